@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertforwarder"
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+)
+
+// newCommandAlertForwarder creates an alert forwarder for a subcommand, using the webhooks, routes, receivers, and templates of the configuration.
+// State which the server would record, e.g. deduplication or the durable queue, is not loaded, so that the subcommand does not affect the server.
+// The route of the receiver is used if it is configured, otherwise the root route.
+func newCommandAlertForwarder(cfg *config.Config, client *http.Client, maximumBackoffElapsedTime time.Duration, receiverName string, opts ...alertforwarder.Option) (*alertforwarder.AlertForwarder, *alertforwarder.Webhooks, error) {
+	cfg.Deduplication = nil
+	cfg.MessageState = nil
+	cfg.Queue = nil
+	cfg.Dispatcher = nil
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := cfg.ReadWebhookURLs(); err != nil {
+		return nil, nil, err
+	}
+
+	route := cfg.Route
+	if receiverName != "" {
+		receiver, ok := findReceiver(cfg, receiverName)
+		if !ok {
+			return nil, nil, fmt.Errorf("receiver ('%s') has not been configured", receiverName)
+		}
+		route = receiver.ReceiverRoute()
+	}
+	if route == nil {
+		return nil, nil, fmt.Errorf("the configuration has no root route, so a receiver must be selected")
+	}
+	if err := route.Compile(); err != nil {
+		return nil, nil, err
+	}
+
+	webhooks, err := alertforwarder.NewWebhooks(client, cfg, maximumBackoffElapsedTime)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	af := alertforwarder.NewRoutingAlertForwarder(webhooks, route, opts...)
+	return &af, webhooks, nil
+}
+
+func findReceiver(cfg *config.Config, name string) (config.Receiver, bool) {
+	if name == "" {
+		return config.Receiver{}, false
+	}
+	for _, receiver := range cfg.Receivers {
+		if receiver.Name == name {
+			return receiver, true
+		}
+	}
+	return config.Receiver{}, false
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertforwarder"
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/decoder"
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+	"github.com/specklesystems/alertmanager-discord/pkg/queue"
+	"github.com/specklesystems/alertmanager-discord/pkg/silence"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	renderOutputJSON = "json"
+	renderOutputText = "text"
+	renderOutputBoth = "both"
+)
+
+var (
+	renderInputPath         string
+	renderConfigurationPath string
+	renderTemplateFiles     []string
+	renderReceiver          string
+	renderOutput            string
+	renderFormat            string
+)
+
+func init() {
+	renderCmd.Flags().StringVarP(&renderInputPath, "input", "i", "-", "Path to the AlertManager webhook payload, or '-' to read it from stdin.")
+	renderCmd.Flags().StringVarP(&renderConfigurationPath, "config", "c", "", "Path to the configuration file, whose webhooks, routes, receivers, and templates are used. If not provided, the default templates are used.")
+	renderCmd.Flags().StringArrayVarP(&renderTemplateFiles, "template", "t", nil, "Path to a template file, parsed for all webhooks after any template files of the configuration. May be repeated.")
+	renderCmd.Flags().StringVarP(&renderReceiver, "receiver", "r", "", "Name of the receiver whose route is used. Defaults to the receiver named by the payload, if one is configured, otherwise the root route.")
+	renderCmd.Flags().StringVarP(&renderOutput, "output", "o", renderOutputBoth, "Output format: 'json', 'text', or 'both'.")
+	renderCmd.Flags().StringVarP(&renderFormat, "format", "f", "", fmt.Sprintf("Format of the input, one of: %s. If not provided, the format is detected.", strings.Join(decoder.Default().Formats(), ", ")))
+	rootCmd.AddCommand(renderCmd)
+}
+
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Renders an AlertManager notification as Discord messages, without sending them.",
+	Long: `Renders an AlertManager webhook payload, or a notification of any other supported format, as the Discord messages which would be sent,
+using the same grouping, routing, and templates as the server, without any network access.
+Each message is printed as the JSON sent to Discord, and as a plain text approximation of how Discord displays it.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if renderOutput != renderOutputJSON && renderOutput != renderOutputText && renderOutput != renderOutputBoth {
+			return fmt.Errorf("output format ('%s') is not supported", renderOutput)
+		}
+
+		input := cmd.InOrStdin()
+		if renderInputPath != "-" {
+			f, err := os.Open(renderInputPath)
+			if err != nil {
+				return fmt.Errorf("unable to open input ('%s'): %w", renderInputPath, err)
+			}
+			defer f.Close()
+			input = f
+		}
+		b, err := io.ReadAll(input)
+		if err != nil {
+			return fmt.Errorf("unable to read input: %w", err)
+		}
+		cfg, err := loadRenderConfig()
+		if err != nil {
+			return err
+		}
+		format := renderFormat
+		if receiver, ok := findReceiver(cfg, renderReceiver); format == "" && ok {
+			format = receiver.Format
+		}
+		amos, err := decodeInput(cfg.Decoders(), b, format)
+		if err != nil {
+			return err
+		}
+
+		payloadReceiver := ""
+		if len(amos) > 0 {
+			payloadReceiver = amos[0].Receiver
+		}
+		af, err := renderAlertForwarder(cfg, payloadReceiver)
+		if err != nil {
+			return err
+		}
+		var messages []queue.Message
+		for _, amo := range amos {
+			rendered, err := af.Render(amo)
+			if err != nil {
+				return err
+			}
+			messages = append(messages, rendered...)
+		}
+
+		w := cmd.OutOrStdout()
+		if renderOutput == renderOutputJSON {
+			outs := make([]discord.Out, 0, len(messages))
+			for _, message := range messages {
+				outs = append(outs, message.Out)
+			}
+			return printJSON(w, outs)
+		}
+		for i, message := range messages {
+			fmt.Fprintf(w, "--- message %d of %d to webhook ('%s') ---\n", i+1, len(messages), message.Webhook)
+			if renderOutput == renderOutputBoth {
+				if err := printJSON(w, message.Out); err != nil {
+					return err
+				}
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintln(w, discord.Text(message.Out))
+		}
+		return nil
+	},
+}
+
+// loadRenderConfig loads the configuration file, if any, with the template files of the command line.
+func loadRenderConfig() (*config.Config, error) {
+	cfg := &config.Config{}
+	if renderConfigurationPath != "" {
+		var err error
+		if cfg, err = config.LoadFile(renderConfigurationPath); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.Webhooks) == 0 {
+		// the url of the default webhook is not needed, as nothing is sent
+		cfg.AddDefaultWebhook("http://localhost", 0)
+	}
+	cfg.TemplateFiles = append(cfg.TemplateFiles, renderTemplateFiles...)
+	for i := range cfg.Webhooks {
+		// the template files of the command line take precedence over those of each webhook
+		cfg.Webhooks[i].TemplateFiles = append(cfg.Webhooks[i].TemplateFiles, renderTemplateFiles...)
+		// the url files of the webhooks, e.g. mounted secrets, may not be available
+		cfg.Webhooks[i].URLFile = ""
+	}
+	return cfg, nil
+}
+
+// renderAlertForwarder creates an alert forwarder for the configuration, which is never used to send to Discord.
+// The route of the receiver named by the flag is used, otherwise that of the receiver named by the payload if it is configured, otherwise the root route.
+func renderAlertForwarder(cfg *config.Config, payloadReceiver string) (*alertforwarder.AlertForwarder, error) {
+	receiverName := renderReceiver
+	if _, ok := findReceiver(cfg, payloadReceiver); receiverName == "" && ok {
+		receiverName = payloadReceiver
+	}
+	var opts []alertforwarder.Option
+	if cfg.Silences != nil {
+		// the links are not signed with the real signing key, so only demonstrate how they would appear
+		durations, _ := cfg.Silences.ParsedDurations()
+		signer := silence.NewSigner([]byte("preview"), cfg.Silences.ExternalURL, time.Duration(cfg.Silences.LinkTTLSeconds)*time.Second, durations)
+		opts = append(opts, alertforwarder.WithSilenceLinks(signer))
+	}
+	af, _, err := newCommandAlertForwarder(cfg, &http.Client{}, 0, receiverName, opts...)
+	return af, err
+}
+
+// decodeInput decodes the input as the format, or as its detected format if none is provided, as the server would with the configured decoders.
+// The alerts of formats which are not grouped by their sender are grouped by alertname, as the server would once their group wait had elapsed,
+// so a notification is returned for each group.
+func decodeInput(decoders *decoder.Registry, b []byte, format string) ([]*alertmanager.Out, error) {
+	d, ok := decoders.Lookup(format)
+	if format == "" {
+		if d, ok = decoders.Detect(b); !ok {
+			return nil, fmt.Errorf("unable to detect the format of the input")
+		}
+	} else if !ok {
+		return nil, fmt.Errorf("format ('%s') is not supported, expected one of: %s", format, strings.Join(decoders.Formats(), ", "))
+	}
+
+	amo, err := d.Decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode input as format ('%s'): %w", d.Format(), err)
+	}
+	if g, ok := d.(decoder.Grouper); ok && g.GroupWait() > 0 {
+		return alertforwarder.GroupByAlertName(amo), nil
+	}
+	return []*alertmanager.Out{amo}, nil
+}
+
+func printJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(v)
+}
@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
 	"github.com/specklesystems/alertmanager-discord/pkg/flags"
 	"github.com/specklesystems/alertmanager-discord/pkg/server"
 	"github.com/specklesystems/alertmanager-discord/pkg/version"
@@ -24,17 +26,29 @@ const (
 var (
 	configurationFilePath     string
 	webhookURL                string
+	webhookURLFile            string
 	listenAddress             string
 	logLevel                  string
 	maximumBackoffTimeSeconds int
+	tlsCertFile               string
+	tlsKeyFile                string
+	tlsClientCAFile           string
+	tlsMinVersion             string
+	tlsCipherSuites           string
 )
 
 func init() {
 	defineConfigurationVariable(&configurationFilePath, rootCmd.Flags().StringVarP, flags.ConfigurationPathFlagKey, "c", defaultConfigurationPath, "Path to the configuration file.")
 	defineConfigurationVariable(&webhookURL, rootCmd.Flags().StringVarP, flags.DiscordWebhookUrlFlagKey, "d", "", "Url to the Discord webhook API endpoint.")
+	defineConfigurationVariable(&webhookURLFile, rootCmd.Flags().StringVarP, flags.DiscordWebhookUrlFileFlagKey, "", "", "Path to a file containing the url to the Discord webhook API endpoint, e.g. a mounted Kubernetes secret. The file is read again when it changes. Ignored if the url itself is provided.")
 	defineConfigurationVariable(&listenAddress, rootCmd.Flags().StringVarP, flags.ListenAddressFlagKey, "l", server.DefaultListenAddress, "The address (host:port) which the server will attempt to bind to and listen on.")
 	defineConfigurationVariable(&logLevel, rootCmd.Flags().StringVarP, flags.LogLevelFlagKey, "", defaultLogLevel, "The minimum level of logging to be produced by the pod. Acceptable values, in ascending order, are 'trace', 'debug', 'info', 'warn', 'error', 'fatal', 'panic', or 'disabled'.")
 	defineConfigurationVariable(&maximumBackoffTimeSeconds, rootCmd.Flags().IntVarP, flags.MaxBackoffTimeSecondsFlagKey, "", defaultMaxBackoffTimeSeconds, "The maximum elapsed duration (expressed as an integer number of seconds) to allow the Discord client to continue retrying to send messages to the Discord API.")
+	defineConfigurationVariable(&tlsCertFile, rootCmd.Flags().StringVarP, flags.TLSCertFileFlagKey, "", "", "Path to the PEM encoded TLS certificate. If provided, the server listens with HTTPS. The certificate is reloaded when the file changes.")
+	defineConfigurationVariable(&tlsKeyFile, rootCmd.Flags().StringVarP, flags.TLSKeyFileFlagKey, "", "", "Path to the PEM encoded private key of the TLS certificate.")
+	defineConfigurationVariable(&tlsClientCAFile, rootCmd.Flags().StringVarP, flags.TLSClientCAFileFlagKey, "", "", "Path to the PEM encoded certificate authorities against which client certificates are verified.")
+	defineConfigurationVariable(&tlsMinVersion, rootCmd.Flags().StringVarP, flags.TLSMinVersionFlagKey, "", server.DefaultTLSMinVersion, "The minimum TLS version which is accepted. Acceptable values are '1.0', '1.1', '1.2', or '1.3'.")
+	defineConfigurationVariable(&tlsCipherSuites, rootCmd.Flags().StringVarP, flags.TLSCipherSuitesFlagKey, "", "", "Comma separated names of the cipher suites which are accepted for TLS 1.2 and below. Defaults to the cipher suites of Go.")
 }
 
 func defineConfigurationVariable[K int | string](variable *K, flagParser func(*K, string, string, K, string), flagKey string, shorthand string, defaultValue K, description string) {
@@ -58,13 +72,20 @@ and forwards that to Discord's message API endpoint.`,
 		// these log messages are generated before the log level is set
 		log.Debug().Msgf("Attempting to read from configuration file path: ('%s')", configurationFilePath)
 		viper.SetConfigFile(configurationFilePath)
+		cfg := &config.Config{}
+		configFileRead := false
 		if err := viper.ReadInConfig(); err != nil {
 			log.Info().Err(err).Msgf("Unable to read configuration file at path ('%s'). Attempting to parse command line arguments or environment variables, the command line argument has higher order of precedence.", configurationFilePath)
+		} else if cfg, err = config.LoadFile(configurationFilePath); err != nil {
+			log.Fatal().Err(err).Msgf("Unable to parse webhooks and routes from configuration file at path ('%s').", configurationFilePath)
+		} else {
+			configFileRead = true
 		}
 
 		if viper.GetString(flags.DiscordWebhookUrlFlagKey) != "" {
 			webhookURL = viper.GetString(flags.DiscordWebhookUrlFlagKey)
 		}
+		addDefaultWebhookFile(cfg, webhookURL)
 		if viper.GetString(flags.ListenAddressFlagKey) != "" {
 			listenAddress = viper.GetString(flags.ListenAddressFlagKey)
 		}
@@ -77,7 +98,21 @@ and forwards that to Discord's message API endpoint.`,
 
 		amds := server.AlertManagerDiscordServer{
 			MaximumBackoffTimeSeconds: time.Duration(maximumBackoffTimeSeconds) * time.Second,
+			Config:                    cfg,
+			TLS: &server.TLSConfig{
+				CertFile:     viper.GetString(flags.TLSCertFileFlagKey),
+				KeyFile:      viper.GetString(flags.TLSKeyFileFlagKey),
+				ClientCAFile: viper.GetString(flags.TLSClientCAFileFlagKey),
+				MinVersion:   viper.GetString(flags.TLSMinVersionFlagKey),
+				CipherSuites: splitList(viper.GetString(flags.TLSCipherSuitesFlagKey)),
+			},
 		}
+		// the configuration file and secret files are watched, and may be reloaded via SIGHUP or the reload endpoint
+		if configFileRead {
+			amds.ConfigFile = configurationFilePath
+		}
+		amds.LoadConfig = func() (*config.Config, string, error) { return reloadConfiguration(configFileRead) }
+		amds.OnReload = func() { setGlobalLogLevel(viper.GetString(flags.LogLevelFlagKey)) }
 		stopCh, err := amds.ListenAndServe(webhookURL, listenAddress)
 		defer func() {
 			if err = amds.Shutdown(); err != nil {
@@ -94,6 +129,31 @@ and forwards that to Discord's message API endpoint.`,
 	},
 }
 
+// reloadConfiguration reads the configuration file again, if it was read at startup.
+// Command line arguments and environment variables continue to take precedence over the file.
+func reloadConfiguration(configFileRead bool) (*config.Config, string, error) {
+	cfg := &config.Config{}
+	if configFileRead {
+		if err := viper.ReadInConfig(); err != nil {
+			return nil, "", fmt.Errorf("unable to read configuration file at path ('%s'): %w", configurationFilePath, err)
+		}
+		var err error
+		if cfg, err = config.LoadFile(configurationFilePath); err != nil {
+			return nil, "", err
+		}
+	}
+	url := viper.GetString(flags.DiscordWebhookUrlFlagKey)
+	addDefaultWebhookFile(cfg, url)
+	return cfg, url, nil
+}
+
+// addDefaultWebhookFile adds the default webhook, whose url is read from the file provided by flag, environment variable, or configuration key, unless the url itself is provided.
+func addDefaultWebhookFile(cfg *config.Config, url string) {
+	if file := viper.GetString(flags.DiscordWebhookUrlFileFlagKey); url == "" && file != "" {
+		cfg.AddDefaultWebhookFile(file, 0)
+	}
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		log.Error().Err(err).Msg("Error when executing command. Exiting program...")
@@ -123,3 +183,14 @@ func setGlobalLogLevel(logLevel string) {
 		break
 	}
 }
+
+// splitList splits a comma separated list, ignoring any empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertforwarder"
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+	"github.com/specklesystems/alertmanager-discord/pkg/flags"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	sendTestAlertName = "AlertmanagerDiscordTest"
+	// maxErrorBodyLength limits the length of the response body printed when Discord rejects a message.
+	maxErrorBodyLength = 512
+)
+
+var (
+	sendTestConfigurationPath string
+	sendTestReceiver          string
+	sendTestStatus            string
+	sendTestLabels            []string
+	sendTestAnnotations       []string
+)
+
+func init() {
+	sendTestCmd.Flags().StringVarP(&sendTestConfigurationPath, "config", "c", defaultConfigurationPath, "Path to the configuration file.")
+	sendTestCmd.Flags().StringVarP(&sendTestReceiver, "receiver", "r", "", "Name of the receiver whose route is used. If not provided, the root route is used.")
+	sendTestCmd.Flags().StringVarP(&sendTestStatus, "status", "s", alertmanager.StatusFiring, "Status of the test alert: 'firing' or 'resolved'.")
+	sendTestCmd.Flags().StringArrayVarP(&sendTestLabels, "label", "l", nil, "Label of the test alert, as 'name=value', e.g. to select a route. May be repeated.")
+	sendTestCmd.Flags().StringArrayVarP(&sendTestAnnotations, "annotation", "a", nil, "Annotation of the test alert, as 'name=value'. May be repeated.")
+	rootCmd.AddCommand(sendTestCmd)
+}
+
+var sendTestCmd = &cobra.Command{
+	Use:   "send-test",
+	Short: "Sends a test alert to Discord through the configured routes and templates.",
+	Long: `Sends a test alert to Discord, exactly as if AlertManager had sent it to the receiver,
+so that a webhook can be verified end to end. For each message sent, the HTTP status,
+Discord's rate limit headers, and the latency are reported. The command exits with a
+non-zero status if Discord does not accept every message.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if sendTestStatus != alertmanager.StatusFiring && sendTestStatus != alertmanager.StatusResolved {
+			return fmt.Errorf("status ('%s') must be either '%s' or '%s'", sendTestStatus, alertmanager.StatusFiring, alertmanager.StatusResolved)
+		}
+		labels, err := parseKeyValues(sendTestLabels)
+		if err != nil {
+			return fmt.Errorf("invalid label: %w", err)
+		}
+		annotations, err := parseKeyValues(sendTestAnnotations)
+		if err != nil {
+			return fmt.Errorf("invalid annotation: %w", err)
+		}
+
+		// the configuration is read as it is by the server, so that the default webhook may be provided by the environment
+		viper.SetConfigFile(sendTestConfigurationPath)
+		cfg := &config.Config{}
+		if err := viper.ReadInConfig(); err == nil {
+			if cfg, err = config.LoadFile(sendTestConfigurationPath); err != nil {
+				return err
+			}
+		} else if viper.GetString(flags.DiscordWebhookUrlFlagKey) == "" && viper.GetString(flags.DiscordWebhookUrlFileFlagKey) == "" {
+			return fmt.Errorf("unable to read configuration file at path ('%s'): %w", sendTestConfigurationPath, err)
+		}
+		maxBackoff := time.Duration(viper.GetInt(flags.MaxBackoffTimeSecondsFlagKey)) * time.Second
+		cfg.AddDefaultWebhook(viper.GetString(flags.DiscordWebhookUrlFlagKey), 0)
+		addDefaultWebhookFile(cfg, viper.GetString(flags.DiscordWebhookUrlFlagKey))
+
+		af, webhooks, err := newCommandAlertForwarder(cfg, &http.Client{}, maxBackoff, sendTestReceiver)
+		if err != nil {
+			return err
+		}
+		messages, err := af.Render(testNotification(sendTestReceiver, sendTestStatus, labels, annotations, time.Now()))
+		if err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return fmt.Errorf("the route did not select any webhook for the test alert")
+		}
+
+		w := cmd.OutOrStdout()
+		failed := 0
+		for _, message := range messages {
+			start := time.Now()
+			res, err := webhooks.Publish(message)
+			latency := time.Since(start)
+
+			fmt.Fprintf(w, "webhook ('%s'):", message.Webhook)
+			if res != nil {
+				fmt.Fprintf(w, " %s in %s\n", res.Status, latency.Round(time.Millisecond))
+				printRateLimit(w, discord.ParseRateLimit(res))
+			} else {
+				fmt.Fprintf(w, " no response in %s\n", latency.Round(time.Millisecond))
+			}
+			if err != nil {
+				fmt.Fprintf(w, "  error: %s\n", err)
+			}
+			if res != nil && (res.StatusCode < 200 || res.StatusCode > 299) {
+				if body := readErrorBody(res); body != "" {
+					fmt.Fprintf(w, "  response: %s\n", body)
+				}
+			}
+			if res != nil && res.Body != nil {
+				res.Body.Close()
+			}
+			if err != nil || res == nil || res.StatusCode < 200 || res.StatusCode > 299 {
+				failed++
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("Discord did not accept %d of %d message(s)", failed, len(messages))
+		}
+		return nil
+	},
+}
+
+// testNotification creates a notification resembling one sent by AlertManager for a single alert.
+// The labels and annotations are added to those of the test alert, replacing any of the same name.
+func testNotification(receiver, status string, labels, annotations map[string]string, now time.Time) *alertmanager.Out {
+	hostname, _ := os.Hostname()
+	alertLabels := map[string]string{
+		"alertname": sendTestAlertName,
+		"severity":  "info",
+		"instance":  hostname,
+	}
+	for name, value := range labels {
+		alertLabels[name] = value
+	}
+	alertAnnotations := map[string]string{
+		"summary":     "Test alert sent by alertmanager-discord",
+		"description": "This alert was sent by the 'send-test' command, to verify that the webhook works. It can be safely ignored.",
+	}
+	for name, value := range annotations {
+		alertAnnotations[name] = value
+	}
+
+	alert := alertmanager.Alert{
+		Status:      status,
+		Labels:      alertLabels,
+		Annotations: alertAnnotations,
+		StartsAt:    now.Add(-5 * time.Minute),
+	}
+	// the fingerprint is derived from the labels exactly as the server derives that of an alert without one
+	alert.Fingerprint = alertforwarder.AlertFingerprint(alert)
+	if status == alertmanager.StatusResolved {
+		alert.EndsAt = now
+	}
+
+	return &alertmanager.Out{
+		Version:           alertmanager.Version,
+		GroupKey:          fmt.Sprintf(`{}:{alertname="%s"}`, alertLabels["alertname"]),
+		Status:            status,
+		Receiver:          receiver,
+		GroupLabels:       map[string]string{"alertname": alertLabels["alertname"]},
+		CommonLabels:      alertLabels,
+		CommonAnnotations: alertAnnotations,
+		Alerts:            []alertmanager.Alert{alert},
+	}
+}
+
+// parseKeyValues parses items of the form 'name=value'.
+func parseKeyValues(items []string) (map[string]string, error) {
+	values := make(map[string]string, len(items))
+	for _, item := range items {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("('%s') must be of the form 'name=value'", item)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+func printRateLimit(w io.Writer, limit discord.RateLimit) {
+	if limit.Bucket == "" && limit.RetryAfter == 0 {
+		return
+	}
+	fmt.Fprintf(w, "  rate limit: bucket ('%s'), %d of %d remaining, resets after %s\n", limit.Bucket, limit.Remaining, limit.Limit, limit.ResetAfter)
+	if limit.RetryAfter > 0 {
+		fmt.Fprintf(w, "  rate limited: retry after %s (global: %t, scope: '%s')\n", limit.RetryAfter, limit.Global, limit.Scope)
+	}
+}
+
+func readErrorBody(res *http.Response) string {
+	if res.Body == nil {
+		return ""
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBodyLength))
+	return strings.TrimSpace(string(b))
+}
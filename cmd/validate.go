@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/flags"
+	"github.com/specklesystems/alertmanager-discord/pkg/validation"
+
+	"github.com/spf13/cobra"
+)
+
+var validateConfigurationFilePath string
+
+func init() {
+	validateCmd.Flags().StringVarP(&validateConfigurationFilePath, "config", "c", defaultConfigurationPath, "Path to the configuration file.")
+	rootCmd.AddCommand(validateCmd)
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validates the configuration file and templates.",
+	Long: `Validates the configuration file without sending anything to Discord.
+The url of every webhook is checked, the matchers of every route and mention are compiled,
+and the templates of every webhook are parsed and rendered against sample AlertManager notifications.
+Each problem is printed with the line of the configuration file at which it was found,
+and the command exits with a non-zero status if there are any problems.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		b, err := os.ReadFile(validateConfigurationFilePath)
+		if err != nil {
+			return fmt.Errorf("unable to read configuration file ('%s'): %w", validateConfigurationFilePath, err)
+		}
+
+		// the default webhook may be provided by the environment, as it would be to the server
+		defaultWebhookURL, err := config.ReadSecret(os.Getenv(strings.ToUpper(flags.DiscordWebhookUrlFlagKey)), os.Getenv(strings.ToUpper(flags.DiscordWebhookUrlFileFlagKey)))
+		if err != nil {
+			return err
+		}
+		problems := validation.Validate(b, defaultWebhookURL)
+		for _, problem := range problems {
+			if problem.Line > 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "%s:%s\n", validateConfigurationFilePath, problem)
+			} else {
+				fmt.Fprintf(cmd.ErrOrStderr(), "%s: %s\n", validateConfigurationFilePath, problem)
+			}
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("configuration file ('%s') has %d problem(s)", validateConfigurationFilePath, len(problems))
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: OK\n", validateConfigurationFilePath)
+		return nil
+	},
+}
@@ -1,17 +1,25 @@
 package alertforwarder
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/decoder"
 	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+	"github.com/specklesystems/alertmanager-discord/pkg/dispatcher"
 	"github.com/specklesystems/alertmanager-discord/pkg/logging"
-	"github.com/specklesystems/alertmanager-discord/pkg/prometheus"
+	"github.com/specklesystems/alertmanager-discord/pkg/queue"
+	"github.com/specklesystems/alertmanager-discord/pkg/routing"
+	"github.com/specklesystems/alertmanager-discord/pkg/silence"
+	"github.com/specklesystems/alertmanager-discord/pkg/templates"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
@@ -26,9 +34,17 @@ type AlertForwarderHandler struct {
 	af AlertForwarder
 }
 
-func NewAlertForwarderHandler(client *http.Client, webhookURL string, maximumBackoffElapsedTime time.Duration) *AlertForwarderHandler {
+func NewAlertForwarderHandler(client *http.Client, webhookURL string, maximumBackoffElapsedTime time.Duration) (*AlertForwarderHandler, error) {
+	af, err := NewAlertForwarder(client, webhookURL, maximumBackoffElapsedTime)
+	if err != nil {
+		return nil, err
+	}
+	return &AlertForwarderHandler{af: af}, nil
+}
+
+func NewRoutingAlertForwarderHandler(webhooks *Webhooks, route *routing.Route, opts ...Option) *AlertForwarderHandler {
 	return &AlertForwarderHandler{
-		af: NewAlertForwarder(client, webhookURL, maximumBackoffElapsedTime),
+		af: NewRoutingAlertForwarder(webhooks, route, opts...),
 	}
 }
 
@@ -37,106 +53,376 @@ func (h *AlertForwarderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 }
 
 type AlertForwarder struct {
-	client *discord.Client
+	webhooks *Webhooks
+	route    *routing.Route
+	queue    *queue.Queue
+	silences *silence.Signer
+
+	// decoders detect the format of each request, unless the decoder of a single format has been selected
+	decoders *decoder.Registry
+	decoder  decoder.Decoder
+	// grouper buffers the alerts of formats which are not grouped by their sender
+	grouper *grouper
+
+	dispatcher *dispatcher.Dispatcher
+	retryAfter time.Duration
+}
+
+// Option configures optional behaviour of an AlertForwarder.
+type Option func(*AlertForwarder)
+
+// WithQueue causes translated messages to be persisted to the durable queue, and delivered asynchronously, rather than being sent to Discord within the request.
+func WithQueue(q *queue.Queue) Option {
+	return func(af *AlertForwarder) {
+		af.queue = q
+	}
 }
 
-func NewAlertForwarder(client *http.Client, webhookURL string, maximumBackoffElapsedTime time.Duration) AlertForwarder {
-	return AlertForwarder{
-		client: discord.NewClient(client, webhookURL, maximumBackoffElapsedTime),
+// WithDispatcher causes translated messages to be delivered asynchronously by the dispatcher, rather than being sent to Discord within the request.
+// If the dispatcher is full, the request is rejected with 503 Service Unavailable, and the client is asked to retry after the duration.
+func WithDispatcher(d *dispatcher.Dispatcher, retryAfter time.Duration) Option {
+	return func(af *AlertForwarder) {
+		af.dispatcher = d
+		af.retryAfter = retryAfter
 	}
 }
 
-func (af *AlertForwarder) groupAlerts(amo *alertmanager.Out) map[string][]alertmanager.Alert {
-	groupedAlerts := make(map[string][]alertmanager.Alert)
+// WithSilenceLinks causes each alert to link to the silence endpoint of this service, from which it can be silenced.
+func WithSilenceLinks(signer *silence.Signer) Option {
+	return func(af *AlertForwarder) {
+		af.silences = signer
+	}
+}
+
+// WithDecoder causes every request to be decoded as the decoder's format, rather than its format being detected.
+func WithDecoder(d decoder.Decoder) Option {
+	return func(af *AlertForwarder) {
+		af.decoder = d
+	}
+}
+
+// WithDecoders causes the format of each request to be detected by the decoders of the registry, rather than the built-in decoders.
+func WithDecoders(r *decoder.Registry) Option {
+	return func(af *AlertForwarder) {
+		af.decoders = r
+	}
+}
+
+// NewAlertForwarder creates an AlertForwarder which sends all alerts to a single Discord webhook, using the default templates.
+// An error is returned if the url is not that of a Discord webhook, or the webhooks cannot be created.
+func NewAlertForwarder(client *http.Client, webhookURL string, maximumBackoffElapsedTime time.Duration) (AlertForwarder, error) {
+	if ok, _, err := CheckWebhookURL(webhookURL); !ok {
+		return AlertForwarder{}, err
+	}
+	webhooks, err := NewWebhooks(client,
+		&config.Config{Webhooks: []config.Webhook{{Name: config.DefaultWebhookName, URL: webhookURL}}},
+		maximumBackoffElapsedTime,
+	)
+	if err != nil {
+		return AlertForwarder{}, err
+	}
+	return NewRoutingAlertForwarder(webhooks, &routing.Route{Webhook: config.DefaultWebhookName}), nil
+}
+
+// NewRoutingAlertForwarder creates an AlertForwarder which selects the Discord webhooks for each alert using the routing tree.
+// The route is expected to have been compiled.
+func NewRoutingAlertForwarder(webhooks *Webhooks, route *routing.Route, opts ...Option) AlertForwarder {
+	af := AlertForwarder{
+		webhooks: webhooks,
+		route:    route,
+		decoders: decoder.Default(),
+	}
+	for _, opt := range opts {
+		opt(&af)
+	}
+	af.grouper = newGrouper(webhooks.sent, route, af.forward)
+	return af
+}
+
+// destination is a webhook and the status of the alerts which will be sent to it in a single message.
+type destination struct {
+	webhook string
+	status  string
+}
+
+// groupAlerts splits the alerts within a notification by webhook, as selected by the routing tree, and by status.
+func (af *AlertForwarder) groupAlerts(amo *alertmanager.Out) map[destination][]alertmanager.Alert {
+	groupedAlerts := make(map[destination][]alertmanager.Alert)
 	for _, alert := range amo.Alerts {
-		groupedAlerts[alert.Status] = append(groupedAlerts[alert.Status], alert)
+		for _, webhook := range af.route.Select(alert.Labels) {
+			dest := destination{webhook: webhook, status: alert.Status}
+			groupedAlerts[dest] = append(groupedAlerts[dest], alert)
+		}
 	}
 	return groupedAlerts
 }
 
+// sortedDestinations returns the destinations in a deterministic order.
+func sortedDestinations(groupedAlerts map[destination][]alertmanager.Alert) []destination {
+	destinations := make([]destination, 0, len(groupedAlerts))
+	for dest := range groupedAlerts {
+		destinations = append(destinations, dest)
+	}
+	sort.Slice(destinations, func(i, j int) bool {
+		if destinations[i].webhook != destinations[j].webhook {
+			return destinations[i].webhook < destinations[j].webhook
+		}
+		return destinations[i].status < destinations[j].status
+	})
+	return destinations
+}
+
 func (af *AlertForwarder) sendWebhook(correlationId string, amo *alertmanager.Out, w http.ResponseWriter) {
+	status := af.forward(correlationId, amo)
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", strconv.Itoa(int(af.retryAfter.Seconds())))
+	}
+	w.WriteHeader(status)
+}
+
+// forward translates the notification, and sends the messages to Discord or hands them to the durable queue or dispatcher, returning the http status with which to respond.
+// If the dispatcher is full, 503 Service Unavailable is returned, and the sender should retry after the duration of the dispatcher.
+func (af *AlertForwarder) forward(correlationId string, amo *alertmanager.Out) int {
 	if len(amo.Alerts) < 1 {
 		log.Debug().
 			Str(logging.FieldKeyCorrelationId, correlationId).
 			Msg("There are no alerts within this notification. There is nothing to forward to Discord. Returning early...")
-		w.WriteHeader(http.StatusOK)
-		return
+		return http.StatusOK
 	}
 
 	logger := zerolog.New(os.Stderr).With().
 		Timestamp().
 		Str(logging.FieldKeyCorrelationId, correlationId).Logger()
-	if amo.CommonLabels.Alertname != "" {
-		logger = logger.With().Str(logging.FieldKeyAlertName, amo.CommonLabels.Alertname).Logger()
-	} else if amo.GroupLabels.Alertname != "" {
-		logger = logger.With().Str(logging.FieldKeyAlertName, amo.GroupLabels.Alertname).Logger()
+	if alertname := amo.CommonLabels["alertname"]; alertname != "" {
+		logger = logger.With().Str(logging.FieldKeyAlertName, alertname).Logger()
+	} else if alertname := amo.GroupLabels["alertname"]; alertname != "" {
+		logger = logger.With().Str(logging.FieldKeyAlertName, alertname).Logger()
 	}
 
-	failedToPublishAtLeastOne := false
-	for status, alerts := range af.groupAlerts(amo) {
-		DO := TranslateAlertManagerToDiscord(status, amo, alerts)
+	messages, observations, ok := af.translate(logger, amo)
 
-		logger.Info().
-			Str(logging.FieldKeyEventType, logging.EventTypeRequestSending).
-			Str(logging.FieldKeyCorrelationId, correlationId).
-			Msg("Sending HTTP request to Discord.")
-		res, err := af.client.PublishMessage(DO)
-		if err != nil {
-			err = fmt.Errorf("failed to publish message to Discord: %w", err)
+	for i := range messages {
+		messages[i].CorrelationId = correlationId
+	}
+
+	if af.queue != nil {
+		if err := af.queue.Enqueue(messages...); err != nil {
 			logger.Error().
 				Str(logging.FieldKeyCorrelationId, correlationId).
 				Err(err).
-				Msg("Error when attempting to publish message to Discord.")
-			failedToPublishAtLeastOne = true
-			continue
+				Msg("Error when attempting to persist messages to the durable queue.")
+			af.undoDeduplication(observations)
+			return http.StatusInternalServerError
+		}
+		if !ok {
+			return http.StatusInternalServerError
 		}
 
-		logger.Info().
-			Str(logging.FieldKeyEventType, logging.EventTypeResponseReceived).
+		logger.Debug().
 			Str(logging.FieldKeyCorrelationId, correlationId).
-			Msg("HTTP response received from Discord")
+			Msgf("Persisted %d messages to the durable queue.", len(messages))
+		return http.StatusAccepted
+	}
+
+	if af.dispatcher != nil {
+		if err := af.dispatcher.Dispatch(messages...); err != nil {
+			logger.Warn().
+				Err(err).
+				Msg("Unable to dispatch messages. AlertManager is asked to retry later.")
+			af.undoDeduplication(observations)
+			return http.StatusServiceUnavailable
+		}
+		if !ok {
+			return http.StatusInternalServerError
+		}
+
+		logger.Debug().Msgf("Dispatched %d messages.", len(messages))
+		return http.StatusAccepted
+	}
 
-		if res.StatusCode < 200 || res.StatusCode > 399 {
+	failedToPublishAtLeastOne := !ok
+	for _, message := range messages {
+		logger := logger.With().Str(logging.FieldKeyWebhook, message.Webhook).Logger()
+		if !af.publishMessage(logger, message) {
 			failedToPublishAtLeastOne = true
-			continue
 		}
 	}
 
 	if failedToPublishAtLeastOne {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		// AlertManager retries the notification, which must not then be dropped as a duplicate
+		af.undoDeduplication(observations)
+		return http.StatusInternalServerError
 	}
 
-	w.WriteHeader(http.StatusOK)
+	return http.StatusOK
 }
 
-func (af *AlertForwarder) sendRawPromAlertWarn(correlationId string) (*http.Response, error) {
+// translate groups the alerts by webhook and status, and renders each group as Discord messages.
+// Groups which have already been sent within the deduplication window are dropped; the observations of the deduplicator are returned, so that they may be undone.
+// If any group cannot be translated, the remaining groups are still returned, along with false.
+func (af *AlertForwarder) translate(logger zerolog.Logger, amo *alertmanager.Out) ([]queue.Message, []observation, bool) {
+	ok := true
+	var messages []queue.Message
+	var observations []observation
 
-	warningMessage := `You have probably misconfigured this software.
-We detected input in Prometheus Alert format but are expecting AlertManager format.
-This program is intended to ingest alerts from alertmanager.
-It is not a replacement for alertmanager, it is a
-webhook target for it. Please read the README.md
-for guidance on how to configure it for alertmanager
-or https://prometheus.io/docs/alerting/latest/configuration/#webhook_config`
-	log.Warn().Msg(warningMessage)
+	groupedAlerts := af.groupAlerts(amo)
+	for _, dest := range sortedDestinations(groupedAlerts) {
+		logger := logger.With().Str(logging.FieldKeyWebhook, dest.webhook).Logger()
+
+		if _, exists := af.webhooks.clients[dest.webhook]; !exists {
+			logger.Error().Msg("The route selected a webhook which has not been configured. Unable to publish message to Discord.")
+			ok = false
+			continue
+		}
+
+		alerts := groupedAlerts[dest]
+		repeats := 0
+		if af.webhooks.dedup != nil {
+			duplicate, n, obs := af.webhooks.dedup.observe(dest.webhook, amo.GroupKey, dest.status, alerts)
+			if duplicate {
+				logger.Info().Msgf("Dropping %s notification, as it has already been sent within the deduplication window.", dest.status)
+				continue
+			}
+			repeats = n
+			observations = append(observations, obs)
+		}
+
+		editResolved := af.webhooks.configs[dest.webhook].EditResolved
+		if editResolved && dest.status == alertmanager.StatusResolved {
+			var edits []queue.Message
+			edits, alerts = af.editResolvedMessages(logger, dest.webhook, amo, alerts)
+			messages = append(messages, edits...)
+			if len(alerts) == 0 {
+				continue
+			}
+		}
+
+		translated, err := TranslateAlertManagerToDiscord(dest.status, amo, alerts, af.webhooks.templates[dest.webhook], af.silences, af.webhooks.severities)
+		if err != nil {
+			// a broken template should not prevent the alert from reaching Discord
+			logger.Error().
+				Err(err).
+				Msg("Error when rendering the templates of the webhook. Falling back to the default templates.")
+			if translated, err = TranslateAlertManagerToDiscord(dest.status, amo, alerts, templates.Default(), af.silences, af.webhooks.severities); err != nil {
+				logger.Error().
+					Err(err).
+					Msg("Error when rendering the default templates. Unable to publish message to Discord.")
+				ok = false
+				continue
+			}
+		}
+
+		digest := repeats > 0 && dest.status == alertmanager.StatusFiring && af.webhooks.dedup.repeatDigest
+		if digest {
+			translated = repeatDigest(translated, repeats)
+		}
+
+		var mentions []config.Mention
+		if dest.status == alertmanager.StatusFiring {
+			// resolved alerts do not require anyone's attention
+			mentions = af.webhooks.mentions[dest.webhook]
+		}
+		translated = applyMentions(translated, mentions, alerts)
+
+		threadName := ""
+		if af.webhooks.configs[dest.webhook].Threads {
+			if threadName, err = TranslateThreadName(dest.status, amo, alerts, af.webhooks.templates[dest.webhook]); err != nil {
+				logger.Error().
+					Err(err).
+					Msg("Error when rendering the thread name template of the webhook. Falling back to the default template.")
+				threadName, _ = TranslateThreadName(dest.status, amo, alerts, templates.Default())
+			}
+		}
+
+		// messages which were split cannot be reliably edited, as the alerts are spread across them
+		// a reminder is not tracked, so that the original message is edited once the alerts resolve
+		track := editResolved && dest.status == alertmanager.StatusFiring && len(translated) == 1 && !digest
+
+		for _, out := range translated {
+			// the thread name is only used if the thread of the group does not yet exist
+			out.ThreadName = threadName
+			message := queue.Message{Webhook: dest.webhook, GroupKey: amo.GroupKey, Out: out}
+			if track {
+				message.Fingerprints = fingerprints(alerts)
+			}
+			messages = append(messages, message)
+		}
+	}
+
+	return messages, observations, ok
+}
+
+// Render groups, routes and translates the notification as TransformAndForward would, returning the messages rather than sending them to Discord.
+// Errors, e.g. of templates which fall back to the defaults, are logged; an error is returned if any group could not be translated.
+// The webhooks should not deduplicate notifications, as the rendered notification would be recorded as sent.
+func (af *AlertForwarder) Render(amo *alertmanager.Out) ([]queue.Message, error) {
+	if err := amo.CheckVersion(); err != nil {
+		return nil, err
+	}
+	messages, _, ok := af.translate(log.Logger, amo)
+	if !ok {
+		return messages, fmt.Errorf("unable to translate all alerts of the notification")
+	}
+	return messages, nil
+}
+
+func (af *AlertForwarder) undoDeduplication(observations []observation) {
+	if af.webhooks.dedup != nil {
+		af.webhooks.dedup.undo(observations)
+	}
+}
+
+// publishMessage sends the message to Discord, returning true if Discord responded successfully.
+func (af *AlertForwarder) publishMessage(logger zerolog.Logger, message queue.Message) bool {
+	logger.Info().
+		Str(logging.FieldKeyEventType, logging.EventTypeRequestSending).
+		Str(logging.FieldKeyCorrelationId, message.CorrelationId).
+		Msg("Sending HTTP request to Discord.")
+	res, err := af.webhooks.Publish(message)
+	if err != nil {
+		err = fmt.Errorf("failed to publish message to Discord: %w", err)
+		logger.Error().
+			Str(logging.FieldKeyCorrelationId, message.CorrelationId).
+			Err(err).
+			Msg("Error when attempting to publish message to Discord.")
+		return false
+	}
+	discord.CloseBody(res)
+
+	logger.Info().
+		Str(logging.FieldKeyEventType, logging.EventTypeResponseReceived).
+		Str(logging.FieldKeyCorrelationId, message.CorrelationId).
+		Int(logging.FieldKeyStatusCode, res.StatusCode).
+		Msg("HTTP response received from Discord")
+
+	return res.StatusCode >= 200 && res.StatusCode <= 399
+}
+
+func (af *AlertForwarder) sendMisconfigurationWarning(correlationId string, misconfiguration *decoder.MisconfigurationError) (*http.Response, error) {
+	log.Warn().Msg(misconfiguration.Description)
 	DO := discord.Out{
 		Content: "",
 		Embeds: []discord.Embed{
 			{
-				Title:       "You have misconfigured this software",
-				Description: warningMessage,
+				Title:       misconfiguration.Title,
+				Description: misconfiguration.Description,
 				Color:       discord.ColorGrey,
 				Fields:      []discord.EmbedField{},
 			},
 		},
 	}
 
+	// there are no labels with which to route, so the warning is sent to the webhook selected by the root route
+	client, ok := af.webhooks.clients[af.route.Webhook]
+	if !ok {
+		return nil, fmt.Errorf("the webhook ('%s') of the root route has not been configured", af.route.Webhook)
+	}
+
 	log.Info().
 		Str(logging.FieldKeyEventType, logging.EventTypeRequestSending).
 		Str(logging.FieldKeyCorrelationId, correlationId).
 		Msg("Sending HTTP request to Discord.")
-	res, err := af.client.PublishMessage(DO)
+	res, err := client.PublishMessage(DO)
 	if err != nil {
 		return nil, fmt.Errorf("error encountered when publishing message to Discord: %w", err)
 	}
@@ -172,41 +458,73 @@ func (af *AlertForwarder) TransformAndForward(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	amo := alertmanager.Out{}
-	err = json.Unmarshal(b, &amo)
+	d := af.decoder
+	if d == nil {
+		var detected bool
+		if d, detected = af.decoders.Detect(b); !detected {
+			af.handleInvalidInput(correlationId, b, w)
+			return
+		}
+		log.Debug().
+			Str(logging.FieldKeyCorrelationId, correlationId).
+			Str(logging.FieldKeyFormat, d.Format()).
+			Msg("Detected the format of the notification.")
+	}
+
+	amo, err := d.Decode(b)
+	var misconfiguration *decoder.MisconfigurationError
+	if errors.As(err, &misconfiguration) {
+		af.handleMisconfiguration(correlationId, misconfiguration, w)
+		return
+	}
 	if err != nil {
-		af.handleInvalidInput(correlationId, b, w)
+		log.Error().
+			Str(logging.FieldKeyCorrelationId, correlationId).
+			Str(logging.FieldKeyFormat, d.Format()).
+			Err(err).
+			Msg("Unable to forward the notification to Discord.")
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	af.sendWebhook(correlationId, &amo, w)
-}
-
-func (af *AlertForwarder) handleInvalidInput(correlationId string, b []byte, w http.ResponseWriter) {
-	if prometheus.IsAlert(b) {
-		log.Info().
+	if g, ok := d.(decoder.Grouper); ok && g.GroupWait() > 0 {
+		af.grouper.add(correlationId, amo, g.GroupWait())
+		log.Debug().
 			Str(logging.FieldKeyCorrelationId, correlationId).
-			Msg("Detected a Prometheus Alert, and not an AlertManager alert, has been sent within the http request. This indicates a misconfiguration. Attempting to send a message to notify the Discord channel of the misconfiguration.")
-		res, err := af.sendRawPromAlertWarn(correlationId)
-		if err != nil || (res != nil && res.StatusCode < 200 || res.StatusCode > 399) {
-			statusCode := 0
-			if res != nil {
-				statusCode = res.StatusCode
-			}
+			Str(logging.FieldKeyFormat, d.Format()).
+			Msgf("Grouping %d alerts by alertname for %s before forwarding them.", len(amo.Alerts), g.GroupWait())
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
 
-			log.Error().
-				Err(err).
-				Str(logging.FieldKeyCorrelationId, correlationId).
-				Int(logging.FieldKeyStatusCode, statusCode).
-				Msg("Error when attempting to send a warning message to Discord.")
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+	af.sendWebhook(correlationId, amo, w)
+}
+
+func (af *AlertForwarder) handleMisconfiguration(correlationId string, misconfiguration *decoder.MisconfigurationError, w http.ResponseWriter) {
+	log.Info().
+		Str(logging.FieldKeyCorrelationId, correlationId).
+		Str(logging.FieldKeyFormat, misconfiguration.Format).
+		Msg("Detected a notification which indicates that its sender has been misconfigured. Attempting to send a message to notify the Discord channel of the misconfiguration.")
+	res, err := af.sendMisconfigurationWarning(correlationId, misconfiguration)
+	if err != nil || (res != nil && res.StatusCode < 200 || res.StatusCode > 399) {
+		statusCode := 0
+		if res != nil {
+			statusCode = res.StatusCode
 		}
 
-		w.WriteHeader(http.StatusUnprocessableEntity)
+		log.Error().
+			Err(err).
+			Str(logging.FieldKeyCorrelationId, correlationId).
+			Int(logging.FieldKeyStatusCode, statusCode).
+			Msg("Error when attempting to send a warning message to Discord.")
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	w.WriteHeader(http.StatusUnprocessableEntity)
+}
+
+func (af *AlertForwarder) handleInvalidInput(correlationId string, b []byte, w http.ResponseWriter) {
 	if len(b) > maxLogLength-3 {
 		log.Info().
 			Str(logging.FieldKeyCorrelationId, correlationId).
@@ -3,16 +3,23 @@ package alertforwarder
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/decoder"
 	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+	"github.com/specklesystems/alertmanager-discord/pkg/dispatcher"
 	"github.com/specklesystems/alertmanager-discord/pkg/prometheus"
+	"github.com/specklesystems/alertmanager-discord/pkg/routing"
 	. "github.com/specklesystems/alertmanager-discord/test"
 
 	"github.com/stretchr/testify/assert"
@@ -25,10 +32,8 @@ func Test_TransformAndForward_HappyPath(t *testing.T) {
 				Status: alertmanager.StatusFiring,
 			},
 		},
-		CommonAnnotations: struct {
-			Summary string `json:"summary"`
-		}{
-			Summary: "a_common_annotation_summary",
+		CommonAnnotations: map[string]string{
+			"summary": "a_common_annotation_summary",
 		},
 	}
 
@@ -53,7 +58,8 @@ func Test_TransformAndForward_InvalidInput_NoValue_ReturnsErrorResponseCode(t *t
 	mockClientRecorder := MockClientRecorder{}
 	mockClient := mockClientRecorder.NewMockClientWithResponse(http.StatusBadRequest)
 
-	SUT := NewAlertForwarder(mockClient, "https://discordapp.com/api/webhooks/123456789123456789/abc", 100*time.Millisecond)
+	SUT, err := NewAlertForwarder(mockClient, "https://discordapp.com/api/webhooks/123456789123456789/abc", 100*time.Millisecond)
+	assert.NoError(t, err, "creating alert forwarder")
 
 	w := httptest.NewRecorder()
 	SUT.TransformAndForward(w, req)
@@ -73,7 +79,8 @@ func Test_TransformAndForward_InvalidInput_LongString_ReturnsErrorResponseCode(t
 	mockClientRecorder := MockClientRecorder{}
 	mockClient := mockClientRecorder.NewMockClientWithResponse(http.StatusBadRequest)
 
-	SUT := NewAlertForwarder(mockClient, "https://discordapp.com/api/webhooks/123456789123456789/abc", 100*time.Millisecond)
+	SUT, err := NewAlertForwarder(mockClient, "https://discordapp.com/api/webhooks/123456789123456789/abc", 100*time.Millisecond)
+	assert.NoError(t, err, "creating alert forwarder")
 
 	w := httptest.NewRecorder()
 	SUT.TransformAndForward(w, req)
@@ -100,7 +107,8 @@ func Test_TransformAndForward_InvalidInput_PrometheusAlert_ReturnsErrorResponseC
 	mockClientRecorder := MockClientRecorder{}
 	mockClient := mockClientRecorder.NewMockClientWithResponse(http.StatusBadRequest)
 
-	SUT := NewAlertForwarder(mockClient, "https://discordapp.com/api/webhooks/123456789123456789/abc", 100*time.Millisecond)
+	SUT, err := NewAlertForwarder(mockClient, "https://discordapp.com/api/webhooks/123456789123456789/abc", 100*time.Millisecond)
+	assert.NoError(t, err, "creating alert forwarder")
 
 	w := httptest.NewRecorder()
 	SUT.TransformAndForward(w, req)
@@ -129,7 +137,8 @@ func Test_TransformAndForward_PrometheusAlert_And_DiscordClientResponsdsWithErro
 	mockClientRecorder := MockClientRecorder{}
 	mockClient := mockClientRecorder.NewMockClientReturnsNil()
 
-	SUT := NewAlertForwarder(mockClient, "https://discordapp.com/api/webhooks/123456789123456789/abc", 100*time.Millisecond)
+	SUT, err := NewAlertForwarder(mockClient, "https://discordapp.com/api/webhooks/123456789123456789/abc", 100*time.Millisecond)
+	assert.NoError(t, err, "creating alert forwarder")
 
 	w := httptest.NewRecorder()
 	SUT.TransformAndForward(w, req)
@@ -157,7 +166,8 @@ func Test_TransformAndForward_PrometheusAlert_And_DiscordClientResponsdsWithErro
 	mockClientRecorder := MockClientRecorder{}
 	mockClient := mockClientRecorder.NewMockClientWithResponse(http.StatusBadRequest)
 
-	SUT := NewAlertForwarder(mockClient, "https://discordapp.com/api/webhooks/123456789123456789/abc", 100*time.Millisecond)
+	SUT, err := NewAlertForwarder(mockClient, "https://discordapp.com/api/webhooks/123456789123456789/abc", 100*time.Millisecond)
+	assert.NoError(t, err, "creating alert forwarder")
 
 	w := httptest.NewRecorder()
 	SUT.TransformAndForward(w, req)
@@ -171,6 +181,19 @@ func Test_TransformAndForward_PrometheusAlert_And_DiscordClientResponsdsWithErro
 	// TODO test message content sent to Discord
 }
 
+func Test_TransformAndForward_UnsupportedVersion_ReturnsBadRequest(t *testing.T) {
+	ao := alertmanager.Out{
+		Version: "5",
+		Alerts:  []alertmanager.Alert{{Status: alertmanager.StatusFiring}},
+	}
+
+	mockClientRecorder, res := triggerAndRecordRequest(t, ao, http.StatusOK)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "http response status code")
+	assert.Equal(t, 0, len(mockClientRecorder.Requests), "Should not have sent a request to Discord")
+}
+
 func Test_TransformAndForward_NoAlerts_DoesNotSendToDiscord(t *testing.T) {
 	ao := alertmanager.Out{}
 
@@ -264,10 +287,8 @@ func Test_TransformAndForward_DiscordClientReturnsError(t *testing.T) {
 				Status: alertmanager.StatusFiring,
 			},
 		},
-		CommonAnnotations: struct {
-			Summary string `json:"summary"`
-		}{
-			Summary: "a_common_annotation_summary",
+		CommonAnnotations: map[string]string{
+			"summary": "a_common_annotation_summary",
 		},
 	}
 
@@ -292,10 +313,8 @@ func Test_TransformAndForward_DiscordReturnsWithErrorStatusCode_ReturnInternalSe
 				Status: alertmanager.StatusFiring,
 			},
 		},
-		CommonAnnotations: struct {
-			Summary string `json:"summary"`
-		}{
-			Summary: "a_common_annotation_summary",
+		CommonAnnotations: map[string]string{
+			"summary": "a_common_annotation_summary",
 		},
 	}
 
@@ -332,6 +351,586 @@ func Test_TransformAndForward_MultipleAlerts_DifferentStatus_HappyPath(t *testin
 	assert.Equal(t, 2, len(mockClientRecorder.Requests), "Should have sent two requests to Discord")
 }
 
+func Test_TransformAndForward_GrafanaNotification_LinksDashboardAndImage(t *testing.T) {
+	ao := alertmanager.Out{
+		Version:      alertmanager.GrafanaVersion,
+		Status:       alertmanager.StatusFiring,
+		OrgID:        1,
+		State:        "alerting",
+		ExternalURL:  "https://grafana.example.com/",
+		CommonLabels: map[string]string{"alertname": "HighCPU"},
+		Alerts: []alertmanager.Alert{
+			{
+				Status:       alertmanager.StatusFiring,
+				Labels:       map[string]string{"alertname": "HighCPU"},
+				DashboardURL: "https://grafana.example.com/d/cpu",
+				PanelURL:     "https://grafana.example.com/d/cpu?viewPanel=2",
+				SilenceURL:   "https://grafana.example.com/alerting/silence/new",
+				ImageURL:     "https://grafana.example.com/public/img/attachments/cpu.png",
+				Values:       map[string]float64{"B": 93.5},
+			},
+		},
+	}
+
+	mockClientRecorder, res := triggerAndRecordRequest(t, ao, http.StatusOK)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "http response status code")
+	assert.Equal(t, 1, len(mockClientRecorder.Requests), "Should have sent one request to Discord")
+
+	do := readerToDiscordOut(t, mockClientRecorder.Requests[0].Body)
+	embed := do.Embeds[0]
+	assert.Equal(t, "https://grafana.example.com/d/cpu", embed.URL, "embed title should link to the dashboard")
+	assert.Equal(t, &discord.EmbedImage{URL: "https://grafana.example.com/public/img/attachments/cpu.png"}, embed.Image, "embed image")
+	assert.Contains(t, embed.Fields[0].Value, "B: 93.5", "field value should contain the values of the alert")
+	assert.Contains(t, embed.Fields[0].Value, "[Panel](https://grafana.example.com/d/cpu?viewPanel=2)", "field value should link to the panel")
+	assert.Contains(t, embed.Fields[0].Value, "[Silence](https://grafana.example.com/alerting/silence/new)", "field value should link to Grafana's silence page")
+}
+
+func Test_TransformAndForward_WithDecoder_DecodesAsTheSelectedFormat(t *testing.T) {
+	mockClientRecorder := MockClientRecorder{}
+	mockClient := mockClientRecorder.NewMockClientWithResponse(http.StatusOK)
+
+	webhooks, err := NewWebhooks(mockClient, &config.Config{
+		Webhooks: []config.Webhook{{Name: "default", URL: "https://discordapp.com/api/webhooks/123456789123456789/abc"}},
+	}, 100*time.Millisecond)
+	assert.NoError(t, err, "creating webhooks")
+	SUT := NewRoutingAlertForwarder(webhooks, &routing.Route{Webhook: "default"}, WithDecoder(decoder.JSON{}))
+
+	w := httptest.NewRecorder()
+	SUT.TransformAndForward(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"title": "Backup failed", "description": "exit status 1"}`)))
+	assert.Equal(t, http.StatusOK, w.Code, "generic JSON notification should be forwarded")
+	if assert.Equal(t, 1, len(mockClientRecorder.Requests), "Should have sent one request to Discord") {
+		do := readerToDiscordOut(t, mockClientRecorder.Requests[0].Body)
+		assert.Equal(t, "[FIRING: 1] Backup failed", do.Embeds[0].Title, "Discord message embed title")
+		assert.Contains(t, do.Embeds[0].Fields[0].Value, "exit status 1", "Discord message embed field")
+	}
+
+	w = httptest.NewRecorder()
+	SUT.TransformAndForward(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"alerts": [{"status": "firing"}]}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "AlertManager's payload should not be detected once a format has been selected")
+	assert.Equal(t, 1, len(mockClientRecorder.Requests), "should not have sent another request to Discord")
+}
+
+func Test_TransformAndForward_ForwardedPrometheusAlerts_AreGroupedByAlertName(t *testing.T) {
+	var mu sync.Mutex
+	var received []discord.Out
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, readerToDiscordOut(t, r.Body))
+	}))
+	defer mockDiscordServer.Close()
+
+	webhooks, err := NewWebhooks(&http.Client{}, &config.Config{
+		Webhooks: []config.Webhook{{Name: "default", URL: mockDiscordServer.URL}},
+	}, 100*time.Millisecond)
+	assert.NoError(t, err, "creating webhooks")
+	decoders := decoder.Default()
+	decoders.Register(decoder.Prometheus{Forward: true, Wait: 50 * time.Millisecond})
+	SUT := NewRoutingAlertForwarder(webhooks, &routing.Route{Webhook: "default"}, WithDecoders(decoders))
+
+	for _, body := range []string{
+		`[{"labels": {"alertname": "HighCPU", "instance": "web-1"}, "endsAt": "2999-01-01T00:00:00Z"}, {"labels": {"alertname": "DiskFull", "instance": "db-1"}, "endsAt": "2999-01-01T00:00:00Z"}]`,
+		`[{"labels": {"alertname": "HighCPU", "instance": "web-2"}, "endsAt": "2999-01-01T00:00:00Z"}, {"labels": {"alertname": "HighCPU", "instance": "web-1"}, "endsAt": "2999-01-01T00:00:00Z"}]`,
+	} {
+		w := httptest.NewRecorder()
+		SUT.TransformAndForward(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		assert.Equal(t, http.StatusAccepted, w.Code, "alerts should be accepted to be grouped")
+	}
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 2
+	}, 5*time.Second, 10*time.Millisecond, "should have sent one message per alertname")
+
+	mu.Lock()
+	defer mu.Unlock()
+	titles := []string{received[0].Embeds[0].Title, received[1].Embeds[0].Title}
+	assert.ElementsMatch(t, []string{"[FIRING: 2] HighCPU", "[FIRING: 1] DiskFull"}, titles, "alerts sent again within the group wait should not be repeated")
+}
+
+func Test_TransformAndForward_ForwardedPrometheusAlerts_AreOnlyForwardedWhenTheirStatusChanges(t *testing.T) {
+	var mu sync.Mutex
+	var received []discord.Out
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, readerToDiscordOut(t, r.Body))
+	}))
+	defer mockDiscordServer.Close()
+
+	webhooks, err := NewWebhooks(&http.Client{}, &config.Config{
+		Webhooks: []config.Webhook{{Name: "default", URL: mockDiscordServer.URL}},
+	}, 100*time.Millisecond)
+	assert.NoError(t, err, "creating webhooks")
+	decoders := decoder.Default()
+	decoders.Register(decoder.Prometheus{Forward: true, Wait: 20 * time.Millisecond})
+	SUT := NewRoutingAlertForwarder(webhooks, &routing.Route{Webhook: "default"}, WithDecoders(decoders))
+
+	receivedCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received)
+	}
+	send := func(body string) {
+		w := httptest.NewRecorder()
+		SUT.TransformAndForward(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		assert.Equal(t, http.StatusAccepted, w.Code, "alerts should be accepted to be grouped")
+	}
+
+	firing := `[{"labels": {"alertname": "HighCPU", "instance": "web-1"}, "endsAt": "2999-01-01T00:00:00Z"}]`
+	send(firing)
+	assert.Eventually(t, func() bool { return receivedCount() == 1 }, 5*time.Second, 10*time.Millisecond, "the firing alert should be forwarded")
+
+	// Prometheus sends firing alerts again on each evaluation
+	for i := 0; i < 2; i++ {
+		send(firing)
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.Equal(t, 1, receivedCount(), "an alert which is still firing should not be forwarded again")
+
+	send(`[{"labels": {"alertname": "HighCPU", "instance": "web-1"}, "endsAt": "2000-01-01T00:00:00Z"}]`)
+	assert.Eventually(t, func() bool { return receivedCount() == 2 }, 5*time.Second, 10*time.Millisecond, "the resolved alert should be forwarded")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, "[RESOLVED: 1] HighCPU", received[1].Embeds[0].Title, "resolved message title")
+}
+
+func Test_TransformAndForward_ForwardedPrometheusAlerts_AreNotForwardedAgainAfterReload(t *testing.T) {
+	var mu sync.Mutex
+	var received []discord.Out
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, readerToDiscordOut(t, r.Body))
+	}))
+	defer mockDiscordServer.Close()
+
+	cfg := &config.Config{Webhooks: []config.Webhook{{Name: "default", URL: mockDiscordServer.URL}}}
+	webhooks, err := NewWebhooks(&http.Client{}, cfg, 100*time.Millisecond)
+	assert.NoError(t, err, "creating webhooks")
+	decoders := decoder.Default()
+	decoders.Register(decoder.Prometheus{Forward: true, Wait: 20 * time.Millisecond})
+
+	receivedCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received)
+	}
+	send := func(SUT AlertForwarder, body string) {
+		w := httptest.NewRecorder()
+		SUT.TransformAndForward(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		assert.Equal(t, http.StatusAccepted, w.Code, "alerts should be accepted to be grouped")
+	}
+
+	firing := `[{"labels": {"alertname": "HighCPU", "instance": "web-1"}, "endsAt": "2999-01-01T00:00:00Z"}]`
+	send(NewRoutingAlertForwarder(webhooks, &routing.Route{Webhook: "default"}, WithDecoders(decoders)), firing)
+	assert.Eventually(t, func() bool { return receivedCount() == 1 }, 5*time.Second, 10*time.Millisecond, "the firing alert should be forwarded")
+
+	reloaded, err := ReloadWebhooks(&http.Client{}, cfg, 100*time.Millisecond, webhooks)
+	assert.NoError(t, err, "reloading webhooks")
+	SUT := NewRoutingAlertForwarder(reloaded, &routing.Route{Webhook: "default"}, WithDecoders(decoders))
+	send(SUT, firing)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 1, receivedCount(), "an alert which is still firing should not be forwarded again after a reload")
+
+	send(SUT, `[{"labels": {"alertname": "HighCPU", "instance": "web-1"}, "endsAt": "2000-01-01T00:00:00Z"}]`)
+	assert.Eventually(t, func() bool { return receivedCount() == 2 }, 5*time.Second, 10*time.Millisecond, "the resolved alert should be forwarded")
+}
+
+func Test_GroupByAlertName_SplitsTheNotificationByAlertName(t *testing.T) {
+	amo := &alertmanager.Out{
+		Receiver: "prometheus",
+		Alerts: []alertmanager.Alert{
+			{Status: alertmanager.StatusFiring, Labels: map[string]string{"alertname": "HighCPU", "instance": "web-1"}},
+			{Status: alertmanager.StatusFiring, Labels: map[string]string{"alertname": "DiskFull", "instance": "db-1"}},
+			{Status: alertmanager.StatusResolved, Labels: map[string]string{"alertname": "HighCPU", "instance": "web-1"}},
+			{Status: alertmanager.StatusFiring, Labels: map[string]string{"alertname": "HighCPU", "instance": "web-2"}},
+		},
+	}
+
+	groups := GroupByAlertName(amo)
+
+	assert.Equal(t, 2, len(groups), "should have one notification per alertname")
+	assert.Equal(t, `{}:{alertname="HighCPU"}`, groups[0].GroupKey, "group key of the first alertname")
+	assert.Equal(t, "prometheus", groups[0].Receiver, "receiver")
+	assert.Equal(t, 2, len(groups[0].Alerts), "an alert sent again should replace its earlier state")
+	assert.Equal(t, alertmanager.StatusResolved, groups[0].Alerts[0].Status, "the latest state of the alert should be retained")
+	assert.Equal(t, `{}:{alertname="DiskFull"}`, groups[1].GroupKey, "group key of the second alertname")
+}
+
+func Test_TransformAndForward_Routing_SplitsAlertsAcrossWebhooks(t *testing.T) {
+	ao := alertmanager.Out{
+		Alerts: []alertmanager.Alert{
+			{
+				Status: alertmanager.StatusFiring,
+				Labels: map[string]string{"team": "db"},
+			},
+			{
+				Status: alertmanager.StatusFiring,
+				Labels: map[string]string{"team": "web"},
+			},
+			{
+				Status: alertmanager.StatusResolved,
+				Labels: map[string]string{"team": "db", "severity": "critical"},
+			},
+		},
+	}
+	aoJson, err := json.Marshal(ao)
+	assert.NoError(t, err, "marshalling alertmanager out")
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(aoJson))
+	req.Host = "testing.localhost"
+
+	mockClientRecorder := MockClientRecorder{}
+	mockClient := mockClientRecorder.NewMockClientWithResponse(http.StatusOK)
+
+	route := &routing.Route{
+		Webhook: "default",
+		Routes: []*routing.Route{
+			{Webhook: "critical", Match: map[string]string{"severity": "critical"}, Continue: true},
+			{Webhook: "database", Match: map[string]string{"team": "db"}},
+		},
+	}
+	assert.NoError(t, route.Compile(), "compiling route")
+
+	webhooks, err := NewWebhooks(mockClient, &config.Config{
+		Webhooks: []config.Webhook{
+			{Name: "default", URL: "https://discordapp.com/api/webhooks/123456789123456789/default"},
+			{Name: "critical", URL: "https://discordapp.com/api/webhooks/123456789123456789/critical"},
+			{Name: "database", URL: "https://discordapp.com/api/webhooks/123456789123456789/database"},
+		},
+	}, 100*time.Millisecond)
+	assert.NoError(t, err, "creating webhooks")
+
+	SUT := NewRoutingAlertForwarder(webhooks, route)
+
+	w := httptest.NewRecorder()
+	SUT.TransformAndForward(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "http response status code")
+
+	// critical/resolved, database/firing, database/resolved, default/firing
+	assert.Equal(t, 4, len(mockClientRecorder.Requests), "Should have sent one request per webhook and status")
+	assert.Contains(t, mockClientRecorder.Requests[0].Url, "/critical", "first request webhook")
+	assert.Contains(t, mockClientRecorder.Requests[1].Url, "/database", "second request webhook")
+	assert.Contains(t, mockClientRecorder.Requests[2].Url, "/database", "third request webhook")
+	assert.Contains(t, mockClientRecorder.Requests[3].Url, "/default", "fourth request webhook")
+
+	do := readerToDiscordOut(t, mockClientRecorder.Requests[1].Body)
+	assert.Equal(t, 1, len(do.Embeds[0].Fields), "database firing message should contain only the database alert")
+	assert.Equal(t, 10038562, do.Embeds[0].Color, "Discord message embed color")
+}
+
+func Test_TransformAndForward_LargeNotification_IsSplitWithinDiscordLimits(t *testing.T) {
+	ao := alertmanager.Out{}
+	for i := 0; i < 40; i++ {
+		ao.Alerts = append(ao.Alerts, alertmanager.Alert{
+			Status: alertmanager.StatusFiring,
+			Annotations: map[string]string{
+				"description": strings.Repeat("d", 2000),
+			},
+		})
+	}
+
+	mockClientRecorder, res := triggerAndRecordRequest(t, ao, http.StatusOK)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "http response status code")
+	assert.Equal(t, 8, len(mockClientRecorder.Requests), "Should have split the notification across multiple messages")
+
+	fields := 0
+	for i, request := range mockClientRecorder.Requests {
+		do := readerToDiscordOut(t, request.Body)
+		assert.Contains(t, do.Embeds[0].Title, fmt.Sprintf("(part %d/8)", i+1), "Discord message embed title should contain the part marker")
+		for _, embed := range do.Embeds {
+			assert.LessOrEqual(t, len(embed.Fields), discord.LimitFieldsPerEmbed, "Discord message embed fields length")
+			for _, field := range embed.Fields {
+				assert.LessOrEqual(t, len([]rune(field.Value)), discord.LimitFieldValue, "Discord message embed field value length")
+			}
+			fields += len(embed.Fields)
+		}
+	}
+	assert.Equal(t, 40, fields, "all alerts should be sent to Discord")
+}
+
+func Test_TransformAndForward_EditResolved_EditsOriginalMessage(t *testing.T) {
+	type discordRequest struct {
+		method string
+		path   string
+		query  string
+		out    discord.Out
+	}
+	var requests []discordRequest
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, discordRequest{
+			method: r.Method,
+			path:   r.URL.Path,
+			query:  r.URL.RawQuery,
+			out:    readerToDiscordOut(t, r.Body),
+		})
+		if r.Method == http.MethodPost && r.URL.Query().Get("wait") == "true" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id": "a_message_id", "channel_id": "a_channel_id"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer mockDiscordServer.Close()
+
+	webhooks, err := NewWebhooks(&http.Client{}, &config.Config{
+		Webhooks: []config.Webhook{
+			{Name: "default", URL: mockDiscordServer.URL + "/api/webhooks/123/abc", EditResolved: true},
+		},
+	}, 100*time.Millisecond)
+	assert.NoError(t, err, "creating webhooks")
+	SUT := NewRoutingAlertForwarder(webhooks, &routing.Route{Webhook: "default"})
+
+	forward := func(status string) int {
+		ao := alertmanager.Out{
+			GroupKey:     "a_group_key",
+			Status:       status,
+			CommonLabels: map[string]string{"alertname": "an_alert"},
+			Alerts: []alertmanager.Alert{
+				{Status: status, Fingerprint: "a_fingerprint", Labels: map[string]string{"alertname": "an_alert", "severity": "critical"}, EndsAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+			},
+		}
+		aoJson, err := json.Marshal(ao)
+		assert.NoError(t, err, "marshalling alertmanager out")
+
+		w := httptest.NewRecorder()
+		SUT.TransformAndForward(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(aoJson)))
+		return w.Result().StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, forward(alertmanager.StatusFiring), "http response status code of firing notification")
+	assert.Equal(t, http.StatusOK, forward(alertmanager.StatusResolved), "http response status code of resolved notification")
+
+	assert.Equal(t, 2, len(requests), "Should have published one message and edited it")
+	assert.Equal(t, http.MethodPost, requests[0].method, "firing request method")
+	assert.Equal(t, "wait=true", requests[0].query, "firing request should wait for the created message")
+	assert.Equal(t, http.MethodPatch, requests[1].method, "resolved request method")
+	assert.Equal(t, "/api/webhooks/123/abc/messages/a_message_id", requests[1].path, "resolved request should edit the published message")
+	assert.Equal(t, "🔥 [FIRING: 1] an_alert", requests[0].out.Embeds[0].Title, "firing message title")
+	assert.Equal(t, "[RESOLVED: 1] an_alert", requests[1].out.Embeds[0].Title, "edited message should be titled as resolved, without the severity prefix")
+	assert.Equal(t, discord.ColorGreen, requests[1].out.Embeds[0].Color, "edited message embed color")
+	assert.Equal(t, "2024-01-02T03:04:05Z", requests[1].out.Embeds[0].Timestamp, "edited message should show when the alert resolved")
+
+	assert.Equal(t, http.StatusOK, forward(alertmanager.StatusResolved), "http response status code of repeated resolved notification")
+	assert.Equal(t, 3, len(requests), "Should have published a new message once the original has been edited")
+	assert.Equal(t, http.MethodPost, requests[2].method, "repeated resolved request method")
+}
+
+func Test_TransformAndForward_Threads_PostsGroupIntoItsOwnThread(t *testing.T) {
+	type discordRequest struct {
+		query url.Values
+		out   discord.Out
+	}
+	var requests []discordRequest
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, discordRequest{query: r.URL.Query(), out: readerToDiscordOut(t, r.Body)})
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"id": "message_%d", "channel_id": "a_thread_id"}`, len(requests))))
+	}))
+	defer mockDiscordServer.Close()
+
+	webhooks, err := NewWebhooks(&http.Client{}, &config.Config{
+		Webhooks: []config.Webhook{
+			{Name: "default", URL: mockDiscordServer.URL + "/api/webhooks/123/abc", Threads: true},
+		},
+	}, 100*time.Millisecond)
+	assert.NoError(t, err, "creating webhooks")
+	SUT := NewRoutingAlertForwarder(webhooks, &routing.Route{Webhook: "default"})
+
+	forward := func(status string) int {
+		ao := alertmanager.Out{
+			GroupKey:     "a_group_key",
+			Status:       status,
+			Alerts:       []alertmanager.Alert{{Status: status, Labels: map[string]string{"alertname": "an_alert"}}},
+			CommonLabels: map[string]string{"alertname": "an_alert"},
+		}
+		aoJson, err := json.Marshal(ao)
+		assert.NoError(t, err, "marshalling alertmanager out")
+
+		w := httptest.NewRecorder()
+		SUT.TransformAndForward(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(aoJson)))
+		return w.Result().StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, forward(alertmanager.StatusFiring), "http response status code of firing notification")
+	assert.Equal(t, http.StatusOK, forward(alertmanager.StatusResolved), "http response status code of resolved notification")
+
+	assert.Equal(t, 2, len(requests), "Should have sent two requests to Discord")
+	assert.Equal(t, "an_alert", requests[0].out.ThreadName, "first notification should create a thread named from the template")
+	assert.Equal(t, "", requests[0].query.Get("thread_id"), "first notification should not be posted into an existing thread")
+	assert.Equal(t, "", requests[1].out.ThreadName, "subsequent notification should not create another thread")
+	assert.Equal(t, "a_thread_id", requests[1].query.Get("thread_id"), "subsequent notification should be posted into the thread of the group")
+}
+
+func Test_TransformAndForward_Mentions_ArePrependedForMatchingFiringAlerts(t *testing.T) {
+	ao := alertmanager.Out{
+		Alerts: []alertmanager.Alert{
+			{
+				Status:      alertmanager.StatusFiring,
+				Labels:      map[string]string{"severity": "critical", "team": "db"},
+				Annotations: map[string]string{"summary": "@everyone the database is down"},
+			},
+			{
+				Status: alertmanager.StatusResolved,
+				Labels: map[string]string{"severity": "critical"},
+			},
+		},
+	}
+	aoJson, err := json.Marshal(ao)
+	assert.NoError(t, err, "marshalling alertmanager out")
+
+	mockClientRecorder := MockClientRecorder{}
+	mockClient := mockClientRecorder.NewMockClientWithResponse(http.StatusOK)
+
+	webhooks, err := NewWebhooks(mockClient, &config.Config{
+		Webhooks: []config.Webhook{
+			{
+				Name: "default",
+				URL:  "https://discordapp.com/api/webhooks/123456789123456789/default",
+				Mentions: []config.Mention{
+					{Match: map[string]string{"severity": "critical"}, Roles: []string{"111"}},
+					{Matchers: []string{`team=~"db|storage"`}, Users: []string{"222", "333"}},
+					{Match: map[string]string{"team": "web"}, Users: []string{"444"}},
+				},
+			},
+		},
+	}, 100*time.Millisecond)
+	assert.NoError(t, err, "creating webhooks")
+	SUT := NewRoutingAlertForwarder(webhooks, &routing.Route{Webhook: "default"})
+
+	w := httptest.NewRecorder()
+	SUT.TransformAndForward(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(aoJson)))
+	res := w.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "http response status code")
+	assert.Equal(t, 2, len(mockClientRecorder.Requests), "Should have sent one request per status")
+
+	firing := readerToDiscordOut(t, mockClientRecorder.Requests[0].Body)
+	assert.True(t, strings.HasPrefix(firing.Content, "<@&111> <@222> <@333>"), "firing message content should begin with the mentions")
+	assert.Equal(t, &discord.AllowedMentions{Parse: []string{}, Roles: []string{"111"}, Users: []string{"222", "333"}}, firing.AllowedMentions, "only the mentioned roles and users should be notified")
+
+	resolved := readerToDiscordOut(t, mockClientRecorder.Requests[1].Body)
+	assert.NotContains(t, resolved.Content, "<@", "resolved message should not mention anyone")
+	assert.Equal(t, &discord.AllowedMentions{Parse: []string{}}, resolved.AllowedMentions, "nobody should be notified by the resolved message")
+}
+
+func Test_TransformAndForward_Severity_ColoursAndPrefixesByHighestSeverity(t *testing.T) {
+	ao := alertmanager.Out{
+		Alerts: []alertmanager.Alert{
+			{
+				Status: alertmanager.StatusFiring,
+				Labels: map[string]string{"alertname": "DiskFilling", "severity": "info"},
+			},
+			{
+				Status: alertmanager.StatusFiring,
+				Labels: map[string]string{"alertname": "DiskFilling", "severity": "warning"},
+			},
+			{
+				Status: alertmanager.StatusResolved,
+				Labels: map[string]string{"alertname": "DiskFilling", "severity": "critical"},
+			},
+		},
+	}
+
+	mockClientRecorder, res := triggerAndRecordRequest(t, ao, http.StatusOK)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "http response status code")
+	assert.Equal(t, 2, len(mockClientRecorder.Requests), "Should have sent one request per status")
+
+	firing := readerToDiscordOut(t, mockClientRecorder.Requests[0].Body)
+	assert.Equal(t, discord.ColorOrange, firing.Embeds[0].Color, "firing message should be coloured by its highest severity")
+	assert.True(t, strings.HasPrefix(firing.Embeds[0].Title, "⚠️ "), "firing message title should be prefixed by the emoji of its highest severity")
+
+	resolved := readerToDiscordOut(t, mockClientRecorder.Requests[1].Body)
+	assert.Equal(t, discord.ColorGreen, resolved.Embeds[0].Color, "resolved message should be green regardless of its severity")
+	assert.False(t, strings.HasPrefix(resolved.Embeds[0].Title, "🔥"), "resolved message title should not be prefixed")
+}
+
+func Test_TransformAndForward_Severity_UsesTheConfiguredLabelAndLevels(t *testing.T) {
+	ao := alertmanager.Out{
+		Alerts: []alertmanager.Alert{
+			{
+				Status: alertmanager.StatusFiring,
+				Labels: map[string]string{"alertname": "HighLatency", "severity": "critical", "priority": "P2"},
+			},
+		},
+	}
+	aoJson, err := json.Marshal(ao)
+	assert.NoError(t, err, "marshalling alertmanager out")
+
+	mockClientRecorder := MockClientRecorder{}
+	mockClient := mockClientRecorder.NewMockClientWithResponse(http.StatusOK)
+
+	webhooks, err := NewWebhooks(mockClient, &config.Config{
+		Webhooks: []config.Webhook{{Name: "default", URL: "https://discordapp.com/api/webhooks/123456789123456789/default"}},
+		Severity: &config.Severity{
+			Label: "priority",
+			Levels: []config.SeverityLevel{
+				{Name: "P1", Color: "#FF0000", Emoji: "🚨"},
+				{Name: "P2", Color: "0x00FF00", Emoji: "🐢"},
+			},
+		},
+	}, 100*time.Millisecond)
+	assert.NoError(t, err, "creating webhooks")
+	SUT := NewRoutingAlertForwarder(webhooks, &routing.Route{Webhook: "default"})
+
+	w := httptest.NewRecorder()
+	SUT.TransformAndForward(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(aoJson)))
+	res := w.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode, "http response status code")
+	do := readerToDiscordOut(t, mockClientRecorder.Requests[0].Body)
+	assert.Equal(t, 0x00FF00, do.Embeds[0].Color, "message should be coloured by the configured level of the configured label")
+	assert.True(t, strings.HasPrefix(do.Embeds[0].Title, "🐢 "), "message title should be prefixed by the configured emoji")
+}
+
+func Test_NewAlertForwarder_InvalidWebhookURL_ReturnsError(t *testing.T) {
+	_, err := NewAlertForwarder(&http.Client{}, "https://example.com/not-discord", 100*time.Millisecond)
+	assert.Error(t, err, "the url must be that of a Discord webhook")
+	_, err = NewAlertForwarderHandler(&http.Client{}, "", 100*time.Millisecond)
+	assert.Error(t, err, "the url must be provided")
+}
+
+func Test_NewWebhooks_InvalidMention_ReturnsError(t *testing.T) {
+	_, err := NewWebhooks(&http.Client{}, &config.Config{
+		Webhooks: []config.Webhook{
+			{Name: "default", Mentions: []config.Mention{{Roles: []string{"everyone"}}}},
+		},
+	}, 100*time.Millisecond)
+	assert.Error(t, err, "mentions must be Discord ids")
+}
+
+func Test_ReloadWebhooks_UnchangedWebhooks_RetainTheirDiscordClients(t *testing.T) {
+	webhookURL := "https://discord.com/api/webhooks/123/abc"
+	previous, err := NewWebhooks(&http.Client{}, &config.Config{
+		Webhooks: []config.Webhook{{Name: "default", URL: webhookURL}, {Name: "platform", URL: webhookURL + "def"}},
+	}, 100*time.Millisecond)
+	assert.NoError(t, err, "creating webhooks")
+
+	SUT, err := ReloadWebhooks(&http.Client{}, &config.Config{
+		Webhooks: []config.Webhook{{Name: "renamed", URL: webhookURL}, {Name: "platform", URL: webhookURL + "ghi"}},
+	}, 100*time.Millisecond, previous)
+
+	assert.NoError(t, err, "reloading webhooks")
+	assert.Same(t, previous.clients["default"], SUT.clients["renamed"], "the client of an unchanged url should be retained, with its rate limit state")
+	assert.NotSame(t, previous.clients["platform"], SUT.clients["platform"], "a changed url should have a new client")
+	assert.Equal(t, webhookURL+"ghi", SUT.clients["platform"].URL, "url of the new client")
+}
+
 // HELPERS
 
 func triggerAndRecordRequest(t *testing.T, request alertmanager.Out, discordStatusCode int) (mockClientRecorder MockClientRecorder, httpResponse *http.Response) {
@@ -344,7 +943,8 @@ func triggerAndRecordRequest(t *testing.T, request alertmanager.Out, discordStat
 	mockClientRecorder = MockClientRecorder{}
 	mockClient := mockClientRecorder.NewMockClientWithResponse(discordStatusCode)
 
-	SUT := NewAlertForwarder(mockClient, "https://discordapp.com/api/webhooks/123456789123456789/abc", 100*time.Millisecond)
+	SUT, err := NewAlertForwarder(mockClient, "https://discordapp.com/api/webhooks/123456789123456789/abc", 100*time.Millisecond)
+	assert.NoError(t, err, "creating alert forwarder")
 
 	w := httptest.NewRecorder()
 	SUT.TransformAndForward(w, req)
@@ -364,3 +964,67 @@ func readerToDiscordOut(t *testing.T, reader io.Reader) discord.Out {
 	}
 	return do
 }
+
+func Test_TransformAndForward_DispatcherFull_ReturnsServiceUnavailable(t *testing.T) {
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer mockDiscordServer.Close()
+
+	webhooks, err := NewWebhooks(&http.Client{}, &config.Config{
+		Webhooks: []config.Webhook{{Name: "default", URL: mockDiscordServer.URL}},
+	}, 100*time.Millisecond)
+	assert.NoError(t, err, "creating webhooks")
+	// the dispatcher is not run, so the notification remains queued
+	SUT := NewRoutingAlertForwarder(webhooks, &routing.Route{Webhook: "default"}, WithDispatcher(dispatcher.New(1, 1), 15*time.Second))
+
+	aoJson, err := json.Marshal(alertmanager.Out{Alerts: []alertmanager.Alert{{Status: alertmanager.StatusFiring}}})
+	assert.NoError(t, err, "marshalling alertmanager out")
+
+	w := httptest.NewRecorder()
+	SUT.TransformAndForward(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(aoJson)))
+	assert.Equal(t, http.StatusAccepted, w.Code, "dispatched notification should be accepted")
+
+	w = httptest.NewRecorder()
+	SUT.TransformAndForward(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(aoJson)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "notification should be rejected once the dispatcher is full")
+	assert.Equal(t, "15", w.Header().Get("Retry-After"), "client should be asked to retry later")
+}
+
+func Test_Render_ReturnsMessagesWithoutSendingToDiscord(t *testing.T) {
+	mockClientRecorder := MockClientRecorder{}
+	mockClient := mockClientRecorder.NewMockClientWithResponse(http.StatusOK)
+
+	route := &routing.Route{
+		Webhook: "default",
+		Routes:  []*routing.Route{{Webhook: "database", Match: map[string]string{"team": "db"}}},
+	}
+	assert.NoError(t, route.Compile(), "compiling route")
+
+	webhooks, err := NewWebhooks(mockClient, &config.Config{
+		Webhooks: []config.Webhook{
+			{Name: "default", URL: "https://discordapp.com/api/webhooks/123456789123456789/default"},
+			{Name: "database", URL: "https://discordapp.com/api/webhooks/123456789123456789/database"},
+		},
+	}, 100*time.Millisecond)
+	assert.NoError(t, err, "creating webhooks")
+
+	SUT := NewRoutingAlertForwarder(webhooks, route)
+	messages, err := SUT.Render(&alertmanager.Out{
+		GroupKey:     "a_group_key",
+		CommonLabels: map[string]string{"alertname": "an_alert"},
+		Alerts: []alertmanager.Alert{
+			{Status: alertmanager.StatusFiring, Labels: map[string]string{"alertname": "an_alert", "team": "db"}},
+			{Status: alertmanager.StatusResolved, Labels: map[string]string{"alertname": "an_alert", "team": "web"}},
+		},
+	})
+
+	assert.NoError(t, err, "rendering notification")
+	assert.Empty(t, mockClientRecorder.Requests, "rendering should not send anything to Discord")
+	assert.Equal(t, 2, len(messages), "one message per webhook and status")
+	assert.Equal(t, "database", messages[0].Webhook, "first message webhook")
+	assert.Equal(t, "[FIRING: 1] an_alert", messages[0].Out.Embeds[0].Title, "first message title")
+	assert.Equal(t, "default", messages[1].Webhook, "second message webhook")
+	assert.Equal(t, "a_group_key", messages[1].GroupKey, "second message group key")
+
+	_, err = SUT.Render(&alertmanager.Out{Version: "5"})
+	assert.Error(t, err, "unsupported version should not be rendered")
+}
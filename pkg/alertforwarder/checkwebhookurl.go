@@ -7,17 +7,19 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
 	"github.com/specklesystems/alertmanager-discord/pkg/flags"
 )
 
 func CheckWebhookURL(webhookURL string) (bool, *url.URL, error) {
 	if webhookURL == "" {
-		return false, &url.URL{}, fmt.Errorf("'%s' has not been set. This can be provided via configuration file, command line, or environment variable ('%s')", flags.DiscordWebhookUrlFlagKey, strings.ToUpper(flags.DiscordWebhookUrlFlagKey))
+		return false, &url.URL{}, fmt.Errorf("'%s' has not been set. This can be provided via configuration file, command line, or environment variable ('%s'), or read from a file ('%s')", flags.DiscordWebhookUrlFlagKey, strings.ToUpper(flags.DiscordWebhookUrlFlagKey), flags.DiscordWebhookUrlFileFlagKey)
 	}
 
 	parsedUrl, err := url.Parse(webhookURL)
 	if err != nil {
-		return false, &url.URL{}, fmt.Errorf("the Discord WebHook URL ('%s') cannot be parsed as a url: %w", webhookURL, err)
+		// the error of the parser contains the url, and therefore the token
+		return false, &url.URL{}, fmt.Errorf("the Discord WebHook URL ('%s') cannot be parsed as a url", discord.RedactURL(webhookURL))
 	}
 
 	host, _, _ := net.SplitHostPort(parsedUrl.Host)
@@ -34,7 +36,7 @@ func CheckWebhookURL(webhookURL string) (bool, *url.URL, error) {
 
 	ok := re.Match([]byte(webhookURL))
 	if !ok {
-		return false, parsedUrl, fmt.Errorf("the Discord WebHook URL doesn't seem to be a valid Discord Webhook API url: '%s'", webhookURL)
+		return false, parsedUrl, fmt.Errorf("the Discord WebHook URL doesn't seem to be a valid Discord Webhook API url: '%s'", discord.RedactURL(webhookURL))
 	}
 
 	return ok, parsedUrl, nil
@@ -43,3 +43,11 @@ func Test_WebhookUrl_InvalidAPIUrl_ReturnsFalse(t *testing.T) {
 	assert.False(t, ok, "Non-Discord urls should be identified as invalid")
 	assert.Error(t, err, "Non-Discord urls should return an error message")
 }
+
+func Test_WebhookUrl_InvalidUrl_ErrorDoesNotContainToken(t *testing.T) {
+	_, _, err := CheckWebhookURL("https://example.org/api/webhooks/12/a_secret_token")
+	assert.NotContains(t, err.Error(), "a_secret_token", "error should not contain the token of the webhook")
+
+	_, _, err = CheckWebhookURL("https://discord.com/api/webhooks/123456789123456789/a_secret_token\x7f")
+	assert.NotContains(t, err.Error(), "a_secret_token", "error should not contain the token of the webhook")
+}
@@ -0,0 +1,225 @@
+package alertforwarder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultDeduplicationWindow    = 5 * time.Minute
+	DefaultDeduplicationRetention = 24 * time.Hour
+)
+
+// dedupEntry is the most recent notification sent to a webhook for a group of alerts with the same status.
+type dedupEntry struct {
+	Webhook  string    `json:"webhook"`
+	GroupKey string    `json:"groupKey"`
+	Status   string    `json:"status"`
+	LastSent time.Time `json:"lastSent"`
+	// Fingerprints are the fingerprints of the alerts of the notification.
+	Fingerprints []string `json:"fingerprints"`
+	// Repeats is the number of times the notification has been resent by AlertManager, after the window.
+	Repeats int `json:"repeats"`
+}
+
+// observation is the change made to the state of the deduplicator by a notification, so that it can be undone if the notification is not delivered.
+type observation struct {
+	key      string
+	previous *dedupEntry
+	removed  map[string]dedupEntry
+}
+
+// deduplicator drops notifications which have already been sent to a webhook within the window, e.g. by each peer of highly available AlertManagers.
+// Notifications are identified by webhook, group key, status, and the fingerprints of their alerts.
+// If the repeat digest is enabled, notifications repeated after the window, e.g. on AlertManager's repeat interval, are sent as a short reminder.
+type deduplicator struct {
+	window       time.Duration
+	retention    time.Duration
+	repeatDigest bool
+	file         string
+
+	mu      sync.Mutex
+	entries map[string]dedupEntry
+}
+
+// newDeduplicator creates a deduplicator, loading its state from the snapshot file, if any.
+func newDeduplicator(cfg *config.Deduplication) (*deduplicator, error) {
+	d := &deduplicator{
+		window:       DefaultDeduplicationWindow,
+		retention:    DefaultDeduplicationRetention,
+		repeatDigest: cfg.RepeatDigest,
+		file:         cfg.File,
+		entries:      make(map[string]dedupEntry),
+	}
+	if cfg.WindowSeconds > 0 {
+		d.window = time.Duration(cfg.WindowSeconds) * time.Second
+	}
+	if cfg.RetentionSeconds > 0 {
+		d.retention = time.Duration(cfg.RetentionSeconds) * time.Second
+	}
+	if d.file == "" {
+		return d, nil
+	}
+
+	b, err := os.ReadFile(d.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read deduplication state file ('%s'): %w", d.file, err)
+	}
+	if err := json.Unmarshal(b, &d.entries); err != nil {
+		return nil, fmt.Errorf("unable to parse deduplication state file ('%s'): %w", d.file, err)
+	}
+	d.prune(time.Now())
+	return d, nil
+}
+
+// observe records the notification, returning true if it is a duplicate which should be dropped,
+// otherwise the number of times it has been repeated, and the observation with which the change may be undone.
+func (d *deduplicator) observe(webhook, groupKey, status string, alerts []alertmanager.Alert) (bool, int, observation) {
+	key := dedupKey(webhook, groupKey, status, alerts)
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.prune(now)
+	obs := observation{key: key, removed: make(map[string]dedupEntry)}
+	entry, exists := d.entries[key]
+	if exists {
+		if now.Sub(entry.LastSent) < d.window {
+			return true, 0, observation{}
+		}
+		previous := entry
+		obs.previous = &previous
+		entry.Repeats++
+	} else {
+		entry = dedupEntry{Webhook: webhook, GroupKey: groupKey, Status: status, Fingerprints: fingerprints(alerts)}
+	}
+	entry.LastSent = now
+	d.entries[key] = entry
+
+	// once the status of alerts changes, e.g. they resolve, a notification of their previous status is no longer a repeat.
+	// Only notifications sharing alerts are removed, as a notification with both firing and resolved alerts is observed once for each status.
+	for other, otherEntry := range d.entries {
+		if otherEntry.Webhook == webhook && otherEntry.GroupKey == groupKey && otherEntry.Status != status && overlaps(otherEntry.Fingerprints, entry.Fingerprints) {
+			obs.removed[other] = otherEntry
+			delete(d.entries, other)
+		}
+	}
+
+	d.save()
+	return false, entry.Repeats, obs
+}
+
+// undo reverts the observations, so that the notifications are not dropped when AlertManager retries them.
+func (d *deduplicator) undo(observations []observation) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i := len(observations) - 1; i >= 0; i-- {
+		obs := observations[i]
+		if obs.key == "" {
+			continue
+		}
+		if obs.previous != nil {
+			d.entries[obs.key] = *obs.previous
+		} else {
+			delete(d.entries, obs.key)
+		}
+		for key, entry := range obs.removed {
+			d.entries[key] = entry
+		}
+	}
+	d.save()
+}
+
+func (d *deduplicator) prune(now time.Time) {
+	for key, entry := range d.entries {
+		if now.Sub(entry.LastSent) > d.retention {
+			delete(d.entries, key)
+		}
+	}
+}
+
+// save snapshots the state atomically, by writing to a temporary file which is then renamed.
+// The snapshot is best effort; failures are logged, as deduplication continues in memory.
+func (d *deduplicator) save() {
+	if d.file == "" {
+		return
+	}
+
+	b, err := json.Marshal(d.entries)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(d.file), 0o700)
+	}
+	if err == nil {
+		err = os.WriteFile(d.file+".tmp", b, 0o600)
+	}
+	if err == nil {
+		err = os.Rename(d.file+".tmp", d.file)
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Unable to write deduplication state file ('%s').", d.file)
+	}
+}
+
+func dedupKey(webhook, groupKey, status string, alerts []alertmanager.Alert) string {
+	fps := fingerprints(alerts)
+	sort.Strings(fps)
+	return strings.Join([]string{webhook, groupKey, status, strings.Join(fps, ",")}, "\x00")
+}
+
+func overlaps(a, b []string) bool {
+	for _, x := range a {
+		if slices.Contains(b, x) {
+			return true
+		}
+	}
+	return false
+}
+
+// repeatDigest replaces the translated messages of a repeated notification with a short reminder, retaining the title of the first message.
+func repeatDigest(translated []discord.Out, repeats int) []discord.Out {
+	embed := discord.Embed{
+		Description: fmt.Sprintf("Still firing (%s reminder)", ordinal(repeats)),
+		Color:       discord.ColorRed,
+	}
+	if len(translated) > 0 && len(translated[0].Embeds) > 0 {
+		embed.Title = translated[0].Embeds[0].Title
+		embed.Color = translated[0].Embeds[0].Color
+	}
+	return []discord.Out{{Embeds: []discord.Embed{embed}}}
+}
+
+// ordinal formats the number as an English ordinal, e.g. '3rd'.
+func ordinal(n int) string {
+	suffix := "th"
+	switch n % 10 {
+	case 1:
+		suffix = "st"
+	case 2:
+		suffix = "nd"
+	case 3:
+		suffix = "rd"
+	}
+	if n%100 >= 11 && n%100 <= 13 {
+		suffix = "th"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
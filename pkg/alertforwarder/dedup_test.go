@@ -0,0 +1,150 @@
+package alertforwarder
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+	"github.com/specklesystems/alertmanager-discord/pkg/routing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TransformAndForward_Deduplication_DropsDuplicatesWithinWindow(t *testing.T) {
+	var requests []discord.Out
+	statusCode := http.StatusInternalServerError
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, readerToDiscordOut(t, r.Body))
+		w.WriteHeader(statusCode)
+	}))
+	defer mockDiscordServer.Close()
+
+	SUT := newDeduplicatingAlertForwarder(t, mockDiscordServer.URL, &config.Deduplication{})
+
+	assert.Equal(t, http.StatusInternalServerError, forwardNotification(t, SUT, alertmanager.StatusFiring), "failed notification")
+	failedRequests := len(requests)
+	statusCode = http.StatusOK
+	assert.Equal(t, http.StatusOK, forwardNotification(t, SUT, alertmanager.StatusFiring), "retry of failed notification")
+	assert.Greater(t, len(requests), failedRequests, "retry of a failed notification should not be dropped as a duplicate")
+
+	sentRequests := len(requests)
+	assert.Equal(t, http.StatusOK, forwardNotification(t, SUT, alertmanager.StatusFiring), "duplicate notification")
+	assert.Equal(t, sentRequests, len(requests), "duplicate notification, e.g. from another AlertManager peer, should be dropped")
+
+	assert.Equal(t, http.StatusOK, forwardNotification(t, SUT, alertmanager.StatusResolved), "resolved notification")
+	assert.Equal(t, sentRequests+1, len(requests), "notification with a different status should be sent")
+}
+
+func Test_TransformAndForward_Deduplication_MixedStatus_DropsDuplicates(t *testing.T) {
+	var requests []discord.Out
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, readerToDiscordOut(t, r.Body))
+	}))
+	defer mockDiscordServer.Close()
+
+	SUT := newDeduplicatingAlertForwarder(t, mockDiscordServer.URL, &config.Deduplication{})
+	aoJson, err := json.Marshal(alertmanager.Out{
+		GroupKey: "a_group_key",
+		Status:   alertmanager.StatusFiring,
+		Alerts: []alertmanager.Alert{
+			{Status: alertmanager.StatusFiring, Fingerprint: "a_fingerprint", Labels: map[string]string{"alertname": "an_alert"}},
+			{Status: alertmanager.StatusResolved, Fingerprint: "another_fingerprint", Labels: map[string]string{"alertname": "an_alert"}},
+		},
+		CommonLabels: map[string]string{"alertname": "an_alert"},
+	})
+	assert.NoError(t, err, "marshalling alertmanager out")
+
+	w := httptest.NewRecorder()
+	SUT.TransformAndForward(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(aoJson)))
+	assert.Equal(t, http.StatusOK, w.Code, "mixed notification")
+	sentRequests := len(requests)
+	assert.Equal(t, 2, sentRequests, "the firing and resolved alerts should each be sent")
+
+	// the same notification from another AlertManager peer
+	w = httptest.NewRecorder()
+	SUT.TransformAndForward(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(aoJson)))
+	assert.Equal(t, http.StatusOK, w.Code, "duplicate mixed notification")
+	assert.Equal(t, sentRequests, len(requests), "neither the firing nor the resolved alerts of a duplicate notification should be sent")
+}
+
+func Test_TransformAndForward_Deduplication_RepeatDigest(t *testing.T) {
+	var requests []discord.Out
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, readerToDiscordOut(t, r.Body))
+	}))
+	defer mockDiscordServer.Close()
+
+	file := filepath.Join(t.TempDir(), "dedup.json")
+	SUT := newDeduplicatingAlertForwarder(t, mockDiscordServer.URL, &config.Deduplication{RepeatDigest: true, File: file})
+
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusOK, forwardNotification(t, SUT, alertmanager.StatusFiring), "firing notification")
+		// simulates AlertManager's repeat interval having elapsed
+		expireWindow(SUT.webhooks.dedup)
+	}
+
+	assert.Equal(t, 4, len(requests), "repeated notifications after the window should be sent")
+	assert.Equal(t, "[FIRING: 1] an_alert", requests[0].Embeds[0].Title, "first notification should be sent in full")
+	assert.NotEmpty(t, requests[0].Embeds[0].Fields, "first notification should be sent in full")
+	assert.Equal(t, "[FIRING: 1] an_alert", requests[3].Embeds[0].Title, "reminder should retain the title")
+	assert.Equal(t, "Still firing (3rd reminder)", requests[3].Embeds[0].Description, "reminder should count the repeats")
+	assert.Empty(t, requests[3].Embeds[0].Fields, "reminder should not include the alerts")
+
+	// the state is restored from the snapshot after a restart
+	restarted := newDeduplicatingAlertForwarder(t, mockDiscordServer.URL, &config.Deduplication{RepeatDigest: true, File: file})
+	assert.Equal(t, http.StatusOK, forwardNotification(t, restarted, alertmanager.StatusFiring), "firing notification after restart")
+	assert.Equal(t, "Still firing (4th reminder)", requests[4].Embeds[0].Description, "reminder count should survive a restart")
+
+	expireWindow(restarted.webhooks.dedup)
+	assert.Equal(t, http.StatusOK, forwardNotification(t, restarted, alertmanager.StatusResolved), "resolved notification")
+	assert.Equal(t, http.StatusOK, forwardNotification(t, restarted, alertmanager.StatusFiring), "firing notification after resolving")
+	assert.NotEmpty(t, requests[6].Embeds[0].Fields, "notification firing again after resolving should be sent in full")
+}
+
+func Test_Ordinal(t *testing.T) {
+	for n, expected := range map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th", 21: "21st", 102: "102nd", 111: "111th"} {
+		assert.Equal(t, expected, ordinal(n), "ordinal of %d", n)
+	}
+}
+
+func newDeduplicatingAlertForwarder(t *testing.T, url string, dedup *config.Deduplication) AlertForwarder {
+	t.Helper()
+	webhooks, err := NewWebhooks(&http.Client{}, &config.Config{
+		Webhooks:      []config.Webhook{{Name: "default", URL: url}},
+		Deduplication: dedup,
+	}, 100*time.Millisecond)
+	assert.NoError(t, err, "creating webhooks")
+	return NewRoutingAlertForwarder(webhooks, &routing.Route{Webhook: "default"})
+}
+
+func forwardNotification(t *testing.T, SUT AlertForwarder, status string) int {
+	t.Helper()
+	aoJson, err := json.Marshal(alertmanager.Out{
+		GroupKey:     "a_group_key",
+		Status:       status,
+		Alerts:       []alertmanager.Alert{{Status: status, Fingerprint: "a_fingerprint", Labels: map[string]string{"alertname": "an_alert"}}},
+		CommonLabels: map[string]string{"alertname": "an_alert"},
+	})
+	assert.NoError(t, err, "marshalling alertmanager out")
+
+	w := httptest.NewRecorder()
+	SUT.TransformAndForward(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(aoJson)))
+	return w.Code
+}
+
+func expireWindow(d *deduplicator) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key, entry := range d.entries {
+		entry.LastSent = entry.LastSent.Add(-d.window)
+		d.entries[key] = entry
+	}
+	d.save()
+}
@@ -0,0 +1,191 @@
+package alertforwarder
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+	"github.com/specklesystems/alertmanager-discord/pkg/logging"
+	"github.com/specklesystems/alertmanager-discord/pkg/routing"
+
+	"github.com/rs/zerolog/log"
+)
+
+// grouper buffers the alerts of senders which do not group alerts themselves, e.g. Prometheus, by alertname,
+// so that the alerts of each alertname which arrive within the group wait are forwarded together in a single notification.
+// As Prometheus sends every firing alert again on each evaluation, only the alerts whose status has changed since they were last forwarded are forwarded.
+// Groups which are pending when the process exits are lost; Prometheus sends its alerts again shortly after.
+type grouper struct {
+	mu     sync.Mutex
+	groups map[string]*pendingGroup
+	// sent is shared by the groupers of all forwarders, so that it is retained when the configuration is reloaded
+	sent *sentAlerts
+	// route selects the webhooks of each alert, by which its forwarded status is recorded
+	route   *routing.Route
+	forward func(correlationId string, amo *alertmanager.Out) int
+}
+
+// sentAlerts are the alerts which have been forwarded, by the webhooks to which they are routed, alertname, and fingerprint.
+type sentAlerts struct {
+	mu     sync.Mutex
+	alerts map[string]sentAlert
+}
+
+// sentAlert is the status with which an alert was last forwarded, and when it was last received.
+type sentAlert struct {
+	status string
+	seen   time.Time
+}
+
+// sentRetention is how long a forwarded alert is remembered after it was last received.
+// Prometheus sends firing alerts again every minute, and resolved alerts for 15 minutes, so an alert which returns after this time is forwarded again.
+const sentRetention = time.Hour
+
+// pendingGroup holds the alerts of an alertname which are waiting to be forwarded, by fingerprint, so that an alert sent again replaces its earlier state.
+type pendingGroup struct {
+	// correlationId is that of the request which began the group
+	correlationId string
+	fingerprints  []string
+	alerts        map[string]alertmanager.Alert
+}
+
+func newGrouper(sent *sentAlerts, route *routing.Route, forward func(correlationId string, amo *alertmanager.Out) int) *grouper {
+	return &grouper{
+		groups:  make(map[string]*pendingGroup),
+		sent:    sent,
+		route:   route,
+		forward: forward,
+	}
+}
+
+func newSentAlerts() *sentAlerts {
+	return &sentAlerts{alerts: make(map[string]sentAlert)}
+}
+
+// add buffers the alerts of the notification. The group of each alertname is forwarded once the wait has elapsed since its first alert was added.
+func (g *grouper) add(correlationId string, amo *alertmanager.Out, wait time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, alert := range amo.Alerts {
+		alertname := alert.Labels["alertname"]
+		group, exists := g.groups[alertname]
+		if !exists {
+			group = &pendingGroup{correlationId: correlationId, alerts: make(map[string]alertmanager.Alert)}
+			g.groups[alertname] = group
+			time.AfterFunc(wait, func() { g.flush(alertname, amo.Receiver) })
+		}
+
+		group.put(alert)
+	}
+}
+
+// put adds the alert to the group, replacing the earlier state of the same alert, if any.
+func (p *pendingGroup) put(alert alertmanager.Alert) {
+	fingerprint := AlertFingerprint(alert)
+	if _, seen := p.alerts[fingerprint]; !seen {
+		p.fingerprints = append(p.fingerprints, fingerprint)
+	}
+	p.alerts[fingerprint] = alert
+}
+
+// GroupByAlertName splits the notification into a notification per alertname, in the order in which each alertname first appears,
+// as the alerts of formats which are not grouped by their sender, e.g. Prometheus, are grouped before they are forwarded.
+func GroupByAlertName(amo *alertmanager.Out) []*alertmanager.Out {
+	var alertnames []string
+	groups := make(map[string]*pendingGroup)
+	for _, alert := range amo.Alerts {
+		alertname := alert.Labels["alertname"]
+		group, exists := groups[alertname]
+		if !exists {
+			group = &pendingGroup{alerts: make(map[string]alertmanager.Alert)}
+			groups[alertname] = group
+			alertnames = append(alertnames, alertname)
+		}
+		group.put(alert)
+	}
+
+	outs := make([]*alertmanager.Out, 0, len(alertnames))
+	for _, alertname := range alertnames {
+		group := groups[alertname]
+		alerts := make([]alertmanager.Alert, 0, len(group.fingerprints))
+		for _, fingerprint := range group.fingerprints {
+			alerts = append(alerts, group.alerts[fingerprint])
+		}
+		outs = append(outs, newAlertNameOut(amo.Receiver, alerts))
+	}
+	return outs
+}
+
+func newAlertNameOut(receiver string, alerts []alertmanager.Alert) *alertmanager.Out {
+	return alertmanager.NewOut(receiver, []string{"alertname"}, alerts)
+}
+
+// flush forwards the alerts of the pending group of the alertname whose status has changed since they were last forwarded.
+// The notification is not retried if it cannot be forwarded, as the sender repeats its alerts.
+func (g *grouper) flush(alertname, receiver string) {
+	now := time.Now()
+	g.mu.Lock()
+	group, exists := g.groups[alertname]
+	delete(g.groups, alertname)
+	g.mu.Unlock()
+	if !exists {
+		return
+	}
+
+	var changed []alertmanager.Alert
+	g.sent.mu.Lock()
+	for _, fingerprint := range group.fingerprints {
+		alert := group.alerts[fingerprint]
+		key := g.sentKey(alertname, alert)
+		if sent, ok := g.sent.alerts[key]; ok && sent.status == alert.Status {
+			g.sent.alerts[key] = sentAlert{status: sent.status, seen: now}
+			continue
+		}
+		changed = append(changed, alert)
+	}
+	g.sent.prune(now)
+	g.sent.mu.Unlock()
+	if len(changed) == 0 {
+		log.Debug().
+			Str(logging.FieldKeyCorrelationId, group.correlationId).
+			Str(logging.FieldKeyAlertName, alertname).
+			Msgf("The group of %d alerts has already been forwarded.", len(group.fingerprints))
+		return
+	}
+
+	status := g.forward(group.correlationId, newAlertNameOut(receiver, changed))
+	if status < 200 || status > 299 {
+		log.Error().
+			Str(logging.FieldKeyCorrelationId, group.correlationId).
+			Str(logging.FieldKeyAlertName, alertname).
+			Int(logging.FieldKeyStatusCode, status).
+			Msgf("Unable to forward the group of %d alerts to Discord.", len(changed))
+		return
+	}
+
+	g.sent.mu.Lock()
+	for _, alert := range changed {
+		g.sent.alerts[g.sentKey(alertname, alert)] = sentAlert{status: alert.Status, seen: now}
+	}
+	g.sent.mu.Unlock()
+	log.Debug().
+		Str(logging.FieldKeyCorrelationId, group.correlationId).
+		Str(logging.FieldKeyAlertName, alertname).
+		Msgf("Forwarded %d of the group of %d alerts.", len(changed), len(group.fingerprints))
+}
+
+// prune forgets the forwarded alerts which have not been received within the retention. The lock must be held.
+func (s *sentAlerts) prune(now time.Time) {
+	for key, sent := range s.alerts {
+		if now.Sub(sent.seen) > sentRetention {
+			delete(s.alerts, key)
+		}
+	}
+}
+
+// sentKey identifies the alert by the webhooks to which it is routed, so that an alert routed to other webhooks, e.g. by another receiver, is forwarded to them.
+func (g *grouper) sentKey(alertname string, alert alertmanager.Alert) string {
+	return strings.Join(g.route.Select(alert.Labels), ",") + "\x00" + alertname + "\x00" + AlertFingerprint(alert)
+}
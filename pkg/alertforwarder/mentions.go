@@ -0,0 +1,65 @@
+package alertforwarder
+
+import (
+	"strings"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+)
+
+// applyMentions prepends the roles and users of each mention matching any of the alerts to the content of the first message.
+// All messages are restricted to notifying only those roles and users, so that mentions within the alerts themselves (e.g. '@everyone' within an annotation) notify nobody.
+func applyMentions(messages []discord.Out, mentions []config.Mention, alerts []alertmanager.Alert) []discord.Out {
+	allowed := &discord.AllowedMentions{Parse: []string{}}
+	seen := make(map[string]bool)
+	for _, mention := range mentions {
+		if !anyAlertMatches(mention, alerts) {
+			continue
+		}
+		for _, role := range mention.Roles {
+			if !seen["role:"+role] {
+				seen["role:"+role] = true
+				allowed.Roles = append(allowed.Roles, role)
+			}
+		}
+		for _, user := range mention.Users {
+			if !seen["user:"+user] {
+				seen["user:"+user] = true
+				allowed.Users = append(allowed.Users, user)
+			}
+		}
+	}
+
+	for i := range messages {
+		messages[i].AllowedMentions = allowed
+	}
+
+	if len(messages) == 0 || (len(allowed.Roles) == 0 && len(allowed.Users) == 0) {
+		return messages
+	}
+
+	tags := make([]string, 0, len(allowed.Roles)+len(allowed.Users))
+	for _, role := range allowed.Roles {
+		tags = append(tags, "<@&"+role+">")
+	}
+	for _, user := range allowed.Users {
+		tags = append(tags, "<@"+user+">")
+	}
+	content := strings.Join(tags, " ")
+	if messages[0].Content != "" {
+		content += " " + messages[0].Content
+	}
+	messages[0].Content = discord.Truncate(content, discord.LimitContent)
+
+	return messages
+}
+
+func anyAlertMatches(mention config.Mention, alerts []alertmanager.Alert) bool {
+	for _, alert := range alerts {
+		if mention.Matches(alert.Labels) {
+			return true
+		}
+	}
+	return false
+}
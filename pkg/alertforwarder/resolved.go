@@ -0,0 +1,151 @@
+package alertforwarder
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+	"github.com/specklesystems/alertmanager-discord/pkg/internal/keys"
+	"github.com/specklesystems/alertmanager-discord/pkg/queue"
+	"github.com/specklesystems/alertmanager-discord/pkg/templates"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	resolvedFooterText = "Resolved"
+)
+
+// partMarker is appended by discord.Split to the title of each message of a split notification.
+var partMarker = regexp.MustCompile(` \(part \d+/\d+\)$`)
+
+// editResolvedMessages finds the messages previously published for the resolved alerts of the notification.
+// Messages in which all alerts have now resolved are returned as edits, titled as resolved. Any alerts which remain are returned, to be published as a new message.
+func (af *AlertForwarder) editResolvedMessages(logger zerolog.Logger, webhook string, amo *alertmanager.Out, alerts []alertmanager.Alert) ([]queue.Message, []alertmanager.Alert) {
+	groupKey := amo.GroupKey
+	var remaining []alertmanager.Alert
+	states := make(map[string]discord.MessageState)
+	alertsByMessage := make(map[string][]alertmanager.Alert)
+
+	for _, alert := range alerts {
+		fingerprint := AlertFingerprint(alert)
+		state, ok := af.webhooks.messages.Get(webhook, groupKey, fingerprint)
+		if !ok {
+			remaining = append(remaining, alert)
+			continue
+		}
+		if previous, ok := states[state.MessageID]; ok {
+			state = previous
+		}
+		// the stored state may share its backing array with that of other notifications, so it is copied before being appended to
+		state.Resolved = append(slices.Clone(state.Resolved), fingerprint)
+		states[state.MessageID] = state
+		alertsByMessage[state.MessageID] = append(alertsByMessage[state.MessageID], alert)
+	}
+
+	ids := make([]string, 0, len(states))
+	for id := range states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var edits []queue.Message
+	for _, id := range ids {
+		state := states[id]
+		if !state.IsResolved() {
+			// other alerts within the message are still firing, so a new message is published for these alerts
+			if err := af.webhooks.messages.Put(state); err != nil {
+				logger.Error().Err(err).Msg("Unable to record the resolved alerts in the message state.")
+			}
+			remaining = append(remaining, alertsByMessage[id]...)
+			continue
+		}
+
+		title, err := af.webhooks.templates[webhook].Execute(templates.NameTitle, templates.NewData(alertmanager.StatusResolved, amo, alertsByMessage[id]))
+		if err != nil {
+			// a broken template should not prevent the message from being marked as resolved
+			logger.Error().Err(err).Msg("Unable to render the title of the resolved message. Falling back to the default templates.")
+			if title, err = templates.Default().Execute(templates.NameTitle, templates.NewData(alertmanager.StatusResolved, amo, alertsByMessage[id])); err != nil {
+				logger.Error().Err(err).Msg("Unable to render the title of the resolved message with the default templates. Retaining the original title.")
+				title = ""
+			}
+		}
+
+		edits = append(edits, queue.Message{
+			Webhook:       webhook,
+			GroupKey:      groupKey,
+			EditMessageID: id,
+			Out:           resolvedMessage(state.Message, title, resolvedAt(alertsByMessage[id])),
+		})
+	}
+
+	return edits, remaining
+}
+
+// resolvedMessage returns a copy of the message, with its embeds coloured green, titled as resolved, and marked with the time at which the alerts resolved.
+// The title replaces the title of each embed, retaining any part marker of a split message; the original titles are retained if it is empty.
+func resolvedMessage(message discord.Out, title string, at time.Time) discord.Out {
+	resolved := discord.Out{
+		Content: message.Content,
+		Embeds:  make([]discord.Embed, 0, len(message.Embeds)),
+		// the mentions of the original message have already notified their roles and users
+		AllowedMentions: &discord.AllowedMentions{Parse: []string{}},
+	}
+	for _, embed := range message.Embeds {
+		embed.Color = discord.ColorGreen
+		if title != "" && embed.Title != "" {
+			// the original title, e.g. '🔥 [FIRING: 1] ...', would otherwise suggest that the alerts are still firing
+			marker := partMarker.FindString(embed.Title)
+			embed.Title = discord.Truncate(title, discord.LimitEmbedTitle-utf8.RuneCountInString(marker)) + marker
+		}
+		embed.Timestamp = at.UTC().Format(time.RFC3339)
+		embed.Footer = &discord.EmbedFooter{Text: resolvedFooterText}
+		resolved.Embeds = append(resolved.Embeds, embed)
+	}
+	return resolved
+}
+
+// resolvedAt returns the latest time at which the alerts ended, or the current time if it is not known.
+func resolvedAt(alerts []alertmanager.Alert) time.Time {
+	var latest time.Time
+	for _, alert := range alerts {
+		if alert.EndsAt.After(latest) {
+			latest = alert.EndsAt
+		}
+	}
+	if latest.IsZero() {
+		return time.Now()
+	}
+	return latest
+}
+
+func fingerprints(alerts []alertmanager.Alert) []string {
+	fingerprints := make([]string, 0, len(alerts))
+	for _, alert := range alerts {
+		fingerprints = append(fingerprints, AlertFingerprint(alert))
+	}
+	return fingerprints
+}
+
+// AlertFingerprint returns the fingerprint provided by AlertManager, by which alerts are identified within the message and deduplication state.
+// Older versions of AlertManager do not provide a fingerprint, so one is derived from the labels of the alert.
+func AlertFingerprint(alert alertmanager.Alert) string {
+	if alert.Fingerprint != "" {
+		return alert.Fingerprint
+	}
+
+	h := fnv.New64a()
+	for _, name := range keys.Sorted(alert.Labels) {
+		_, _ = h.Write([]byte(name))
+		_, _ = h.Write([]byte{0xff})
+		_, _ = h.Write([]byte(alert.Labels[name]))
+		_, _ = h.Write([]byte{0xff})
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
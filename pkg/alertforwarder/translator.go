@@ -1,30 +1,45 @@
 package alertforwarder
 
 import (
-	"fmt"
-	"sort"
 	"strings"
 
 	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
 	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+	"github.com/specklesystems/alertmanager-discord/pkg/severity"
+	"github.com/specklesystems/alertmanager-discord/pkg/silence"
+	"github.com/specklesystems/alertmanager-discord/pkg/templates"
 )
 
-const (
-	keySummary         = "summary"
-	keyEnvironmentType = "source_environment_type"
-	keyEnvironmentName = "source_environment_name"
-)
+// TranslateAlertManagerToDiscord renders the alerts, all of which share the same status, as Discord messages.
+// If tmpl is nil, the default templates are used. If signer is not nil, each alert links to the silence endpoint of this service.
+// Firing alerts are coloured, and their title prefixed, by the highest of their severities; if severities is nil, the default severities are used.
+// More than one message is returned if the rendered message would exceed Discord's limits.
+func TranslateAlertManagerToDiscord(status string, amo *alertmanager.Out, alerts []alertmanager.Alert, tmpl *templates.Template, signer *silence.Signer, severities *severity.Severities) ([]discord.Out, error) {
+	if tmpl == nil {
+		tmpl = templates.Default()
+	}
+	if severities == nil {
+		severities = severity.Default()
+	}
 
-func TranslateAlertManagerToDiscord(status string, amo *alertmanager.Out, alerts []alertmanager.Alert) discord.Out {
-	DO := discord.Out{}
+	data := templates.NewData(status, amo, alerts)
 
-	if amo.CommonAnnotations.Summary != "" {
-		DO.Content = fmt.Sprintf(" === %s === \n", amo.CommonAnnotations.Summary)
+	content, err := tmpl.Execute(templates.NameContent, data)
+	if err != nil {
+		return nil, err
+	}
+	title, err := tmpl.Execute(templates.NameTitle, data)
+	if err != nil {
+		return nil, err
+	}
+	description, err := tmpl.Execute(templates.NameDescription, data)
+	if err != nil {
+		return nil, err
 	}
 
 	RichEmbed := discord.Embed{
-		Title:       fmt.Sprintf("[%s: %d] %s", strings.ToUpper(status), len(alerts), amo.CommonLabels.Alertname),
-		Description: amo.CommonAnnotations.Summary,
+		Title:       title,
+		Description: description,
 		Color:       discord.ColorGrey,
 		Fields:      []discord.EmbedField{},
 	}
@@ -32,58 +47,117 @@ func TranslateAlertManagerToDiscord(status string, amo *alertmanager.Out, alerts
 	switch status {
 	case alertmanager.StatusFiring:
 		RichEmbed.Color = discord.ColorRed
+		if level, ok := severities.Highest(alerts); ok {
+			RichEmbed.Color = level.Color
+			if level.Emoji != "" {
+				RichEmbed.Title = level.Emoji + " " + RichEmbed.Title
+			}
+		}
 	case alertmanager.StatusResolved:
+		// resolved alerts are green regardless of their severity, so that they are not mistaken for firing alerts
 		RichEmbed.Color = discord.ColorGreen
 	}
 
-	for _, alert := range alerts {
-		fieldName := fmt.Sprintf("[%s/%s] Alert details", alert.Labels[keyEnvironmentType], alert.Labels[keyEnvironmentName])
-		if summary, ok := alert.Annotations[keySummary]; ok {
-			fieldName = fmt.Sprintf("[%s/%s] %s", alert.Labels[keyEnvironmentType], alert.Labels[keyEnvironmentName], summary)
-		}
+	// only Grafana sends dashboards and images
+	RichEmbed.URL = commonDashboardURL(alerts)
+	if imageURL := firstImageURL(alerts); imageURL != "" {
+		RichEmbed.Image = &discord.EmbedImage{URL: imageURL}
+	}
 
-		var details strings.Builder
-		details.WriteString("Annotations:\n")
+	for _, alert := range alerts {
+		fieldData := newFieldData(data, amo, alert, signer)
 
-		// sort into alphabetical order
-		annotationKeys := make([]string, 0, len(alert.Annotations))
-		for key := range alert.Annotations {
-			annotationKeys = append(annotationKeys, key)
+		fieldName, err := tmpl.Execute(templates.NameFieldName, fieldData)
+		if err != nil {
+			return nil, err
 		}
-		sort.Strings(annotationKeys)
-
-		for _, key := range annotationKeys {
-			if key == keySummary {
-				// if there is a summary, it is already the field name so no need to repeat it
-				continue
-			}
-			details.WriteString(fmt.Sprintf("\t%s: %s\n", key, alert.Annotations[key]))
+		fieldValue, err := tmpl.Execute(templates.NameFieldValue, fieldData)
+		if err != nil {
+			return nil, err
 		}
 
-		details.WriteString("Labels:\n")
+		RichEmbed.Fields = append(RichEmbed.Fields, discord.EmbedField{
+			Name:  fieldName,
+			Value: fieldValue,
+		})
+	}
+
+	return discord.Split(discord.Out{
+		Content: content,
+		Embeds:  []discord.Embed{RichEmbed},
+	}), nil
+}
 
-		// sort into alphabetical order
-		labelKeys := make([]string, 0, len(alert.Labels))
-		for key := range alert.Labels {
-			labelKeys = append(labelKeys, key)
+// commonDashboardURL returns the dashboard of the alerts, if they all share the same one.
+func commonDashboardURL(alerts []alertmanager.Alert) string {
+	if len(alerts) == 0 {
+		return ""
+	}
+	for _, alert := range alerts[1:] {
+		if alert.DashboardURL != alerts[0].DashboardURL {
+			return ""
 		}
-		sort.Strings(labelKeys)
+	}
+	return alerts[0].DashboardURL
+}
 
-		for _, key := range labelKeys {
-			if key == keyEnvironmentName || key == keyEnvironmentType {
-				// if these keys exist, we have already added them to the field name
-				continue
-			}
-			details.WriteString(fmt.Sprintf("\t%s: %s\n", key, alert.Labels[key]))
+// firstImageURL returns the image of the first alert which has one, as an embed displays a single image.
+func firstImageURL(alerts []alertmanager.Alert) string {
+	for _, alert := range alerts {
+		if alert.ImageURL != "" {
+			return alert.ImageURL
 		}
+	}
+	return ""
+}
 
-		RichEmbed.Fields = append(RichEmbed.Fields, discord.EmbedField{
-			Name:  fieldName,
-			Value: details.String(),
-		})
+func newFieldData(data templates.Data, amo *alertmanager.Out, alert alertmanager.Alert, signer *silence.Signer) templates.FieldData {
+	fieldData := templates.FieldData{
+		Data:       data,
+		Alert:      alert,
+		SilenceURL: alert.SilenceURL,
+	}
+	if fieldData.SilenceURL == "" {
+		fieldData.SilenceURL = silence.AlertManagerURL(amo.ExternalURL, alert.Labels)
+	}
+	// resolved alerts do not need to be silenced, and Grafana's alerts cannot be silenced within AlertManager
+	if signer != nil && alert.Status != alertmanager.StatusResolved && len(alert.Labels) > 0 && !amo.IsGrafana() {
+		fieldData.QuickSilenceURL = signer.Link(alert.Labels)
 	}
 
-	DO.Embeds = []discord.Embed{RichEmbed}
+	if alert.GeneratorURL != "" {
+		fieldData.Links = append(fieldData.Links, templates.Link{Text: "Source", URL: alert.GeneratorURL})
+	}
+	if alert.DashboardURL != "" {
+		fieldData.Links = append(fieldData.Links, templates.Link{Text: "Dashboard", URL: alert.DashboardURL})
+	}
+	if alert.PanelURL != "" {
+		fieldData.Links = append(fieldData.Links, templates.Link{Text: "Panel", URL: alert.PanelURL})
+	}
+	if fieldData.SilenceURL != "" && alert.Status != alertmanager.StatusResolved {
+		fieldData.Links = append(fieldData.Links, templates.Link{Text: "Silence", URL: fieldData.SilenceURL})
+	}
+	if fieldData.QuickSilenceURL != "" {
+		fieldData.Links = append(fieldData.Links, templates.Link{Text: "Quick silence", URL: fieldData.QuickSilenceURL})
+	}
+	return fieldData
+}
+
+// TranslateThreadName renders the name of the thread in which the alerts of the group are posted.
+// If tmpl is nil, the default templates are used.
+func TranslateThreadName(status string, amo *alertmanager.Out, alerts []alertmanager.Alert, tmpl *templates.Template) (string, error) {
+	if tmpl == nil {
+		tmpl = templates.Default()
+	}
 
-	return DO
+	name, err := tmpl.Execute(templates.NameThreadName, templates.NewData(status, amo, alerts))
+	if err != nil {
+		return "", err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		// Discord rejects threads without a name
+		name = "Alerts"
+	}
+	return discord.Truncate(name, discord.LimitThreadName), nil
 }
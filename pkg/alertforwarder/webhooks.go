@@ -0,0 +1,222 @@
+package alertforwarder
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+	"github.com/specklesystems/alertmanager-discord/pkg/logging"
+	"github.com/specklesystems/alertmanager-discord/pkg/queue"
+	"github.com/specklesystems/alertmanager-discord/pkg/severity"
+	"github.com/specklesystems/alertmanager-discord/pkg/templates"
+
+	backoff "github.com/cenkalti/backoff/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// Webhooks holds the Discord client and templates of each configured webhook, and the state of the messages they have published.
+// It is shared by all forwarders, so that each webhook has a single client and rate limit state.
+type Webhooks struct {
+	// configs, clients, and templates are keyed by webhook name
+	configs   map[string]config.Webhook
+	clients   map[string]*discord.Client
+	templates map[string]*templates.Template
+	mentions  map[string][]config.Mention
+	// severities colour the notifications of all webhooks
+	severities *severity.Severities
+	messages   discord.MessageStore
+	// dedup is nil unless deduplication has been configured
+	dedup *deduplicator
+	// sent is the alerts which have been forwarded by the groupers of formats which are not grouped by their sender
+	sent *sentAlerts
+}
+
+// NewWebhooks creates a Discord client for each webhook, parses its templates and mentions, and opens the message and deduplication state.
+// An error is returned if the template files or mentions of any webhook cannot be parsed, or the state cannot be loaded.
+func NewWebhooks(client *http.Client, cfg *config.Config, maximumBackoffElapsedTime time.Duration) (*Webhooks, error) {
+	return ReloadWebhooks(client, cfg, maximumBackoffElapsedTime, nil)
+}
+
+// ReloadWebhooks creates the webhooks as NewWebhooks does, but shares the message, deduplication, and forwarded alert state of the previous webhooks, if any,
+// so that the state is retained when the configuration is reloaded.
+// The Discord clients of webhooks whose url and backoff are unchanged are also retained, so that their rate limit state is not lost.
+func ReloadWebhooks(client *http.Client, cfg *config.Config, maximumBackoffElapsedTime time.Duration, previous *Webhooks) (*Webhooks, error) {
+	webhooks := &Webhooks{
+		configs:   make(map[string]config.Webhook, len(cfg.Webhooks)),
+		clients:   make(map[string]*discord.Client, len(cfg.Webhooks)),
+		templates: make(map[string]*templates.Template, len(cfg.Webhooks)),
+		mentions:  make(map[string][]config.Mention, len(cfg.Webhooks)),
+		messages:  discord.NewMemoryMessageStore(discord.DefaultMessageStateTTL),
+		sent:      newSentAlerts(),
+	}
+
+	severities, err := cfg.Severities()
+	if err != nil {
+		return nil, err
+	}
+	webhooks.severities = severities
+
+	if previous != nil {
+		webhooks.messages = previous.messages
+		webhooks.dedup = previous.dedup
+		webhooks.sent = previous.sent
+	} else if cfg.MessageState != nil {
+		ttl := time.Duration(cfg.MessageState.TTLSeconds) * time.Second
+		if cfg.MessageState.File == "" {
+			webhooks.messages = discord.NewMemoryMessageStore(ttl)
+		} else {
+			store, err := discord.OpenFileMessageStore(cfg.MessageState.File, ttl)
+			if err != nil {
+				return nil, err
+			}
+			webhooks.messages = store
+		}
+	}
+
+	if previous == nil && cfg.Deduplication != nil {
+		dedup, err := newDeduplicator(cfg.Deduplication)
+		if err != nil {
+			return nil, err
+		}
+		webhooks.dedup = dedup
+	}
+
+	for _, webhook := range cfg.Webhooks {
+		backoffElapsedTime := maximumBackoffElapsedTime
+		if webhook.MaxBackoffTimeSeconds > 0 {
+			backoffElapsedTime = time.Duration(webhook.MaxBackoffTimeSeconds) * time.Second
+		}
+
+		tmpl, err := templates.New(cfg.WebhookTemplateFiles(webhook)...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load templates for webhook ('%s'): %w", webhook.Name, err)
+		}
+		webhooks.templates[webhook.Name] = tmpl
+		webhooks.configs[webhook.Name] = webhook
+
+		mentions := append([]config.Mention(nil), webhook.Mentions...)
+		for i := range mentions {
+			if err := mentions[i].Compile(); err != nil {
+				return nil, fmt.Errorf("invalid mention for webhook ('%s'): %w", webhook.Name, err)
+			}
+		}
+		webhooks.mentions[webhook.Name] = mentions
+
+		if previousClient, ok := previous.client(webhook); ok {
+			webhooks.clients[webhook.Name] = previousClient
+			continue
+		}
+		// each Discord client wraps instrumentation around the transport of its http.Client, so each requires its own copy
+		webhookClient := *client
+		webhooks.clients[webhook.Name] = discord.NewClient(&webhookClient, webhook.URL, backoffElapsedTime)
+	}
+
+	return webhooks, nil
+}
+
+// client returns the Discord client of a webhook with the same url and backoff as the webhook, if any.
+func (wh *Webhooks) client(webhook config.Webhook) (*discord.Client, bool) {
+	if wh == nil {
+		return nil, false
+	}
+	for name, previous := range wh.configs {
+		if previous.URL == webhook.URL && previous.MaxBackoffTimeSeconds == webhook.MaxBackoffTimeSeconds {
+			return wh.clients[name], true
+		}
+	}
+	return nil, false
+}
+
+// Deliver publishes a queued message to Discord.
+// Responses which will not succeed if retried, e.g. 400 Bad Request, are returned as permanent errors.
+func (wh *Webhooks) Deliver(message queue.Message) error {
+	if _, ok := wh.clients[message.Webhook]; !ok {
+		return backoff.Permanent(fmt.Errorf("the webhook ('%s') has not been configured", message.Webhook))
+	}
+
+	res, err := wh.Publish(message)
+	if err != nil {
+		return err
+	}
+	discord.CloseBody(res)
+	if res.StatusCode < 200 || res.StatusCode > 399 {
+		return backoff.Permanent(fmt.Errorf("Discord responded with status code %d", res.StatusCode))
+	}
+	return nil
+}
+
+// Publish sends the message to Discord, editing a previously published message if the message has an EditMessageID.
+// If the webhook posts into threads, the message is posted within the thread of its group, creating the thread if it does not yet exist.
+// If the message has fingerprints, the published message is recorded so that it may be edited when its alerts resolve.
+func (wh *Webhooks) Publish(message queue.Message) (*http.Response, error) {
+	client := wh.clients[message.Webhook]
+	logger := log.With().
+		Str(logging.FieldKeyCorrelationId, message.CorrelationId).
+		Str(logging.FieldKeyWebhook, message.Webhook).
+		Logger()
+
+	threadID := ""
+	if wh.configs[message.Webhook].Threads && message.GroupKey != "" {
+		if id, ok := wh.messages.GetThread(message.Webhook, message.GroupKey); ok {
+			threadID = id
+			// the thread already exists, so the message is posted within it rather than creating another
+			message.Out.ThreadName = ""
+		}
+	}
+
+	if message.EditMessageID != "" {
+		res, err := client.EditMessage(message.EditMessageID, threadID, message.Out)
+		if err != nil {
+			return res, err
+		}
+		if res.StatusCode != http.StatusNotFound {
+			if res.StatusCode >= 200 && res.StatusCode <= 399 {
+				if err := wh.messages.Delete(discord.MessageState{MessageID: message.EditMessageID}); err != nil {
+					logger.Error().Err(err).Msg("Unable to remove the edited message from the message state.")
+				}
+			}
+			return res, nil
+		}
+
+		// the message may have been deleted from the channel, in which case the resolution is published as a new message
+		logger.Warn().Msg("The message to be edited no longer exists. Publishing a new message instead.")
+		discord.CloseBody(res)
+		if err := wh.messages.Delete(discord.MessageState{MessageID: message.EditMessageID}); err != nil {
+			logger.Error().Err(err).Msg("Unable to remove the deleted message from the message state.")
+		}
+	}
+
+	createsThread := message.Out.ThreadName != ""
+	if len(message.Fingerprints) == 0 && threadID == "" && !createsThread {
+		return client.PublishMessage(message.Out)
+	}
+
+	created, res, err := client.PublishMessageAndWait(message.Out, threadID)
+	if created == nil {
+		return res, err
+	}
+
+	if createsThread {
+		// the id of a thread created by a message in a forum channel is returned as the channel of the message
+		if err := wh.messages.PutThread(message.Webhook, message.GroupKey, created.ChannelID); err != nil {
+			logger.Error().Err(err).Msg("Unable to record the created thread in the message state.")
+		}
+	}
+
+	if len(message.Fingerprints) > 0 {
+		err := wh.messages.Put(discord.MessageState{
+			Webhook:      message.Webhook,
+			GroupKey:     message.GroupKey,
+			MessageID:    created.ID,
+			Fingerprints: message.Fingerprints,
+			Message:      message.Out,
+		})
+		if err != nil {
+			// the message has been published, so this only prevents it being edited later
+			logger.Error().Err(err).Msg("Unable to record the published message in the message state.")
+		}
+	}
+	return res, err
+}
@@ -1,33 +1,174 @@
 package alertmanager
 
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
 const (
 	StatusFiring   = "firing"
 	StatusResolved = "resolved"
+
+	// Version is the version of the AlertManager webhook payload which is supported.
+	Version = "4"
+	// GrafanaVersion is the version of the payload sent by the webhook contact point of Grafana's unified alerting.
+	GrafanaVersion = "1"
 )
 
+// Alert is a single alert within a notification.
+// https://prometheus.io/docs/alerting/latest/configuration/#webhook_config
 type Alert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
 	Annotations  map[string]string `json:"annotations"`
-	EndsAt       string            `json:"endsAt"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
 	GeneratorURL string            `json:"generatorURL"`
-	Labels       map[string]string `json:"labels"`
-	StartsAt     string            `json:"startsAt"`
-	Status       string            `json:"status"`
+	Fingerprint  string            `json:"fingerprint"`
+
+	// The following are only sent by Grafana.
+	// ImageURL is the screenshot of the panel at the time the alert fired, if Grafana has been configured to upload images.
+	ImageURL     string `json:"imageURL,omitempty"`
+	DashboardURL string `json:"dashboardURL,omitempty"`
+	PanelURL     string `json:"panelURL,omitempty"`
+	// SilenceURL is the page of the Grafana user interface on which a silence for the alert is pre-filled.
+	SilenceURL string `json:"silenceURL,omitempty"`
+	// Values are the values of the queries and expressions of the alert rule, by their reference id, e.g. 'B'.
+	Values map[string]float64 `json:"values,omitempty"`
+}
+
+// UnmarshalJSON unmarshals the alert, treating an empty or null startsAt or endsAt as the zero time,
+// as some senders which are otherwise compatible with AlertManager send an empty string for an alert which has not ended.
+func (a *Alert) UnmarshalJSON(b []byte) error {
+	type alert Alert
+	aux := struct {
+		*alert
+		StartsAt json.RawMessage `json:"startsAt"`
+		EndsAt   json.RawMessage `json:"endsAt"`
+	}{alert: (*alert)(a)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	var err error
+	if a.StartsAt, err = parseTime(aux.StartsAt); err != nil {
+		return fmt.Errorf("unable to parse startsAt: %w", err)
+	}
+	if a.EndsAt, err = parseTime(aux.EndsAt); err != nil {
+		return fmt.Errorf("unable to parse endsAt: %w", err)
+	}
+	return nil
+}
+
+func parseTime(raw json.RawMessage) (time.Time, error) {
+	var t time.Time
+	if s := string(raw); s == "" || s == "null" || s == `""` {
+		return t, nil
+	}
+	err := json.Unmarshal(raw, &t)
+	return t, err
 }
 
+// Out is the payload of a notification sent by AlertManager's webhook receiver.
 type Out struct {
-	Alerts            []Alert `json:"alerts"`
-	CommonAnnotations struct {
-		Summary string `json:"summary"`
-	} `json:"commonAnnotations"`
-	CommonLabels struct {
-		Alertname string `json:"alertname"`
-	} `json:"commonLabels"`
-	ExternalURL string `json:"externalURL"`
-	GroupKey    string `json:"groupKey"`
-	GroupLabels struct {
-		Alertname string `json:"alertname"`
-	} `json:"groupLabels"`
-	Receiver string `json:"receiver"`
-	Status   string `json:"status"`
 	Version  string `json:"version"`
+	GroupKey string `json:"groupKey"`
+	// TruncatedAlerts is the number of alerts which were omitted from the notification, due to the receiver's 'max_alerts'.
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []Alert           `json:"alerts"`
+
+	// The following are only sent by Grafana.
+	// Title and Message are rendered by the templates of the Grafana contact point.
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message,omitempty"`
+	// State is the state of the notification in Grafana's terms, e.g. 'alerting' or 'ok'.
+	State string `json:"state,omitempty"`
+	OrgID int64  `json:"orgId,omitempty"`
+}
+
+// IsGrafana returns true if the payload was sent by the webhook contact point of Grafana's unified alerting,
+// which extends the AlertManager payload with its own fields.
+func (o *Out) IsGrafana() bool {
+	return o.OrgID != 0 || o.State != ""
+}
+
+// CheckVersion returns an error if the payload is of a version which is not supported.
+// Payloads without a version are accepted, as they are not sent by AlertManager itself but are otherwise compatible.
+func (o *Out) CheckVersion() error {
+	if o.IsGrafana() {
+		if o.Version != "" && o.Version != GrafanaVersion {
+			return fmt.Errorf("version ('%s') of the Grafana webhook payload is not supported, expected version ('%s')", o.Version, GrafanaVersion)
+		}
+		return nil
+	}
+	if o.Version != "" && o.Version != Version {
+		return fmt.Errorf("version ('%s') of the AlertManager webhook payload is not supported, expected version ('%s')", o.Version, Version)
+	}
+	return nil
+}
+
+// NewOut creates the notification of a group of alerts, as AlertManager would for alerts grouped by the labels, for senders which do not group alerts themselves.
+// The notification is firing if any of its alerts are firing.
+func NewOut(receiver string, groupBy []string, alerts []Alert) *Out {
+	o := &Out{
+		Receiver:          receiver,
+		Status:            StatusResolved,
+		GroupLabels:       map[string]string{},
+		CommonLabels:      map[string]string{},
+		CommonAnnotations: map[string]string{},
+		Alerts:            alerts,
+	}
+	for _, alert := range alerts {
+		if alert.Status == StatusFiring {
+			o.Status = StatusFiring
+		}
+	}
+
+	if len(alerts) > 0 {
+		for _, name := range groupBy {
+			if value, ok := alerts[0].Labels[name]; ok {
+				o.GroupLabels[name] = value
+			}
+		}
+		o.CommonLabels = common(alerts, func(alert Alert) map[string]string { return alert.Labels })
+		o.CommonAnnotations = common(alerts, func(alert Alert) map[string]string { return alert.Annotations })
+	}
+
+	names := make([]string, 0, len(o.GroupLabels))
+	for name := range o.GroupLabels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	matchers := make([]string, 0, len(names))
+	for _, name := range names {
+		matchers = append(matchers, fmt.Sprintf("%s=%q", name, o.GroupLabels[name]))
+	}
+	o.GroupKey = fmt.Sprintf("{}:{%s}", strings.Join(matchers, ","))
+
+	return o
+}
+
+// common returns the labels, or annotations, which all of the alerts share.
+func common(alerts []Alert, values func(Alert) map[string]string) map[string]string {
+	shared := make(map[string]string, len(values(alerts[0])))
+	for name, value := range values(alerts[0]) {
+		shared[name] = value
+	}
+	for _, alert := range alerts[1:] {
+		for name, value := range shared {
+			if other, ok := values(alert)[name]; !ok || other != value {
+				delete(shared, name)
+			}
+		}
+	}
+	return shared
 }
@@ -0,0 +1,142 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Out_Unmarshal_RetainsTheCompletePayload(t *testing.T) {
+	payload := `{
+		"version": "4",
+		"groupKey": "{}:{alertname=\"HighLatency\"}",
+		"truncatedAlerts": 2,
+		"status": "firing",
+		"receiver": "discord",
+		"groupLabels": {"alertname": "HighLatency", "cluster": "eu-1"},
+		"commonLabels": {"alertname": "HighLatency", "cluster": "eu-1", "severity": "warning"},
+		"commonAnnotations": {"summary": "Latency is high", "runbook_url": "https://example.com/runbook"},
+		"externalURL": "https://alertmanager.example.com",
+		"alerts": [{
+			"status": "firing",
+			"labels": {"alertname": "HighLatency", "instance": "web-1"},
+			"annotations": {"description": "p99 latency above 1s"},
+			"startsAt": "2024-01-02T03:04:05.678Z",
+			"endsAt": "0001-01-01T00:00:00Z",
+			"generatorURL": "https://prometheus.example.com/graph",
+			"fingerprint": "c6a1b2d3e4f50617"
+		}]
+	}`
+
+	SUT := Out{}
+	assert.NoError(t, json.Unmarshal([]byte(payload), &SUT), "unmarshalling payload")
+	assert.NoError(t, SUT.CheckVersion(), "version 4 should be supported")
+
+	assert.Equal(t, 2, SUT.TruncatedAlerts, "truncated alerts")
+	assert.Equal(t, map[string]string{"alertname": "HighLatency", "cluster": "eu-1"}, SUT.GroupLabels, "group labels")
+	assert.Equal(t, "warning", SUT.CommonLabels["severity"], "common labels")
+	assert.Equal(t, "https://example.com/runbook", SUT.CommonAnnotations["runbook_url"], "common annotations")
+
+	alert := SUT.Alerts[0]
+	assert.Equal(t, "c6a1b2d3e4f50617", alert.Fingerprint, "alert fingerprint")
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 678000000, time.UTC), alert.StartsAt.UTC(), "alert starts at")
+	assert.True(t, alert.EndsAt.IsZero(), "firing alert should not have ended")
+}
+
+func Test_Out_CheckVersion_UnsupportedVersion_ReturnsError(t *testing.T) {
+	assert.Error(t, (&Out{Version: "5"}).CheckVersion(), "version 5 should not be supported")
+	assert.NoError(t, (&Out{}).CheckVersion(), "payloads without a version should be accepted")
+}
+
+func Test_Out_Unmarshal_GrafanaPayload_IsDetected(t *testing.T) {
+	payload := `{
+		"receiver": "discord",
+		"status": "firing",
+		"orgId": 1,
+		"alerts": [{
+			"status": "firing",
+			"labels": {"alertname": "HighCPU", "grafana_folder": "Infrastructure"},
+			"annotations": {"summary": "CPU is high"},
+			"startsAt": "2024-01-02T03:04:05Z",
+			"endsAt": "0001-01-01T00:00:00Z",
+			"generatorURL": "https://grafana.example.com/alerting/grafana/abc/view",
+			"fingerprint": "57c6d9296de2ad39",
+			"silenceURL": "https://grafana.example.com/alerting/silence/new?matcher=alertname%3DHighCPU",
+			"dashboardURL": "https://grafana.example.com/d/cpu",
+			"panelURL": "https://grafana.example.com/d/cpu?viewPanel=2",
+			"imageURL": "https://grafana.example.com/public/img/attachments/cpu.png",
+			"values": {"B": 93.5, "C": 1}
+		}],
+		"groupLabels": {"alertname": "HighCPU"},
+		"commonLabels": {"alertname": "HighCPU"},
+		"commonAnnotations": {"summary": "CPU is high"},
+		"externalURL": "https://grafana.example.com/",
+		"version": "1",
+		"groupKey": "{}:{alertname=\"HighCPU\"}",
+		"truncatedAlerts": 0,
+		"title": "[FIRING:1] HighCPU Infrastructure",
+		"state": "alerting",
+		"message": "**Firing**\n\nValue: B=93.5, C=1"
+	}`
+
+	SUT := Out{}
+	assert.NoError(t, json.Unmarshal([]byte(payload), &SUT), "unmarshalling payload")
+	assert.True(t, SUT.IsGrafana(), "payload should be detected as sent by Grafana")
+	assert.NoError(t, SUT.CheckVersion(), "version 1 of Grafana's payload should be supported")
+
+	assert.Equal(t, "[FIRING:1] HighCPU Infrastructure", SUT.Title, "title")
+	assert.Equal(t, "alerting", SUT.State, "state")
+	assert.Equal(t, int64(1), SUT.OrgID, "org id")
+
+	alert := SUT.Alerts[0]
+	assert.Equal(t, "https://grafana.example.com/d/cpu", alert.DashboardURL, "alert dashboard url")
+	assert.Equal(t, "https://grafana.example.com/d/cpu?viewPanel=2", alert.PanelURL, "alert panel url")
+	assert.Equal(t, "https://grafana.example.com/public/img/attachments/cpu.png", alert.ImageURL, "alert image url")
+	assert.Equal(t, map[string]float64{"B": 93.5, "C": 1}, alert.Values, "alert values")
+
+	assert.False(t, (&Out{Version: "4"}).IsGrafana(), "AlertManager's payload should not be detected as sent by Grafana")
+	assert.Error(t, (&Out{Version: "4", OrgID: 1}).CheckVersion(), "Grafana's payload should only be of version 1")
+}
+
+func Test_NewOut_GroupsAlertsAsAlertManagerWould(t *testing.T) {
+	SUT := NewOut("discord", []string{"alertname"}, []Alert{
+		{Status: StatusResolved, Labels: map[string]string{"alertname": "HighCPU", "instance": "web-1", "severity": "warning"}},
+		{Status: StatusFiring, Labels: map[string]string{"alertname": "HighCPU", "instance": "web-2", "severity": "warning"}},
+	})
+
+	assert.Equal(t, `{}:{alertname="HighCPU"}`, SUT.GroupKey, "group key")
+	assert.Equal(t, StatusFiring, SUT.Status, "notification is firing while any alert is firing")
+	assert.Equal(t, map[string]string{"alertname": "HighCPU"}, SUT.GroupLabels, "group labels")
+	assert.Equal(t, map[string]string{"alertname": "HighCPU", "severity": "warning"}, SUT.CommonLabels, "common labels")
+	assert.Equal(t, "discord", SUT.Receiver, "receiver")
+}
+
+func Test_Alert_Unmarshal_EmptyOrNullTimes_AreZero(t *testing.T) {
+	payload := `{
+		"status": "firing",
+		"alerts": [
+			{"status": "firing", "labels": {"alertname": "Empty"}, "startsAt": "", "endsAt": ""},
+			{"status": "firing", "labels": {"alertname": "Null"}, "startsAt": null, "endsAt": null},
+			{"status": "resolved", "labels": {"alertname": "Missing"}, "endsAt": "2024-01-02T03:04:05Z"}
+		]
+	}`
+
+	SUT := Out{}
+	assert.NoError(t, json.Unmarshal([]byte(payload), &SUT), "empty and null times should be accepted")
+
+	assert.Equal(t, 3, len(SUT.Alerts), "number of alerts")
+	for _, alert := range SUT.Alerts {
+		assert.True(t, alert.StartsAt.IsZero(), "start of alert ('%s') should be zero", alert.Labels["alertname"])
+	}
+	assert.True(t, SUT.Alerts[0].EndsAt.IsZero(), "empty end should be zero")
+	assert.True(t, SUT.Alerts[1].EndsAt.IsZero(), "null end should be zero")
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), SUT.Alerts[2].EndsAt.UTC(), "end should be parsed")
+	assert.Equal(t, "Missing", SUT.Alerts[2].Labels["alertname"], "the other fields should be unmarshalled")
+}
+
+func Test_Alert_Unmarshal_InvalidTime_ReturnsError(t *testing.T) {
+	var SUT Alert
+	assert.Error(t, json.Unmarshal([]byte(`{"startsAt": "yesterday"}`), &SUT), "a time which is not RFC3339 should be rejected")
+}
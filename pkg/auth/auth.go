@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/logging"
+	"github.com/specklesystems/alertmanager-discord/pkg/metrics"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	ReasonMissingCredentials         = "missing_credentials"
+	ReasonInvalidBearerToken         = "invalid_bearer_token"
+	ReasonInvalidBasicAuth           = "invalid_basic_auth"
+	ReasonMissingClientCertificate   = "missing_client_certificate"
+	ReasonClientCertificateForbidden = "client_certificate_forbidden"
+
+	realm = `Basic realm="alertmanager-discord"`
+)
+
+// dummyHash is compared against the passwords of unknown users, so that the response time does not reveal which users exist.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("alertmanager-discord"), bcrypt.DefaultCost)
+
+// Options are the credentials which are accepted by an Authenticator.
+type Options struct {
+	BearerToken string
+	// BasicAuthUsers maps each username to a bcrypt hash of its password.
+	BasicAuthUsers map[string]string
+	// RequireClientCertificate requires a client certificate, which has been verified by the TLS listener.
+	RequireClientCertificate bool
+	// AllowedNames, if provided, must include the common name or one of the DNS names of the client certificate.
+	AllowedNames []string
+}
+
+// Authenticator rejects requests which do not present the configured credentials.
+// Requests without credentials are rejected as unauthenticated (401), and requests with incorrect credentials as forbidden (403).
+type Authenticator struct {
+	bearerToken              []byte
+	users                    map[string][]byte
+	requireClientCertificate bool
+	allowedNames             map[string]bool
+
+	// verified caches a digest of the most recent correct password of each user, as bcrypt is purposefully slow
+	mu       sync.Mutex
+	verified map[string][sha256.Size]byte
+}
+
+func NewAuthenticator(options Options) *Authenticator {
+	a := &Authenticator{
+		bearerToken:              []byte(options.BearerToken),
+		users:                    make(map[string][]byte, len(options.BasicAuthUsers)),
+		requireClientCertificate: options.RequireClientCertificate,
+		allowedNames:             make(map[string]bool, len(options.AllowedNames)),
+		verified:                 make(map[string][sha256.Size]byte),
+	}
+	for username, hash := range options.BasicAuthUsers {
+		a.users[username] = []byte(hash)
+	}
+	for _, name := range options.AllowedNames {
+		a.allowedNames[name] = true
+	}
+	return a
+}
+
+// Handler wraps the handler, so that it only receives authenticated requests.
+func (a *Authenticator) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		statusCode, reason := a.Authenticate(r)
+		if statusCode == http.StatusOK {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		log.Info().
+			Str(logging.FieldKeyHttpPath, r.URL.Path).
+			Int(logging.FieldKeyStatusCode, statusCode).
+			Msgf("Rejected request from ('%s'): %s", r.RemoteAddr, reason)
+		metrics.AuthenticationFailuresTotal.WithLabelValues(strconv.Itoa(statusCode), reason).Inc()
+
+		if statusCode == http.StatusUnauthorized && len(a.users) > 0 {
+			w.Header().Set("WWW-Authenticate", realm)
+		}
+		http.Error(w, http.StatusText(statusCode), statusCode)
+	})
+}
+
+// Authenticate returns 200 OK if the request presents the configured credentials, otherwise the status code and reason of its rejection.
+func (a *Authenticator) Authenticate(r *http.Request) (int, string) {
+	if len(a.bearerToken) > 0 || len(a.users) > 0 {
+		if statusCode, reason := a.authenticateCredentials(r); statusCode != http.StatusOK {
+			return statusCode, reason
+		}
+	}
+	if a.requireClientCertificate {
+		return a.authenticateClientCertificate(r)
+	}
+	return http.StatusOK, ""
+}
+
+func (a *Authenticator) authenticateCredentials(r *http.Request) (int, string) {
+	authorization := r.Header.Get("Authorization")
+	scheme, credentials, _ := strings.Cut(authorization, " ")
+
+	switch {
+	case strings.EqualFold(scheme, "Bearer") && len(a.bearerToken) > 0:
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(credentials)), a.bearerToken) != 1 {
+			return http.StatusForbidden, ReasonInvalidBearerToken
+		}
+		return http.StatusOK, ""
+	case strings.EqualFold(scheme, "Basic") && len(a.users) > 0:
+		username, password, ok := r.BasicAuth()
+		if !ok || !a.verifyPassword(username, password) {
+			return http.StatusForbidden, ReasonInvalidBasicAuth
+		}
+		return http.StatusOK, ""
+	default:
+		// credentials of a scheme which is not configured are treated as missing, so that the client is challenged
+		return http.StatusUnauthorized, ReasonMissingCredentials
+	}
+}
+
+func (a *Authenticator) verifyPassword(username, password string) bool {
+	digest := sha256.Sum256([]byte(password))
+
+	hash, ok := a.users[username]
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+
+	a.mu.Lock()
+	verified, cached := a.verified[username]
+	a.mu.Unlock()
+	if cached && subtle.ConstantTimeCompare(verified[:], digest[:]) == 1 {
+		return true
+	}
+
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+		return false
+	}
+	a.mu.Lock()
+	a.verified[username] = digest
+	a.mu.Unlock()
+	return true
+}
+
+func (a *Authenticator) authenticateClientCertificate(r *http.Request) (int, string) {
+	// the TLS listener verifies the certificate against the client CA; only verified certificates are accepted
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return http.StatusUnauthorized, ReasonMissingClientCertificate
+	}
+	if len(a.allowedNames) == 0 {
+		return http.StatusOK, ""
+	}
+
+	certificate := r.TLS.VerifiedChains[0][0]
+	if a.allowedNames[certificate.Subject.CommonName] {
+		return http.StatusOK, ""
+	}
+	for _, name := range certificate.DNSNames {
+		if a.allowedNames[name] {
+			return http.StatusOK, ""
+		}
+	}
+	return http.StatusForbidden, ReasonClientCertificateForbidden
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func Test_Authenticate_BearerToken(t *testing.T) {
+	SUT := NewAuthenticator(Options{BearerToken: "a_token"})
+
+	assertAuthenticated(t, SUT, newRequest("Bearer a_token", nil), http.StatusOK, "correct bearer token")
+	assertAuthenticated(t, SUT, newRequest("bearer a_token", nil), http.StatusOK, "scheme is case insensitive")
+	assertAuthenticated(t, SUT, newRequest("Bearer another_token", nil), http.StatusForbidden, "incorrect bearer token")
+	assertAuthenticated(t, SUT, newRequest("", nil), http.StatusUnauthorized, "missing bearer token")
+	assertAuthenticated(t, SUT, newBasicAuthRequest("user", "a_token"), http.StatusUnauthorized, "unconfigured scheme")
+}
+
+func Test_Authenticate_BasicAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("a_password"), bcrypt.MinCost)
+	assert.NoError(t, err, "hashing password")
+	SUT := NewAuthenticator(Options{BasicAuthUsers: map[string]string{"alertmanager": string(hash)}})
+
+	assertAuthenticated(t, SUT, newBasicAuthRequest("alertmanager", "a_password"), http.StatusOK, "correct password")
+	assertAuthenticated(t, SUT, newBasicAuthRequest("alertmanager", "a_password"), http.StatusOK, "correct password, once cached")
+	assertAuthenticated(t, SUT, newBasicAuthRequest("alertmanager", "another_password"), http.StatusForbidden, "incorrect password, once the correct password is cached")
+	assertAuthenticated(t, SUT, newBasicAuthRequest("unknown", "a_password"), http.StatusForbidden, "unknown user")
+	assertAuthenticated(t, SUT, newRequest("", nil), http.StatusUnauthorized, "missing credentials")
+}
+
+func Test_Authenticate_BearerTokenOrBasicAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("a_password"), bcrypt.MinCost)
+	assert.NoError(t, err, "hashing password")
+	SUT := NewAuthenticator(Options{BearerToken: "a_token", BasicAuthUsers: map[string]string{"alertmanager": string(hash)}})
+
+	assertAuthenticated(t, SUT, newRequest("Bearer a_token", nil), http.StatusOK, "either bearer token")
+	assertAuthenticated(t, SUT, newBasicAuthRequest("alertmanager", "a_password"), http.StatusOK, "or basic auth is accepted")
+}
+
+func Test_Authenticate_ClientCertificate(t *testing.T) {
+	SUT := NewAuthenticator(Options{RequireClientCertificate: true, AllowedNames: []string{"alertmanager.monitoring.svc"}})
+
+	allowedByCommonName := &x509.Certificate{Subject: pkix.Name{CommonName: "alertmanager.monitoring.svc"}}
+	allowedByDNSName := &x509.Certificate{Subject: pkix.Name{CommonName: "alertmanager"}, DNSNames: []string{"alertmanager.monitoring.svc"}}
+	forbidden := &x509.Certificate{Subject: pkix.Name{CommonName: "someone-else"}}
+
+	assertAuthenticated(t, SUT, newRequest("", allowedByCommonName), http.StatusOK, "allowed common name")
+	assertAuthenticated(t, SUT, newRequest("", allowedByDNSName), http.StatusOK, "allowed dns name")
+	assertAuthenticated(t, SUT, newRequest("", forbidden), http.StatusForbidden, "name is not allowed")
+	assertAuthenticated(t, SUT, newRequest("", nil), http.StatusUnauthorized, "missing client certificate")
+}
+
+func Test_Authenticate_BearerTokenAndClientCertificate(t *testing.T) {
+	SUT := NewAuthenticator(Options{BearerToken: "a_token", RequireClientCertificate: true})
+	certificate := &x509.Certificate{Subject: pkix.Name{CommonName: "alertmanager"}}
+
+	assertAuthenticated(t, SUT, newRequest("Bearer a_token", certificate), http.StatusOK, "both bearer token and client certificate")
+	assertAuthenticated(t, SUT, newRequest("Bearer a_token", nil), http.StatusUnauthorized, "bearer token without client certificate")
+	assertAuthenticated(t, SUT, newRequest("", certificate), http.StatusUnauthorized, "client certificate without bearer token")
+}
+
+func Test_Handler_RejectsUnauthenticatedRequests(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("a_password"), bcrypt.MinCost)
+	assert.NoError(t, err, "hashing password")
+	handled := 0
+	SUT := NewAuthenticator(Options{BasicAuthUsers: map[string]string{"alertmanager": string(hash)}}).Handler(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { handled++ }),
+	)
+
+	w := httptest.NewRecorder()
+	SUT.ServeHTTP(w, newRequest("", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "missing credentials should be unauthorized")
+	assert.Equal(t, realm, w.Header().Get("WWW-Authenticate"), "client should be challenged for basic auth")
+
+	w = httptest.NewRecorder()
+	SUT.ServeHTTP(w, newBasicAuthRequest("alertmanager", "another_password"))
+	assert.Equal(t, http.StatusForbidden, w.Code, "incorrect credentials should be forbidden")
+	assert.Equal(t, 0, handled, "rejected requests should not be handled")
+
+	w = httptest.NewRecorder()
+	SUT.ServeHTTP(w, newBasicAuthRequest("alertmanager", "a_password"))
+	assert.Equal(t, http.StatusOK, w.Code, "correct credentials should be handled")
+	assert.Equal(t, 1, handled, "authenticated request should be handled")
+}
+
+func newRequest(authorization string, certificate *x509.Certificate) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	if certificate != nil {
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{certificate}}}
+	}
+	return r
+}
+
+func newBasicAuthRequest(username, password string) *http.Request {
+	r := newRequest("", nil)
+	r.SetBasicAuth(username, password)
+	return r
+}
+
+func assertAuthenticated(t *testing.T, SUT *Authenticator, r *http.Request, expected int, scenario string) {
+	t.Helper()
+	actual, reason := SUT.Authenticate(r)
+	assert.Equal(t, expected, actual, "%s (%s)", scenario, reason)
+}
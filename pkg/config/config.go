@@ -0,0 +1,645 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/decoder"
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+	"github.com/specklesystems/alertmanager-discord/pkg/routing"
+	"github.com/specklesystems/alertmanager-discord/pkg/severity"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultWebhookName is the name given to the webhook provided by the 'discord_webhook_url' flag, environment variable, or configuration key.
+	DefaultWebhookName = "default"
+	// DefaultReceiverPathPrefix is prepended to the name of a receiver if it does not have a path.
+	DefaultReceiverPathPrefix = "/hooks/"
+	// DefaultMappingPathPrefix is prepended to the name of a mapping if it does not have a path.
+	DefaultMappingPathPrefix = "/mappings/"
+)
+
+// ReservedPaths cannot be used by receivers, as they are served by the server itself.
+var ReservedPaths = []string{"/", "/favicon.ico", "/liveness", "/metrics", "/readiness", "/silence", "/-/reload"}
+
+// Config holds the structured sections of the configuration file.
+// Simple scalar values (e.g. 'discord_webhook_url' or 'listen_address') are instead read via flags, environment variables, or viper.
+type Config struct {
+	Webhooks  []Webhook      `yaml:"webhooks"`
+	Route     *routing.Route `yaml:"route"`
+	Receivers []Receiver     `yaml:"receivers"`
+	// Mappings are endpoints which map arbitrary JSON, rather than alerts, into Discord messages.
+	Mappings []Mapping `yaml:"mappings"`
+	// TemplateFiles are parsed for all webhooks, before any template files of the webhook itself.
+	TemplateFiles []string `yaml:"template_files"`
+	// Queue, if provided, enables the durable queue.
+	Queue *Queue `yaml:"queue"`
+	// Deduplication, if provided, drops notifications which have already been sent within a window.
+	Deduplication *Deduplication `yaml:"deduplication"`
+	// Dispatcher, if provided, delivers messages asynchronously using a pool of workers and a bounded in-memory queue.
+	Dispatcher *Dispatcher `yaml:"dispatcher"`
+	// MessageState configures how the messages published to Discord are recorded, so that they may later be edited.
+	MessageState *MessageState `yaml:"message_state"`
+	// Silences, if provided, enables links from which alerts can be silenced via the AlertManager API.
+	Silences *Silences `yaml:"silences"`
+	// Auth, if provided, requires requests from AlertManager to be authenticated.
+	Auth *Auth `yaml:"auth"`
+	// Prometheus, if provided, configures how alerts sent directly by Prometheus, rather than via AlertManager, are handled.
+	Prometheus *Prometheus `yaml:"prometheus"`
+	// Severity, if provided, configures the label and levels by which alerts are coloured and prefixed with an emoji.
+	Severity *Severity `yaml:"severity"`
+}
+
+// Prometheus configures how alerts sent directly by Prometheus are handled. By default, a warning that Prometheus has been misconfigured is sent instead.
+type Prometheus struct {
+	// ForwardAlerts, if true, forwards the alerts to Discord, e.g. where Prometheus is run without AlertManager.
+	ForwardAlerts bool `yaml:"forward_alerts"`
+	// GroupWaitSeconds is how long the alerts of each alertname are buffered, so that they are sent together. Defaults to 10 seconds.
+	GroupWaitSeconds int `yaml:"group_wait_seconds"`
+}
+
+// Decoders returns the decoders of the supported input formats, as configured.
+func (c *Config) Decoders() *decoder.Registry {
+	decoders := decoder.Default()
+	if c.Prometheus != nil {
+		decoders.Register(decoder.Prometheus{
+			Forward: c.Prometheus.ForwardAlerts,
+			Wait:    time.Duration(c.Prometheus.GroupWaitSeconds) * time.Second,
+		})
+	}
+	return decoders
+}
+
+// Severity configures how the severity of alerts is displayed.
+// The colour of a firing notification is that of the highest severity of its alerts, and its title is prefixed with that severity's emoji.
+type Severity struct {
+	// Label is the label whose value is the severity of an alert. Defaults to 'severity'.
+	Label string `yaml:"label"`
+	// Levels, if provided, replace the default levels of critical, warning, and info. They are ordered from the highest severity to the lowest.
+	Levels []SeverityLevel `yaml:"levels"`
+}
+
+// SeverityLevel is a value of the severity label, and the colour and emoji with which it is displayed.
+type SeverityLevel struct {
+	Name string `yaml:"name"`
+	// Color is hex, e.g. '#992D22', or a decimal number.
+	Color string `yaml:"color"`
+	Emoji string `yaml:"emoji"`
+}
+
+// Severities returns the severity levels, as configured. An error is returned if the colour of any level is invalid.
+func (c *Config) Severities() (*severity.Severities, error) {
+	if c.Severity == nil {
+		return severity.Default(), nil
+	}
+	levels := make([]severity.Level, 0, len(c.Severity.Levels))
+	for _, level := range c.Severity.Levels {
+		color, err := discord.ParseColor(level.Color)
+		if err != nil {
+			return nil, fmt.Errorf("invalid colour of severity ('%s'): %w", level.Name, err)
+		}
+		levels = append(levels, severity.Level{Name: level.Name, Color: color, Emoji: level.Emoji})
+	}
+	return severity.New(c.Severity.Label, levels), nil
+}
+
+func (s *Severity) validate() error {
+	names := make(map[string]bool, len(s.Levels))
+	for i, level := range s.Levels {
+		if level.Name == "" {
+			return fmt.Errorf("severity at index ('%d') does not have a name", i)
+		}
+		if names[strings.ToLower(level.Name)] {
+			return fmt.Errorf("severity name ('%s') is not unique", level.Name)
+		}
+		names[strings.ToLower(level.Name)] = true
+		if _, err := discord.ParseColor(level.Color); err != nil {
+			return fmt.Errorf("invalid colour of severity ('%s'): %w", level.Name, err)
+		}
+	}
+	return nil
+}
+
+// Auth configures the authentication of requests from AlertManager, matching the credentials which AlertManager's 'http_config' can send.
+// A request must present one of the configured bearer token or basic authentication credentials, if any are configured,
+// and a verified client certificate, if client certificates are configured.
+type Auth struct {
+	BearerToken     string `yaml:"bearer_token"`
+	BearerTokenFile string `yaml:"bearer_token_file"`
+	// BasicAuthUsers maps each username to a bcrypt hash of its password.
+	BasicAuthUsers map[string]string `yaml:"basic_auth_users"`
+	// ClientCertificate, if provided, requires a client certificate verified against the client CA of the TLS configuration.
+	ClientCertificate *ClientCertificate `yaml:"client_certificate"`
+}
+
+// ClientCertificate restricts the client certificates which are accepted.
+type ClientCertificate struct {
+	// AllowedNames, if provided, must include the common name or one of the DNS names of the certificate.
+	AllowedNames []string `yaml:"allowed_names"`
+}
+
+// Silences configures the silence endpoint of this service, which creates silences via the AlertManager API.
+// Links to the endpoint are signed with the signing key, so that only the alerts which were sent to Discord can be silenced.
+type Silences struct {
+	// ExternalURL is the url at which this service is reachable by those clicking the links.
+	ExternalURL string `yaml:"external_url"`
+	// AlertManagerURL is the url at which this service can reach the AlertManager API.
+	AlertManagerURL string `yaml:"alertmanager_url"`
+	SigningKey      string `yaml:"signing_key"`
+	SigningKeyFile  string `yaml:"signing_key_file"`
+	// Durations for which alerts may be silenced, e.g. '1h'. Defaults to 1h, 4h, and 24h.
+	Durations []string `yaml:"durations"`
+	// LinkTTLSeconds is the duration after which links expire.
+	LinkTTLSeconds int    `yaml:"link_ttl_seconds"`
+	CreatedBy      string `yaml:"created_by"`
+
+	// Credentials for the AlertManager API; either basic authentication or a bearer token.
+	Username        string `yaml:"username"`
+	Password        string `yaml:"password"`
+	PasswordFile    string `yaml:"password_file"`
+	BearerToken     string `yaml:"bearer_token"`
+	BearerTokenFile string `yaml:"bearer_token_file"`
+}
+
+// ParsedDurations returns the durations for which alerts may be silenced.
+func (s *Silences) ParsedDurations() ([]time.Duration, error) {
+	durations := make([]time.Duration, 0, len(s.Durations))
+	for _, value := range s.Durations {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("silence duration ('%s') is invalid", value)
+		}
+		durations = append(durations, d)
+	}
+	return durations, nil
+}
+
+// ReadSecret returns the value if provided, otherwise the trimmed contents of the file if provided, otherwise an empty string.
+func ReadSecret(value, file string) (string, error) {
+	if value != "" || file == "" {
+		return value, nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("unable to read secret file ('%s'): %w", file, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// MessageState configures the message state store. If a file is provided, the state is persisted to it, otherwise it is only held in memory.
+type MessageState struct {
+	File string `yaml:"file"`
+	// TTLSeconds is the duration after which a message is no longer edited.
+	TTLSeconds int `yaml:"ttl_seconds"`
+}
+
+// Queue configures the durable queue. Messages are persisted to the directory before being delivered to Discord.
+type Queue struct {
+	Directory string `yaml:"directory"`
+	// MaxAgeSeconds is the duration after which undelivered messages are dropped.
+	MaxAgeSeconds int `yaml:"max_age_seconds"`
+}
+
+// Deduplication configures the dropping of notifications which have already been sent to a webhook, identified by group key, status, and alert fingerprints.
+type Deduplication struct {
+	// WindowSeconds is the duration within which a repeated notification is dropped. Defaults to 5 minutes.
+	WindowSeconds int `yaml:"window_seconds"`
+	// RetentionSeconds is the duration after which a notification which has not been repeated is forgotten. Defaults to 24 hours.
+	RetentionSeconds int `yaml:"retention_seconds"`
+	// RepeatDigest causes firing notifications repeated after the window to be sent as a short reminder, rather than in full.
+	RepeatDigest bool `yaml:"repeat_digest"`
+	// File, if provided, is the file to which the state is snapshotted, so that it survives a restart.
+	File string `yaml:"file"`
+}
+
+// Dispatcher configures the asynchronous delivery of messages. Requests from AlertManager are rejected with 503 Service Unavailable once the queue is full.
+type Dispatcher struct {
+	Workers int `yaml:"workers"`
+	// QueueSize is the maximum number of notifications awaiting delivery.
+	QueueSize int `yaml:"queue_size"`
+	// RetryAfterSeconds is sent in the Retry-After header when the queue is full.
+	RetryAfterSeconds int `yaml:"retry_after_seconds"`
+}
+
+type Webhook struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	// URLFile, if provided instead of the url, is a file from which the url is read, e.g. a mounted Kubernetes secret.
+	URLFile               string   `yaml:"url_file"`
+	MaxBackoffTimeSeconds int      `yaml:"max_backoff_time_seconds"`
+	TemplateFiles         []string `yaml:"template_files"`
+	// EditResolved causes the message published for firing alerts to be edited when they resolve, rather than publishing a new message.
+	EditResolved bool `yaml:"edit_resolved"`
+	// Threads causes the alerts of each AlertManager group to be posted within their own thread. The webhook must belong to a forum channel.
+	Threads bool `yaml:"threads"`
+	// Mentions are the roles and users which are mentioned in the messages of firing alerts.
+	Mentions []Mention `yaml:"mentions"`
+}
+
+// Mention is a set of Discord roles and users which are mentioned when any firing alert within a message matches.
+// Match, MatchRE and Matchers are combined, as for a route; if there are none, the mention applies to all firing alerts.
+type Mention struct {
+	Match    map[string]string `yaml:"match"`
+	MatchRE  map[string]string `yaml:"match_re"`
+	Matchers []string          `yaml:"matchers"`
+	// Roles and Users are Discord ids, e.g. '123456789123456789'.
+	Roles []string `yaml:"roles"`
+	Users []string `yaml:"users"`
+
+	matchers []*routing.Matcher
+}
+
+// Compile parses the matchers of the mention, and checks that the roles and users are Discord ids.
+// It must be called before Matches.
+func (m *Mention) Compile() error {
+	matchers, err := routing.CompileMatchers(m.Match, m.MatchRE, m.Matchers)
+	if err != nil {
+		return err
+	}
+	m.matchers = matchers
+
+	for _, id := range append(append([]string{}, m.Roles...), m.Users...) {
+		if !isSnowflake(id) {
+			return fmt.Errorf("('%s') is not a Discord id", id)
+		}
+	}
+	if len(m.Roles) == 0 && len(m.Users) == 0 {
+		return fmt.Errorf("mention has neither roles nor users")
+	}
+	return nil
+}
+
+// Matches returns true if the labels match all matchers of the mention.
+func (m *Mention) Matches(labels map[string]string) bool {
+	return routing.MatchesAll(m.matchers, labels)
+}
+
+// isSnowflake returns true if the id is numeric, as are all Discord ids.
+func isSnowflake(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Receiver is an http endpoint to which AlertManager can send notifications, typically corresponding to an AlertManager receiver's webhook_config.
+// Alerts are sent to a single webhook, or alternatively to the webhooks selected by the receiver's own routing tree.
+type Receiver struct {
+	Name    string         `yaml:"name"`
+	Path    string         `yaml:"path"`
+	Webhook string         `yaml:"webhook"`
+	Route   *routing.Route `yaml:"route"`
+	// Format, if provided, is the format in which every request to the receiver is decoded, e.g. 'grafana'. Otherwise, the format of each request is detected.
+	Format string `yaml:"format"`
+}
+
+// ReceiverRoute returns the routing tree of the receiver. If the receiver does not have a route, all alerts are sent to its webhook.
+func (r Receiver) ReceiverRoute() *routing.Route {
+	if r.Route != nil {
+		return r.Route
+	}
+	return &routing.Route{Webhook: r.Webhook}
+}
+
+// ReceiverPath returns the path at which the receiver is served.
+func (r Receiver) ReceiverPath() string {
+	if r.Path != "" {
+		return r.Path
+	}
+	return DefaultReceiverPathPrefix + r.Name
+}
+
+// Mapping is an http endpoint which maps arbitrary JSON, e.g. from a CI pipeline or cron job, into a Discord message sent to a single webhook.
+// Each part of the message is a JSONPath expression, e.g. '$.pipeline.name', selecting a value of the JSON, or otherwise a literal value.
+type Mapping struct {
+	Name    string `yaml:"name"`
+	Path    string `yaml:"path"`
+	Webhook string `yaml:"webhook"`
+
+	Content     string `yaml:"content"`
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	URL         string `yaml:"url"`
+	// Color is the colour of the embed, as a number or as hex, e.g. '#992D22'. If it is not provided, the colour is chosen by the status.
+	Color string `yaml:"color"`
+	// Status, e.g. 'success' or 'failed', chooses the colour of the embed from the status colours.
+	Status string `yaml:"status"`
+	// StatusColors maps each status to a colour, taking precedence over the colours of the common statuses, e.g. 'failed' is red.
+	StatusColors map[string]string `yaml:"status_colors"`
+	Fields       []MappingField    `yaml:"fields"`
+}
+
+// MappingField is a field of the embed, whose name and value are each a JSONPath expression or a literal value.
+type MappingField struct {
+	Name   string `yaml:"name"`
+	Value  string `yaml:"value"`
+	Inline bool   `yaml:"inline"`
+}
+
+// MappingPath returns the path at which the mapping is served.
+func (m Mapping) MappingPath() string {
+	if m.Path != "" {
+		return m.Path
+	}
+	return DefaultMappingPathPrefix + m.Name
+}
+
+// LoadFile reads the configuration file at the given path.
+// The file is parsed directly, rather than via viper, as viper does not preserve the case of map keys (e.g. label names).
+func LoadFile(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read configuration file ('%s'): %w", path, err)
+	}
+
+	return Parse(b)
+}
+
+func Parse(b []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// Webhook returns the webhook with the given name, if it exists.
+func (c *Config) Webhook(name string) (Webhook, bool) {
+	for _, webhook := range c.Webhooks {
+		if webhook.Name == name {
+			return webhook, true
+		}
+	}
+	return Webhook{}, false
+}
+
+// WebhookTemplateFiles returns the template files to be parsed for the webhook, in order.
+func (c *Config) WebhookTemplateFiles(webhook Webhook) []string {
+	files := make([]string, 0, len(c.TemplateFiles)+len(webhook.TemplateFiles))
+	files = append(files, c.TemplateFiles...)
+	return append(files, webhook.TemplateFiles...)
+}
+
+// AddDefaultWebhook adds a webhook named 'default', if a url is provided and no webhook of that name is already configured.
+// If no route is configured, a route sending all alerts to the default webhook is added.
+func (c *Config) AddDefaultWebhook(url string, maxBackoffTimeSeconds int) {
+	c.addDefaultWebhook(Webhook{Name: DefaultWebhookName, URL: url, MaxBackoffTimeSeconds: maxBackoffTimeSeconds})
+}
+
+// AddDefaultWebhookFile adds a webhook named 'default' whose url is read from the file, as AddDefaultWebhook does for a url.
+func (c *Config) AddDefaultWebhookFile(file string, maxBackoffTimeSeconds int) {
+	c.addDefaultWebhook(Webhook{Name: DefaultWebhookName, URLFile: file, MaxBackoffTimeSeconds: maxBackoffTimeSeconds})
+}
+
+func (c *Config) addDefaultWebhook(webhook Webhook) {
+	if webhook.URL != "" || webhook.URLFile != "" {
+		if _, ok := c.Webhook(DefaultWebhookName); !ok {
+			c.Webhooks = append(c.Webhooks, webhook)
+		}
+	}
+
+	if _, ok := c.Webhook(DefaultWebhookName); ok && c.Route == nil {
+		c.Route = &routing.Route{Webhook: DefaultWebhookName}
+	}
+}
+
+// ReadWebhookURLs reads the url of each webhook which has a url file.
+// The webhooks are modified in place, so a copy of the configuration should first be made if it is shared.
+func (c *Config) ReadWebhookURLs() error {
+	for i, webhook := range c.Webhooks {
+		if webhook.URLFile == "" {
+			continue
+		}
+		url, err := ReadSecret(webhook.URL, webhook.URLFile)
+		if err != nil {
+			return fmt.Errorf("unable to read url of webhook ('%s'): %w", webhook.Name, err)
+		}
+		c.Webhooks[i].URL = url
+	}
+	return nil
+}
+
+// SecretFiles returns the files from which secrets are read, e.g. webhook urls, so that they may be watched for changes.
+func (c *Config) SecretFiles() []string {
+	var files []string
+	for _, webhook := range c.Webhooks {
+		files = append(files, webhook.URLFile)
+	}
+	if c.Auth != nil {
+		files = append(files, c.Auth.BearerTokenFile)
+	}
+	if c.Silences != nil {
+		files = append(files, c.Silences.SigningKeyFile, c.Silences.PasswordFile, c.Silences.BearerTokenFile)
+	}
+
+	nonEmpty := files[:0]
+	for _, file := range files {
+		if file != "" {
+			nonEmpty = append(nonEmpty, file)
+		}
+	}
+	return nonEmpty
+}
+
+// WatchedFiles returns the files which the configuration is read from, other than the configuration file itself, i.e. its secret files and the template files of every webhook,
+// so that the configuration is reloaded once any of them change.
+func (c *Config) WatchedFiles() []string {
+	files := c.SecretFiles()
+	seen := make(map[string]bool, len(files))
+	for _, file := range files {
+		seen[file] = true
+	}
+	for _, webhook := range c.Webhooks {
+		for _, file := range c.WebhookTemplateFiles(webhook) {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	return files
+}
+
+// Validate checks the configuration for internal consistency and compiles the routing tree.
+func (c *Config) Validate() error {
+	if len(c.Webhooks) == 0 {
+		return fmt.Errorf("no Discord webhooks have been configured")
+	}
+
+	names := make(map[string]bool, len(c.Webhooks))
+	for i, webhook := range c.Webhooks {
+		if webhook.Name == "" {
+			return fmt.Errorf("webhook at index ('%d') does not have a name", i)
+		}
+		if names[webhook.Name] {
+			return fmt.Errorf("webhook name ('%s') is not unique", webhook.Name)
+		}
+		names[webhook.Name] = true
+
+		if webhook.URL != "" && webhook.URLFile != "" {
+			return fmt.Errorf("webhook ('%s') must have either a url or a url file, not both", webhook.Name)
+		}
+
+		for j := range webhook.Mentions {
+			if err := webhook.Mentions[j].Compile(); err != nil {
+				return fmt.Errorf("invalid mention at index ('%d') of webhook ('%s'): %w", j, webhook.Name, err)
+			}
+		}
+	}
+
+	if c.Route == nil && len(c.Receivers) == 0 && len(c.Mappings) == 0 {
+		return fmt.Errorf("neither a route, nor any receivers or mappings, have been configured")
+	}
+	if c.Route != nil {
+		if err := validateRoute(c.Route, names); err != nil {
+			return fmt.Errorf("invalid route: %w", err)
+		}
+	}
+
+	if c.Queue != nil && c.Queue.Directory == "" {
+		return fmt.Errorf("the queue requires a directory")
+	}
+	if c.Queue != nil && c.Dispatcher != nil {
+		// the durable queue already delivers messages asynchronously
+		return fmt.Errorf("either the durable queue or the dispatcher may be configured, not both")
+	}
+
+	if c.Auth != nil {
+		if err := c.Auth.validate(); err != nil {
+			return fmt.Errorf("invalid auth: %w", err)
+		}
+	}
+
+	if c.Prometheus != nil && c.Prometheus.GroupWaitSeconds < 0 {
+		return fmt.Errorf("the group wait of Prometheus alerts must not be negative")
+	}
+
+	if c.Severity != nil {
+		if err := c.Severity.validate(); err != nil {
+			return fmt.Errorf("invalid severity: %w", err)
+		}
+	}
+
+	if c.Silences != nil {
+		if err := c.Silences.validate(); err != nil {
+			return fmt.Errorf("invalid silences: %w", err)
+		}
+	}
+
+	paths := make(map[string]bool, len(c.Receivers))
+	for _, path := range ReservedPaths {
+		paths[path] = true
+	}
+	receiverNames := make(map[string]bool, len(c.Receivers))
+	for i, receiver := range c.Receivers {
+		if receiver.Name == "" {
+			return fmt.Errorf("receiver at index ('%d') does not have a name", i)
+		}
+		if receiverNames[receiver.Name] {
+			return fmt.Errorf("receiver name ('%s') is not unique", receiver.Name)
+		}
+		receiverNames[receiver.Name] = true
+
+		path := receiver.ReceiverPath()
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("path ('%s') of receiver ('%s') must begin with '/'", path, receiver.Name)
+		}
+		if paths[path] {
+			return fmt.Errorf("path ('%s') of receiver ('%s') is reserved or is not unique", path, receiver.Name)
+		}
+		paths[path] = true
+
+		if receiver.Webhook != "" && receiver.Route != nil {
+			return fmt.Errorf("receiver ('%s') must have either a webhook or a route, not both", receiver.Name)
+		}
+		if err := validateRoute(receiver.ReceiverRoute(), names); err != nil {
+			return fmt.Errorf("invalid route for receiver ('%s'): %w", receiver.Name, err)
+		}
+		if _, ok := decoder.Default().Lookup(receiver.Format); receiver.Format != "" && !ok {
+			return fmt.Errorf("format ('%s') of receiver ('%s') is not supported, expected one of: %s", receiver.Format, receiver.Name, strings.Join(decoder.Default().Formats(), ", "))
+		}
+	}
+
+	mappingNames := make(map[string]bool, len(c.Mappings))
+	for i, mapping := range c.Mappings {
+		if mapping.Name == "" {
+			return fmt.Errorf("mapping at index ('%d') does not have a name", i)
+		}
+		if mappingNames[mapping.Name] {
+			return fmt.Errorf("mapping name ('%s') is not unique", mapping.Name)
+		}
+		mappingNames[mapping.Name] = true
+
+		path := mapping.MappingPath()
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("path ('%s') of mapping ('%s') must begin with '/'", path, mapping.Name)
+		}
+		if paths[path] {
+			return fmt.Errorf("path ('%s') of mapping ('%s') is reserved or is not unique", path, mapping.Name)
+		}
+		paths[path] = true
+
+		if !names[mapping.Webhook] {
+			return fmt.Errorf("mapping ('%s') refers to webhook ('%s') which has not been configured", mapping.Name, mapping.Webhook)
+		}
+	}
+
+	return nil
+}
+
+func validateRoute(route *routing.Route, webhookNames map[string]bool) error {
+	if err := route.Compile(); err != nil {
+		return err
+	}
+	if route.Webhook == "" {
+		return fmt.Errorf("the root route must have a webhook")
+	}
+	for _, name := range route.Webhooks() {
+		if !webhookNames[name] {
+			return fmt.Errorf("route refers to webhook ('%s') which has not been configured", name)
+		}
+	}
+	return nil
+}
+
+func (s *Silences) validate() error {
+	if s.ExternalURL == "" {
+		return fmt.Errorf("the external url of this service is required")
+	}
+	if s.AlertManagerURL == "" {
+		return fmt.Errorf("the url of AlertManager is required")
+	}
+	if s.SigningKey == "" && s.SigningKeyFile == "" {
+		return fmt.Errorf("a signing key is required")
+	}
+	if s.Username != "" && (s.BearerToken != "" || s.BearerTokenFile != "") {
+		return fmt.Errorf("either basic authentication or a bearer token may be configured, not both")
+	}
+	_, err := s.ParsedDurations()
+	return err
+}
+
+func (a *Auth) validate() error {
+	if a.BearerToken != "" && a.BearerTokenFile != "" {
+		return fmt.Errorf("either a bearer token or a bearer token file may be configured, not both")
+	}
+	if a.BearerToken == "" && a.BearerTokenFile == "" && len(a.BasicAuthUsers) == 0 && a.ClientCertificate == nil {
+		return fmt.Errorf("no credentials have been configured")
+	}
+	for username, hash := range a.BasicAuthUsers {
+		if !strings.HasPrefix(hash, "$2") {
+			return fmt.Errorf("password of basic auth user ('%s') must be a bcrypt hash", username)
+		}
+	}
+	return nil
+}
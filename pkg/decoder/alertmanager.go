@@ -0,0 +1,58 @@
+package decoder
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+)
+
+// AlertManager decodes the payload of AlertManager's webhook receiver.
+// https://prometheus.io/docs/alerting/latest/configuration/#webhook_config
+type AlertManager struct{}
+
+func (AlertManager) Format() string {
+	return FormatAlertManager
+}
+
+// Detect accepts any JSON object, as every field of the payload is optional, so that senders which only resemble AlertManager are also accepted.
+func (AlertManager) Detect(b []byte) bool {
+	_, err := unmarshalOut(b)
+	return err == nil
+}
+
+func (AlertManager) Decode(b []byte) (*alertmanager.Out, error) {
+	amo, err := unmarshalOut(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := amo.CheckVersion(); err != nil {
+		return nil, err
+	}
+	return amo, nil
+}
+
+// Grafana decodes the payload of the webhook contact point of Grafana's unified alerting, which extends AlertManager's payload with its own fields.
+// https://grafana.com/docs/grafana/latest/alerting/configure-notifications/manage-contact-points/integrations/webhook-notifier/
+type Grafana struct{}
+
+func (Grafana) Format() string {
+	return FormatGrafana
+}
+
+func (Grafana) Detect(b []byte) bool {
+	amo, err := unmarshalOut(b)
+	return err == nil && amo.IsGrafana()
+}
+
+func (Grafana) Decode(b []byte) (*alertmanager.Out, error) {
+	return AlertManager{}.Decode(b)
+}
+
+func unmarshalOut(b []byte) (*alertmanager.Out, error) {
+	amo := &alertmanager.Out{}
+	if err := json.Unmarshal(b, amo); err != nil {
+		return nil, fmt.Errorf("unable to parse the AlertManager webhook payload: %w", err)
+	}
+	return amo, nil
+}
@@ -0,0 +1,101 @@
+package decoder
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+)
+
+// The formats of the built-in decoders.
+const (
+	FormatAlertManager = "alertmanager"
+	FormatGrafana      = "grafana"
+	FormatPrometheus   = "prometheus"
+	FormatJSON         = "json"
+)
+
+// Decoder decodes the body of a request from a source of alerts into a notification.
+// Notifications of every format are forwarded as if they had been sent by AlertManager, so that they are routed and rendered alike.
+type Decoder interface {
+	// Format is the name by which the decoder is selected, e.g. by the 'format' of a receiver.
+	Format() string
+	// Detect returns true if the body appears to be of the decoder's format.
+	Detect(b []byte) bool
+	// Decode decodes the body into a notification.
+	// A *MisconfigurationError is returned if the body indicates that its sender has been misconfigured, rather than that it is invalid.
+	Decode(b []byte) (*alertmanager.Out, error)
+}
+
+// MisconfigurationError indicates that the sender of a body, rather than the body itself, is at fault.
+// Its sender is expected to be corrected, so a warning is posted to Discord in place of the notification.
+type MisconfigurationError struct {
+	Format      string
+	Title       string
+	Description string
+}
+
+func (e *MisconfigurationError) Error() string {
+	return fmt.Sprintf("the notification of format ('%s') indicates that its sender has been misconfigured", e.Format)
+}
+
+// Registry holds decoders, in the order in which their formats are detected.
+type Registry struct {
+	decoders []Decoder
+}
+
+// NewRegistry creates a registry of the decoders, whose formats are detected in the order provided.
+func NewRegistry(decoders ...Decoder) *Registry {
+	r := &Registry{}
+	for _, d := range decoders {
+		r.Register(d)
+	}
+	return r
+}
+
+// Default returns a registry of the built-in decoders.
+// Grafana's payload and the generic JSON format are detected before AlertManager's, as AlertManager's decoder accepts any JSON object.
+func Default() *Registry {
+	return NewRegistry(Grafana{}, JSON{}, AlertManager{}, Prometheus{})
+}
+
+// Register adds the decoder, to be detected after those already registered. A decoder of the same format is replaced in place.
+func (r *Registry) Register(d Decoder) {
+	for i, existing := range r.decoders {
+		if existing.Format() == d.Format() {
+			r.decoders[i] = d
+			return
+		}
+	}
+	r.decoders = append(r.decoders, d)
+}
+
+// Lookup returns the decoder of the format.
+func (r *Registry) Lookup(format string) (Decoder, bool) {
+	for _, d := range r.decoders {
+		if d.Format() == format {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// Detect returns the first decoder which detects the body as its format.
+func (r *Registry) Detect(b []byte) (Decoder, bool) {
+	for _, d := range r.decoders {
+		if d.Detect(b) {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// Formats returns the formats of the registered decoders, sorted by name.
+func (r *Registry) Formats() []string {
+	formats := make([]string, 0, len(r.decoders))
+	for _, d := range r.decoders {
+		formats = append(formats, d.Format())
+	}
+	sort.Strings(formats)
+	return formats
+}
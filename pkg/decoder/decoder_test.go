@@ -0,0 +1,128 @@
+package decoder
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Default_Detect_SelectsTheDecoderOfEachFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		format string
+	}{
+		{name: "alertmanager", body: `{"version": "4", "status": "firing", "alerts": [{"status": "firing"}]}`, format: FormatAlertManager},
+		{name: "alertmanager without alerts", body: `{}`, format: FormatAlertManager},
+		{name: "alertmanager with empty times", body: `{"version": "4", "status": "firing", "alerts": [{"status": "firing", "startsAt": "", "endsAt": ""}]}`, format: FormatAlertManager},
+		{name: "grafana", body: `{"version": "1", "orgId": 1, "state": "alerting", "title": "[FIRING:1] HighCPU", "alerts": []}`, format: FormatGrafana},
+		{name: "prometheus", body: `[{"labels": {"alertname": "HighCPU"}}]`, format: FormatPrometheus},
+		{name: "generic json", body: `{"title": "Backup failed"}`, format: FormatJSON},
+	}
+
+	SUT := Default()
+	for _, tt := range tests {
+		d, ok := SUT.Detect([]byte(tt.body))
+		if assert.True(t, ok, "format of %s should be detected", tt.name) {
+			assert.Equal(t, tt.format, d.Format(), "format of %s", tt.name)
+			if tt.format != FormatPrometheus {
+				_, err := d.Decode([]byte(tt.body))
+				assert.NoError(t, err, "%s should be decoded", tt.name)
+			}
+		}
+	}
+
+	_, ok := SUT.Detect([]byte("not json"))
+	assert.False(t, ok, "invalid json should not be detected as any format")
+}
+
+func Test_Registry_Register_ReplacesDecoderOfTheSameFormat(t *testing.T) {
+	SUT := NewRegistry(AlertManager{}, Prometheus{})
+	SUT.Register(JSON{})
+	SUT.Register(AlertManager{})
+
+	assert.Equal(t, []string{FormatAlertManager, FormatJSON, FormatPrometheus}, SUT.Formats(), "formats")
+	_, ok := SUT.Lookup(FormatGrafana)
+	assert.False(t, ok, "grafana has not been registered")
+
+	// the json decoder was registered after the alertmanager decoder, which accepts any JSON object
+	d, _ := SUT.Detect([]byte(`{"title": "Backup failed"}`))
+	assert.Equal(t, FormatAlertManager, d.Format(), "decoders should be detected in the order in which they were registered")
+}
+
+func Test_AlertManager_Decode_UnsupportedVersion_ReturnsError(t *testing.T) {
+	_, err := AlertManager{}.Decode([]byte(`{"version": "5"}`))
+	assert.Error(t, err, "version 5 should not be supported")
+
+	_, err = AlertManager{}.Decode([]byte(`[]`))
+	assert.Error(t, err, "an array is not an AlertManager payload")
+}
+
+func Test_Prometheus_Decode_ReturnsMisconfigurationError(t *testing.T) {
+	_, err := Prometheus{}.Decode([]byte(`[{"labels": {"alertname": "HighCPU"}}]`))
+
+	var misconfiguration *MisconfigurationError
+	assert.True(t, errors.As(err, &misconfiguration), "raw Prometheus alerts indicate that Prometheus has been misconfigured")
+	assert.Equal(t, FormatPrometheus, misconfiguration.Format, "format")
+	assert.Contains(t, misconfiguration.Description, "misconfigured", "warning")
+}
+
+func Test_JSON_Decode_MapsToASingleAlert(t *testing.T) {
+	SUT, err := JSON{}.Decode([]byte(`{
+		"title": "Backup failed",
+		"status": "resolved",
+		"description": "The nightly backup exited with status 1.",
+		"url": "https://ci.example.com/jobs/1",
+		"labels": {"job": "backup"},
+		"annotations": {"runbook_url": "https://example.com/runbooks/backup"}
+	}`))
+
+	assert.NoError(t, err, "decoding")
+	assert.Equal(t, alertmanager.StatusResolved, SUT.Status, "status")
+	assert.Equal(t, `{}:{alertname="Backup failed"}`, SUT.GroupKey, "group key")
+	assert.Equal(t, map[string]string{"alertname": "Backup failed", "job": "backup"}, SUT.CommonLabels, "common labels")
+	assert.Equal(t, 1, len(SUT.Alerts), "number of alerts")
+
+	alert := SUT.Alerts[0]
+	assert.Equal(t, alertmanager.StatusResolved, alert.Status, "alert status")
+	assert.Equal(t, "The nightly backup exited with status 1.", alert.Annotations["description"], "description annotation")
+	assert.Equal(t, "https://example.com/runbooks/backup", alert.Annotations["runbook_url"], "annotations")
+	assert.Equal(t, "https://ci.example.com/jobs/1", alert.GeneratorURL, "generator url")
+	assert.False(t, alert.EndsAt.IsZero(), "resolved alert should have ended")
+}
+
+func Test_JSON_Decode_InvalidNotification_ReturnsError(t *testing.T) {
+	_, err := JSON{}.Decode([]byte(`{"title": ""}`))
+	assert.Error(t, err, "a title or alertname label is required")
+
+	_, err = JSON{}.Decode([]byte(`{"title": "Backup failed", "status": "pending"}`))
+	assert.Error(t, err, "status must be firing or resolved")
+}
+
+func Test_Prometheus_Forward_DecodesFiringAndResolvedAlerts(t *testing.T) {
+	body := `[
+		{"labels": {"alertname": "HighCPU", "instance": "web-1"}, "annotations": {"summary": "CPU is high"}, "startsAt": "2024-01-02T03:04:05Z", "endsAt": "2999-01-01T00:00:00Z", "generatorURL": "https://prometheus.example.com/graph"},
+		{"labels": {"alertname": "HighCPU", "instance": "web-2"}, "annotations": {"summary": "CPU is high"}, "startsAt": "2024-01-02T03:04:05Z", "endsAt": "2024-01-02T03:10:00Z"}
+	]`
+	SUT := Prometheus{Forward: true}
+
+	amo, err := SUT.Decode([]byte(body))
+
+	assert.NoError(t, err, "decoding")
+	assert.Equal(t, DefaultPrometheusGroupWait, SUT.GroupWait(), "forwarded alerts should be grouped")
+	assert.Equal(t, alertmanager.StatusFiring, amo.Status, "notification is firing while any alert is firing")
+	assert.Equal(t, 2, len(amo.Alerts), "number of alerts")
+	assert.Equal(t, alertmanager.StatusFiring, amo.Alerts[0].Status, "alert which ends in the future is firing")
+	assert.True(t, amo.Alerts[0].EndsAt.IsZero(), "firing alert should not have ended")
+	assert.Equal(t, "https://prometheus.example.com/graph", amo.Alerts[0].GeneratorURL, "generator url")
+	assert.Equal(t, alertmanager.StatusResolved, amo.Alerts[1].Status, "alert which has ended is resolved")
+	assert.Equal(t, "CPU is high", amo.CommonAnnotations["summary"], "common annotations")
+
+	_, err = SUT.Decode([]byte(`[{"labels": {"instance": "web-1"}}]`))
+	assert.Error(t, err, "alerts require an alertname")
+	assert.Equal(t, time.Duration(0), Prometheus{}.GroupWait(), "alerts which are not forwarded are not grouped")
+}
@@ -0,0 +1,94 @@
+package decoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+)
+
+// JSON decodes a generic JSON format, for senders which are not AlertManager, e.g. scripts or CI pipelines:
+//
+//	{"title": "Backup failed", "status": "firing", "description": "...", "url": "https://...", "labels": {...}, "annotations": {...}}
+//
+// Only the title, or an 'alertname' label, is required. The notification contains a single alert, named by the title.
+type JSON struct{}
+
+// genericAlert is the generic JSON format.
+type genericAlert struct {
+	Title       string            `json:"title"`
+	Status      string            `json:"status"`
+	Description string            `json:"description"`
+	URL         string            `json:"url"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+func (JSON) Format() string {
+	return FormatJSON
+}
+
+// Detect accepts JSON objects with a title, which AlertManager's payload does not have, and without alerts, which both AlertManager's and Grafana's payloads have.
+func (JSON) Detect(b []byte) bool {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return false
+	}
+	_, hasTitle := fields["title"]
+	_, hasAlerts := fields["alerts"]
+	return hasTitle && !hasAlerts
+}
+
+func (JSON) Decode(b []byte) (*alertmanager.Out, error) {
+	ga := genericAlert{}
+	if err := json.Unmarshal(b, &ga); err != nil {
+		return nil, fmt.Errorf("unable to parse the generic JSON notification: %w", err)
+	}
+
+	labels := make(map[string]string, len(ga.Labels)+1)
+	for name, value := range ga.Labels {
+		labels[name] = value
+	}
+	if labels["alertname"] == "" {
+		labels["alertname"] = ga.Title
+	}
+	if labels["alertname"] == "" {
+		return nil, fmt.Errorf("the generic JSON notification requires a title, or an 'alertname' label")
+	}
+
+	annotations := make(map[string]string, len(ga.Annotations)+1)
+	for name, value := range ga.Annotations {
+		annotations[name] = value
+	}
+	if ga.Description != "" && annotations["description"] == "" {
+		annotations["description"] = ga.Description
+	}
+
+	status := ga.Status
+	if status == "" {
+		status = alertmanager.StatusFiring
+	}
+	if status != alertmanager.StatusFiring && status != alertmanager.StatusResolved {
+		return nil, fmt.Errorf("status ('%s') of the generic JSON notification must be either '%s' or '%s'", status, alertmanager.StatusFiring, alertmanager.StatusResolved)
+	}
+	startsAt := ga.StartsAt
+	if startsAt.IsZero() {
+		startsAt = time.Now()
+	}
+	endsAt := ga.EndsAt
+	if status == alertmanager.StatusResolved && endsAt.IsZero() {
+		endsAt = time.Now()
+	}
+
+	return alertmanager.NewOut("", []string{"alertname"}, []alertmanager.Alert{{
+		Status:       status,
+		Labels:       labels,
+		Annotations:  annotations,
+		StartsAt:     startsAt,
+		EndsAt:       endsAt,
+		GeneratorURL: ga.URL,
+	}}), nil
+}
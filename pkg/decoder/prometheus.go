@@ -0,0 +1,113 @@
+package decoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+	"github.com/specklesystems/alertmanager-discord/pkg/prometheus"
+)
+
+// DefaultPrometheusGroupWait is how long alerts which are forwarded are grouped, unless configured otherwise.
+const DefaultPrometheusGroupWait = 10 * time.Second
+
+const prometheusWarning = `You have probably misconfigured this software.
+We detected input in Prometheus Alert format but are expecting AlertManager format.
+This program is intended to ingest alerts from alertmanager.
+It is not a replacement for alertmanager, it is a
+webhook target for it. Please read the README.md
+for guidance on how to configure it for alertmanager
+or https://prometheus.io/docs/alerting/latest/configuration/#webhook_config`
+
+// Grouper is implemented by decoders of formats whose senders do not group alerts themselves, e.g. Prometheus.
+// The alerts of such notifications are grouped in-process by alertname, and forwarded once the group wait has elapsed.
+type Grouper interface {
+	// GroupWait is how long the alerts of a group are buffered before being forwarded together. Alerts are not grouped if it is zero.
+	GroupWait() time.Duration
+}
+
+// Prometheus detects alerts sent directly by Prometheus, rather than via AlertManager.
+// By default, such alerts indicate that Prometheus has been misconfigured, so they are not decoded; a warning is posted to Discord instead.
+type Prometheus struct {
+	// Forward, if true, decodes the alerts so that they are forwarded to Discord, e.g. where Prometheus is run without AlertManager.
+	Forward bool
+	// Wait is how long alerts which are forwarded are grouped by alertname before being sent. Defaults to DefaultPrometheusGroupWait.
+	Wait time.Duration
+}
+
+func (Prometheus) Format() string {
+	return FormatPrometheus
+}
+
+func (Prometheus) Detect(b []byte) bool {
+	return prometheus.IsAlert(b)
+}
+
+func (p Prometheus) GroupWait() time.Duration {
+	if !p.Forward {
+		return 0
+	}
+	if p.Wait <= 0 {
+		return DefaultPrometheusGroupWait
+	}
+	return p.Wait
+}
+
+func (p Prometheus) Decode(b []byte) (*alertmanager.Out, error) {
+	if !p.Forward {
+		return nil, &MisconfigurationError{
+			Format:      FormatPrometheus,
+			Title:       "You have misconfigured this software",
+			Description: prometheusWarning,
+		}
+	}
+
+	var promAlerts []prometheus.Alert
+	if err := json.Unmarshal(b, &promAlerts); err != nil {
+		return nil, fmt.Errorf("unable to parse the Prometheus alerts: %w", err)
+	}
+
+	now := time.Now()
+	alerts := make([]alertmanager.Alert, 0, len(promAlerts))
+	for i, promAlert := range promAlerts {
+		alert, err := convertPrometheusAlert(promAlert, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Prometheus alert at index ('%d'): %w", i, err)
+		}
+		alerts = append(alerts, alert)
+	}
+	return alertmanager.NewOut("", nil, alerts), nil
+}
+
+// convertPrometheusAlert converts the alert, which is resolved if it ended before now.
+// Prometheus sends firing alerts with an end in the future, after which they are considered resolved unless sent again.
+func convertPrometheusAlert(promAlert prometheus.Alert, now time.Time) (alertmanager.Alert, error) {
+	alert := alertmanager.Alert{
+		Status:       alertmanager.StatusFiring,
+		Labels:       promAlert.Labels,
+		Annotations:  promAlert.Annotations,
+		GeneratorURL: promAlert.GeneratorURL,
+	}
+	if alert.Labels["alertname"] == "" {
+		return alertmanager.Alert{}, fmt.Errorf("the alert does not have an 'alertname' label")
+	}
+
+	var err error
+	if promAlert.StartsAt != "" {
+		if alert.StartsAt, err = time.Parse(time.RFC3339, promAlert.StartsAt); err != nil {
+			return alertmanager.Alert{}, fmt.Errorf("unable to parse startsAt: %w", err)
+		}
+	}
+	if promAlert.EndsAt != "" {
+		endsAt, err := time.Parse(time.RFC3339, promAlert.EndsAt)
+		if err != nil {
+			return alertmanager.Alert{}, fmt.Errorf("unable to parse endsAt: %w", err)
+		}
+		if !endsAt.IsZero() && !endsAt.After(now) {
+			alert.Status = alertmanager.StatusResolved
+			alert.EndsAt = endsAt
+		}
+	}
+	return alert, nil
+}
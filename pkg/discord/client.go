@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	backoff "github.com/cenkalti/backoff/v4"
@@ -19,6 +22,7 @@ type Client struct {
 	httpClient                *http.Client
 	URL                       string
 	maximumBackoffElapsedTime time.Duration
+	rateLimiter               rateLimiter
 }
 
 func NewClient(client *http.Client, url string, maximumBackoffElapsedTime time.Duration) *Client {
@@ -47,29 +51,144 @@ func NewClient(client *http.Client, url string, maximumBackoffElapsedTime time.D
 	}
 }
 
+// PublishMessage posts the message to the Discord webhook.
+// Transport errors, 429 Too Many Requests, and 5xx responses are retried with exponential backoff, waiting for at least as long as requested by Discord's rate limit headers.
+// Any other response is returned without retrying. If the retries are exhausted, the last response (if any) is returned along with an error.
 func (dc *Client) PublishMessage(message Out) (*http.Response, error) {
+	return dc.send(http.MethodPost, dc.URL, message)
+}
+
+// PublishMessageAndWait posts the message to the Discord webhook, waiting for Discord to confirm the message has been created.
+// If a thread id is provided, the message is posted within that thread.
+// The created message is returned if Discord responded successfully, so that it may be edited later.
+// Responses are retried as for PublishMessage; the body of the returned response has already been read and closed.
+func (dc *Client) PublishMessageAndWait(message Out, threadID string) (*Message, *http.Response, error) {
+	u, err := dc.webhookURL("", threadID)
+	if err != nil {
+		return nil, nil, err
+	}
+	query := u.Query()
+	query.Set("wait", "true")
+	u.RawQuery = query.Encode()
+
+	res, err := dc.send(http.MethodPost, u.String(), message)
+	if err != nil || res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, res, err
+	}
+	defer CloseBody(res)
+
+	created := &Message{}
+	if err := json.NewDecoder(res.Body).Decode(created); err != nil {
+		return nil, res, fmt.Errorf("unable to parse the message created by Discord: %w", err)
+	}
+	return created, res, nil
+}
+
+// EditMessage replaces the content and embeds of a message previously published by the Discord webhook.
+// If the message is within a thread, the thread id must be provided.
+// Responses are retried as for PublishMessage.
+func (dc *Client) EditMessage(messageID, threadID string, message Out) (*http.Response, error) {
+	u, err := dc.webhookURL("/messages/"+messageID, threadID)
+	if err != nil {
+		return nil, err
+	}
+
+	return dc.send(http.MethodPatch, u.String(), message)
+}
+
+// webhookURL returns the url of the webhook, with the path appended and the thread id (if any) added to the query.
+func (dc *Client) webhookURL(path, threadID string) (*url.URL, error) {
+	u, err := url.Parse(dc.URL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse Discord webhook url: %w", &redactedError{err: err, webhookURL: dc.URL})
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	if threadID != "" {
+		query := u.Query()
+		query.Set("thread_id", threadID)
+		u.RawQuery = query.Encode()
+	}
+	return u, nil
+}
+
+func (dc *Client) send(method, requestURL string, message Out) (*http.Response, error) {
 	DOD, err := json.Marshal(message)
 	if err != nil {
 		return nil, fmt.Errorf("Error encountered when marshalling object to json. We will not continue posting to Discord. Discord Out object: '%v+'. Error: %w", message, err)
 	}
 
+	// the bucket may already be exhausted by a previous message, so wait rather than being rejected by Discord
+	if wait := dc.rateLimiter.wait(); wait > 0 {
+		if wait > dc.maximumBackoffElapsedTime {
+			wait = dc.maximumBackoffElapsedTime
+		}
+		time.Sleep(wait)
+	}
+
 	var response *http.Response
 
+	exponential := backoff.NewExponentialBackOff()
+	exponential.MaxElapsedTime = dc.maximumBackoffElapsedTime
+	retryAfter := &retryAfterBackOff{ExponentialBackOff: exponential}
+
 	operation := func() error {
-		res, err := dc.httpClient.Post(dc.URL, "application/json", bytes.NewReader(DOD))
-		if err == nil {
-			response = res
+		if response != nil {
+			// the previous response is being retried, and will not be returned to the caller
+			CloseBody(response)
 		}
 
-		return err
+		req, err := http.NewRequest(method, requestURL, bytes.NewReader(DOD))
+		if err != nil {
+			return backoff.Permanent(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := dc.httpClient.Do(req)
+		if err != nil {
+			response = nil
+			return err
+		}
+		response = res
+
+		limit := ParseRateLimit(res)
+		dc.rateLimiter.update(limit)
+
+		switch {
+		case res.StatusCode == http.StatusTooManyRequests:
+			RateLimitedTotal.WithLabelValues(limit.Bucket, limitScope(limit)).Inc()
+			retryAfter.retryAfter = limit.RetryAfter
+			return fmt.Errorf("rate limited by Discord (bucket: '%s', scope: '%s'), retrying after %s", limit.Bucket, limitScope(limit), limit.RetryAfter)
+		case res.StatusCode >= 500:
+			return fmt.Errorf("Discord responded with status code %d", res.StatusCode)
+		}
+
+		return nil
 	}
 
-	exponential := backoff.NewExponentialBackOff()
-	exponential.MaxElapsedTime = dc.maximumBackoffElapsedTime
-	err = backoff.Retry(operation, exponential)
+	err = backoff.Retry(operation, retryAfter)
 	if err != nil {
-		return nil, fmt.Errorf("Error encountered sending POST to '%s'. Error: %w", dc.URL, err)
+		// the url of the webhook contains its token, so is redacted from the error, including from any transport error
+		return response, fmt.Errorf("Error encountered sending %s to '%s'. Error: %w", method, RedactURL(dc.URL), &redactedError{err: err, webhookURL: dc.URL})
 	}
 
 	return response, nil
 }
+
+func limitScope(limit RateLimit) string {
+	if limit.Global {
+		return "global"
+	}
+	if limit.Scope != "" {
+		return limit.Scope
+	}
+	return "user"
+}
+
+// CloseBody drains and closes the body of a response from Discord, so that the connection may be reused.
+func CloseBody(res *http.Response) {
+	if res.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+}
@@ -0,0 +1,134 @@
+package discord
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PublishMessage_RateLimited_RetriesAfterRequestedDuration(t *testing.T) {
+	var requests atomic.Int32
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderRateLimitBucket, "a_bucket")
+		if requests.Add(1) == 1 {
+			w.Header().Set(HeaderRetryAfter, "0.2")
+			w.Header().Set(HeaderRateLimitRemaining, "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set(HeaderRateLimitRemaining, "4")
+		w.Header().Set(HeaderRateLimitResetAfter, "1.5")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer mockDiscordServer.Close()
+
+	SUT := NewClient(&http.Client{}, mockDiscordServer.URL, 2*time.Second)
+
+	start := time.Now()
+	res, err := SUT.PublishMessage(Out{Content: "a_message"})
+	assert.NoError(t, err, "publishing message")
+	assert.Equal(t, http.StatusNoContent, res.StatusCode, "response status code")
+	assert.Equal(t, int32(2), requests.Load(), "should have retried the rate limited request")
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond, "should have waited for the Retry-After duration")
+
+	limit := ParseRateLimit(res)
+	assert.Equal(t, "a_bucket", limit.Bucket, "rate limit bucket")
+	assert.Equal(t, 4, limit.Remaining, "rate limit remaining")
+	assert.Equal(t, 1500*time.Millisecond, limit.ResetAfter, "rate limit reset after")
+}
+
+func Test_PublishMessage_RateLimited_RetryAfterInBody(t *testing.T) {
+	res := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(`{"message": "You are being rate limited.", "retry_after": 0.35, "global": true}`)),
+	}
+
+	limit := ParseRateLimit(res)
+	assert.Equal(t, 350*time.Millisecond, limit.RetryAfter, "retry after should be read from the body")
+	assert.True(t, limit.Global, "global rate limit should be read from the body")
+	assert.Equal(t, bucketUnknown, limit.Bucket, "rate limit bucket")
+}
+
+func Test_PublishMessage_ServerError_RetriesWithBackoff(t *testing.T) {
+	var requests atomic.Int32
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockDiscordServer.Close()
+
+	SUT := NewClient(&http.Client{}, mockDiscordServer.URL, 5*time.Second)
+
+	res, err := SUT.PublishMessage(Out{Content: "a_message"})
+	assert.NoError(t, err, "publishing message")
+	assert.Equal(t, http.StatusOK, res.StatusCode, "response status code")
+	assert.Equal(t, int32(3), requests.Load(), "should have retried the failed requests")
+}
+
+func Test_PublishMessage_ServerError_ReturnsErrorWhenBackoffExhausted(t *testing.T) {
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer mockDiscordServer.Close()
+
+	SUT := NewClient(&http.Client{}, mockDiscordServer.URL, 100*time.Millisecond)
+
+	res, err := SUT.PublishMessage(Out{Content: "a_message"})
+	assert.Error(t, err, "publishing message should fail once retries are exhausted")
+	assert.NotNil(t, res, "last response should be returned")
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode, "response status code")
+}
+
+func Test_PublishMessage_RetryAfterExceedsMaximumBackoff_DoesNotWait(t *testing.T) {
+	var requests atomic.Int32
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set(HeaderRetryAfter, "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer mockDiscordServer.Close()
+
+	SUT := NewClient(&http.Client{}, mockDiscordServer.URL, 500*time.Millisecond)
+
+	start := time.Now()
+	_, err := SUT.PublishMessage(Out{Content: "a_message"})
+	assert.Error(t, err, "publishing message should fail")
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "should not wait longer than the maximum backoff time")
+	assert.Equal(t, int32(1), requests.Load(), "should not have retried")
+}
+
+func Test_PublishMessage_ClientError_IsNotRetried(t *testing.T) {
+	var requests atomic.Int32
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer mockDiscordServer.Close()
+
+	SUT := NewClient(&http.Client{}, mockDiscordServer.URL, time.Second)
+
+	res, err := SUT.PublishMessage(Out{Content: "a_message"})
+	assert.NoError(t, err, "client errors are returned as a response")
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "response status code")
+	assert.Equal(t, int32(1), requests.Load(), "should not have retried")
+}
+
+func Test_PublishMessage_TransportError_DoesNotContainToken(t *testing.T) {
+	// nothing listens on the port, so the transport error contains the url
+	SUT := NewClient(&http.Client{}, "http://127.0.0.1:1/api/webhooks/123456789123456789/a_secret_token", 100*time.Millisecond)
+
+	_, err := SUT.PublishMessage(Out{Content: "a_message"})
+	assert.Error(t, err, "publishing message should fail")
+	assert.NotContains(t, err.Error(), "a_secret_token", "error should not contain the token of the webhook")
+	assert.Contains(t, err.Error(), "/api/webhooks/123456789123456789/REDACTED", "error should identify the webhook")
+}
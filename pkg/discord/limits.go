@@ -0,0 +1,169 @@
+package discord
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Discord message limits, https://discord.com/developers/docs/resources/message#embed-object-embed-limits
+const (
+	LimitContent          = 2000
+	LimitEmbedsPerMessage = 10
+	LimitEmbedTitle       = 256
+	LimitEmbedDescription = 4096
+	LimitFieldsPerEmbed   = 25
+	LimitFieldName        = 256
+	LimitFieldValue       = 1024
+	// LimitEmbedsTotal is the limit of the sum of all characters of all embeds within a message.
+	LimitEmbedsTotal = 6000
+	LimitThreadName  = 100
+)
+
+const (
+	ellipsis = "…"
+	// emptyValue replaces empty field names and values, which Discord rejects.
+	emptyValue = "-"
+	// partMarkerReserve is the number of characters reserved for the part marker which may be added to the title.
+	partMarkerReserve = len(" (part 99/99)")
+)
+
+// Split truncates any values exceeding Discord's limits, and divides the embeds of the message across as many embeds and messages as are required to remain within Discord's limits.
+// If more than one message is required, the title of the first embed of each message is marked with the part number, e.g. '(part 2/3)'.
+// The content of the message is only included in the first message.
+func Split(message Out) []Out {
+	message.Content = Truncate(message.Content, LimitContent)
+
+	var chunks []embedChunk
+	for _, embed := range message.Embeds {
+		chunks = append(chunks, splitEmbed(truncateEmbed(embed))...)
+	}
+
+	// pack the chunks into as few messages as possible
+	var messages []Out
+	current := Out{Content: message.Content}
+	currentSize := partMarkerReserve
+	for _, chunk := range chunks {
+		if len(current.Embeds) > 0 && (len(current.Embeds) >= LimitEmbedsPerMessage || currentSize+chunk.size(false) > LimitEmbedsTotal) {
+			messages = append(messages, current)
+			current = Out{}
+			currentSize = partMarkerReserve
+		}
+
+		embed := chunk.embed
+		if len(current.Embeds) == 0 && chunk.continuation {
+			// each message should begin with the title, so that it may be understood without the previous message
+			embed.Title = chunk.title
+		}
+		currentSize += chunk.size(len(current.Embeds) == 0)
+		current.Embeds = append(current.Embeds, embed)
+	}
+	messages = append(messages, current)
+
+	if len(messages) > 1 {
+		for i := range messages {
+			if len(messages[i].Embeds) == 0 {
+				continue
+			}
+			marker := fmt.Sprintf(" (part %d/%d)", i+1, len(messages))
+			embed := &messages[i].Embeds[0]
+			embed.Title = Truncate(embed.Title, LimitEmbedTitle-utf8.RuneCountInString(marker)) + marker
+		}
+	}
+
+	return messages
+}
+
+// Truncate shortens the string to at most the limit of characters, replacing the end with an ellipsis if it is truncated.
+func Truncate(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	if limit <= 0 {
+		return ""
+	}
+
+	runes := []rune(s)
+	return string(runes[:limit-1]) + ellipsis
+}
+
+func truncateEmbed(embed Embed) Embed {
+	embed.Title = Truncate(embed.Title, LimitEmbedTitle)
+	embed.Description = Truncate(embed.Description, LimitEmbedDescription)
+
+	fields := make([]EmbedField, 0, len(embed.Fields))
+	for _, field := range embed.Fields {
+		if field.Name == "" {
+			field.Name = emptyValue
+		}
+		if field.Value == "" {
+			field.Value = emptyValue
+		}
+		field.Name = Truncate(field.Name, LimitFieldName)
+		field.Value = Truncate(field.Value, LimitFieldValue)
+		fields = append(fields, field)
+	}
+	embed.Fields = fields
+
+	return embed
+}
+
+// embedChunk is part of an embed which, on its own, is within Discord's limits.
+type embedChunk struct {
+	embed Embed
+	// continuation chunks do not have a title, unless they begin a new message
+	continuation bool
+	title        string
+}
+
+// size is the number of characters counted towards the total limit of a message.
+// The title is counted for continuation chunks, in case they begin a message.
+func (c embedChunk) size(beginsMessage bool) int {
+	size := embedSize(c.embed)
+	if c.continuation && beginsMessage {
+		size += utf8.RuneCountInString(c.title)
+	}
+	return size
+}
+
+func embedSize(embed Embed) int {
+	size := utf8.RuneCountInString(embed.Title) + utf8.RuneCountInString(embed.Description)
+	for _, field := range embed.Fields {
+		size += utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+	}
+	return size
+}
+
+func splitEmbed(embed Embed) []embedChunk {
+	// reserve room for the title and part marker, which may be added to any chunk
+	titleSize := utf8.RuneCountInString(embed.Title) + partMarkerReserve
+
+	first := embed
+	first.Fields = []EmbedField{}
+	// the image is displayed below the fields, so belongs to the last chunk
+	first.Image = nil
+	chunks := []embedChunk{{embed: first, title: embed.Title}}
+	current := &chunks[0]
+	currentSize := titleSize + utf8.RuneCountInString(embed.Description)
+
+	for _, field := range embed.Fields {
+		fieldSize := utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+		if len(current.embed.Fields) > 0 && (len(current.embed.Fields) >= LimitFieldsPerEmbed || currentSize+fieldSize > LimitEmbedsTotal) {
+			chunks = append(chunks, embedChunk{
+				embed: Embed{
+					Color:  embed.Color,
+					Fields: []EmbedField{},
+				},
+				continuation: true,
+				title:        embed.Title,
+			})
+			current = &chunks[len(chunks)-1]
+			currentSize = titleSize
+		}
+
+		current.embed.Fields = append(current.embed.Fields, field)
+		currentSize += fieldSize
+	}
+	current.embed.Image = embed.Image
+
+	return chunks
+}
@@ -0,0 +1,120 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Split_WithinLimits_IsUnchanged(t *testing.T) {
+	message := Out{
+		Content: "a_content",
+		Embeds: []Embed{
+			{
+				Title:       "a_title",
+				Description: "a_description",
+				Color:       ColorRed,
+				Fields:      []EmbedField{{Name: "a_name", Value: "a_value"}},
+			},
+		},
+	}
+
+	assert.Equal(t, []Out{message}, Split(message), "message within limits should not be changed")
+}
+
+func Test_Split_TooManyFields_SplitsAcrossEmbeds(t *testing.T) {
+	message := Out{
+		Content: "a_content",
+		Embeds:  []Embed{{Title: "a_title", Color: ColorRed, Fields: fields(40, 10)}},
+	}
+
+	SUT := Split(message)
+
+	assert.Equal(t, 1, len(SUT), "number of messages")
+	assert.Equal(t, 2, len(SUT[0].Embeds), "number of embeds")
+	assert.Equal(t, "a_title", SUT[0].Embeds[0].Title, "first embed title")
+	assert.Equal(t, "", SUT[0].Embeds[1].Title, "continuation embed should not repeat the title")
+	assert.Equal(t, ColorRed, SUT[0].Embeds[1].Color, "continuation embed color")
+	assert.Equal(t, 25, len(SUT[0].Embeds[0].Fields), "number of fields in first embed")
+	assert.Equal(t, 15, len(SUT[0].Embeds[1].Fields), "number of fields in second embed")
+	assertWithinLimits(t, SUT)
+}
+
+func Test_Split_TooManyCharacters_SplitsAcrossMessages(t *testing.T) {
+	message := Out{
+		Content: "a_content",
+		Embeds:  []Embed{{Title: "a_title", Description: "a_description", Color: ColorRed, Fields: fields(40, 1000)}},
+	}
+
+	SUT := Split(message)
+
+	assert.Equal(t, 8, len(SUT), "number of messages")
+	assert.Equal(t, "a_content", SUT[0].Content, "content of first message")
+	for i, message := range SUT {
+		assert.Equal(t, fmt.Sprintf("a_title (part %d/8)", i+1), message.Embeds[0].Title, "title should include the part marker")
+		if i > 0 {
+			assert.Equal(t, "", message.Content, "content should only be included in the first message")
+		}
+	}
+	assertWithinLimits(t, SUT)
+
+	total := 0
+	for _, message := range SUT {
+		for _, embed := range message.Embeds {
+			total += len(embed.Fields)
+		}
+	}
+	assert.Equal(t, 40, total, "all fields should be retained")
+}
+
+func Test_Split_LongValues_AreTruncatedWithEllipsis(t *testing.T) {
+	message := Out{
+		Content: strings.Repeat("c", 2500),
+		Embeds: []Embed{{
+			Title:  strings.Repeat("t", 300),
+			Fields: []EmbedField{{Name: strings.Repeat("n", 300), Value: strings.Repeat("é", 2000)}, {}},
+		}},
+	}
+
+	SUT := Split(message)
+
+	assert.Equal(t, 1, len(SUT), "number of messages")
+	assert.Equal(t, LimitContent, utf8.RuneCountInString(SUT[0].Content), "content length")
+	assert.True(t, strings.HasSuffix(SUT[0].Content, "…"), "truncated content should end with an ellipsis")
+	assert.Equal(t, LimitEmbedTitle, utf8.RuneCountInString(SUT[0].Embeds[0].Title), "title length")
+	assert.Equal(t, LimitFieldName, utf8.RuneCountInString(SUT[0].Embeds[0].Fields[0].Name), "field name length")
+	assert.Equal(t, LimitFieldValue, utf8.RuneCountInString(SUT[0].Embeds[0].Fields[0].Value), "field value length")
+	assert.Equal(t, EmbedField{Name: "-", Value: "-"}, SUT[0].Embeds[0].Fields[1], "empty fields should be replaced")
+	assertWithinLimits(t, SUT)
+}
+
+func fields(count, valueLength int) []EmbedField {
+	fields := make([]EmbedField, 0, count)
+	for i := 0; i < count; i++ {
+		fields = append(fields, EmbedField{Name: fmt.Sprintf("field %d", i), Value: strings.Repeat("v", valueLength)})
+	}
+	return fields
+}
+
+func assertWithinLimits(t *testing.T, messages []Out) {
+	t.Helper()
+	for _, message := range messages {
+		assert.LessOrEqual(t, utf8.RuneCountInString(message.Content), LimitContent, "content length")
+		assert.LessOrEqual(t, len(message.Embeds), LimitEmbedsPerMessage, "number of embeds")
+		total := 0
+		for _, embed := range message.Embeds {
+			assert.LessOrEqual(t, utf8.RuneCountInString(embed.Title), LimitEmbedTitle, "title length")
+			assert.LessOrEqual(t, utf8.RuneCountInString(embed.Description), LimitEmbedDescription, "description length")
+			assert.LessOrEqual(t, len(embed.Fields), LimitFieldsPerEmbed, "number of fields")
+			for _, field := range embed.Fields {
+				assert.LessOrEqual(t, utf8.RuneCountInString(field.Name), LimitFieldName, "field name length")
+				assert.LessOrEqual(t, utf8.RuneCountInString(field.Value), LimitFieldValue, "field value length")
+			}
+			total += embedSize(embed)
+		}
+		assert.LessOrEqual(t, total, LimitEmbedsTotal, "total characters of embeds")
+	}
+}
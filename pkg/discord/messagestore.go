@@ -0,0 +1,300 @@
+package discord
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMessageStateTTL = 7 * 24 * time.Hour
+)
+
+// MessageState is a message published by a webhook for a group of alerts, which may later be edited.
+type MessageState struct {
+	Webhook   string `json:"webhook"`
+	GroupKey  string `json:"groupKey"`
+	MessageID string `json:"messageId"`
+	// Fingerprints are of the alerts included within the message.
+	Fingerprints []string `json:"fingerprints"`
+	// Resolved are the fingerprints of the alerts which have since resolved.
+	Resolved []string `json:"resolved,omitempty"`
+	// Message is the message as it was published.
+	Message   Out       `json:"message"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// IsResolved returns true if all alerts within the message have resolved.
+func (ms MessageState) IsResolved() bool {
+	resolved := make(map[string]bool, len(ms.Resolved))
+	for _, fingerprint := range ms.Resolved {
+		resolved[fingerprint] = true
+	}
+	for _, fingerprint := range ms.Fingerprints {
+		if !resolved[fingerprint] {
+			return false
+		}
+	}
+	return true
+}
+
+// MessageStore records the messages published for alerts, keyed by webhook, AlertManager group key, and alert fingerprint,
+// and the thread in which each group of alerts is posted, keyed by webhook and AlertManager group key.
+type MessageStore interface {
+	// Get returns the message which was most recently published by the webhook for the alert within the group.
+	Get(webhook, groupKey, fingerprint string) (MessageState, bool)
+	// Put records the message against each of its fingerprints, replacing any existing state of the message.
+	Put(state MessageState) error
+	// Delete removes the message.
+	Delete(state MessageState) error
+	// GetThread returns the thread in which the webhook posts the alerts of the group.
+	GetThread(webhook, groupKey string) (string, bool)
+	// PutThread records the thread in which the webhook posts the alerts of the group.
+	PutThread(webhook, groupKey, threadID string) error
+}
+
+type messageKey struct {
+	webhook     string
+	groupKey    string
+	fingerprint string
+}
+
+type threadKey struct {
+	webhook  string
+	groupKey string
+}
+
+// threadState is the thread created by a webhook for a group of alerts.
+type threadState struct {
+	Webhook   string    `json:"webhook"`
+	GroupKey  string    `json:"groupKey"`
+	ThreadID  string    `json:"threadId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// persistedState is the format of the file to which the state is persisted.
+type persistedState struct {
+	Messages []MessageState `json:"messages"`
+	Threads  []threadState  `json:"threads"`
+}
+
+// MemoryMessageStore is a MessageStore held in memory.
+// If it has a file, the state is persisted to the file after each change, so that messages may be edited, and threads reused, after a restart.
+// Messages and threads older than the TTL are discarded.
+type MemoryMessageStore struct {
+	file string
+	ttl  time.Duration
+
+	mu       sync.Mutex
+	messages map[string]MessageState
+	index    map[messageKey]string
+	threads  map[threadKey]threadState
+}
+
+// NewMemoryMessageStore creates a MessageStore which is not persisted.
+func NewMemoryMessageStore(ttl time.Duration) *MemoryMessageStore {
+	if ttl <= 0 {
+		ttl = DefaultMessageStateTTL
+	}
+	return &MemoryMessageStore{
+		ttl:      ttl,
+		messages: make(map[string]MessageState),
+		index:    make(map[messageKey]string),
+		threads:  make(map[threadKey]threadState),
+	}
+}
+
+// OpenFileMessageStore creates a MessageStore which is persisted to the file, loading any state already within it.
+func OpenFileMessageStore(file string, ttl time.Duration) (*MemoryMessageStore, error) {
+	store := NewMemoryMessageStore(ttl)
+	store.file = file
+
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read message state file ('%s'): %w", file, err)
+	}
+
+	persisted := persistedState{}
+	if err := json.Unmarshal(b, &persisted); err != nil {
+		return nil, fmt.Errorf("unable to parse message state file ('%s'): %w", file, err)
+	}
+	for _, state := range persisted.Messages {
+		store.add(state)
+	}
+	for _, thread := range persisted.Threads {
+		store.threads[threadKey{webhook: thread.Webhook, groupKey: thread.GroupKey}] = thread
+	}
+	store.prune(time.Now())
+
+	return store, nil
+}
+
+func (s *MemoryMessageStore) Get(webhook, groupKey, fingerprint string) (MessageState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.index[messageKey{webhook: webhook, groupKey: groupKey, fingerprint: fingerprint}]
+	if !ok {
+		return MessageState{}, false
+	}
+	state, ok := s.messages[id]
+	if !ok || time.Since(state.CreatedAt) > s.ttl {
+		return MessageState{}, false
+	}
+	return state, true
+}
+
+func (s *MemoryMessageStore) Put(state MessageState) error {
+	if state.CreatedAt.IsZero() {
+		state.CreatedAt = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(state.MessageID)
+	s.add(state)
+	s.prune(time.Now())
+	return s.save()
+}
+
+func (s *MemoryMessageStore) Delete(state MessageState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(state.MessageID)
+	return s.save()
+}
+
+func (s *MemoryMessageStore) GetThread(webhook, groupKey string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	thread, ok := s.threads[threadKey{webhook: webhook, groupKey: groupKey}]
+	if !ok || time.Since(thread.CreatedAt) > s.ttl {
+		return "", false
+	}
+	return thread.ThreadID, true
+}
+
+func (s *MemoryMessageStore) PutThread(webhook, groupKey, threadID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.threads[threadKey{webhook: webhook, groupKey: groupKey}] = threadState{
+		Webhook:   webhook,
+		GroupKey:  groupKey,
+		ThreadID:  threadID,
+		CreatedAt: time.Now(),
+	}
+	s.prune(time.Now())
+	return s.save()
+}
+
+// Len returns the number of messages within the store.
+func (s *MemoryMessageStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.messages)
+}
+
+func (s *MemoryMessageStore) add(state MessageState) {
+	superseded := make(map[string]bool)
+	s.messages[state.MessageID] = state
+	for _, fingerprint := range state.Fingerprints {
+		key := messageKey{webhook: state.Webhook, groupKey: state.GroupKey, fingerprint: fingerprint}
+		if previous, ok := s.index[key]; ok && previous != state.MessageID {
+			superseded[previous] = true
+		}
+		// a newer message for the same alert, e.g. a repeated notification, replaces the older message
+		s.index[key] = state.MessageID
+	}
+
+	// messages which are no longer the most recent message of any alert will never be edited
+	for id := range superseded {
+		if !s.isIndexed(id) {
+			delete(s.messages, id)
+		}
+	}
+}
+
+func (s *MemoryMessageStore) isIndexed(messageID string) bool {
+	state, ok := s.messages[messageID]
+	if !ok {
+		return false
+	}
+	for _, fingerprint := range state.Fingerprints {
+		if s.index[messageKey{webhook: state.Webhook, groupKey: state.GroupKey, fingerprint: fingerprint}] == messageID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryMessageStore) remove(messageID string) {
+	state, ok := s.messages[messageID]
+	if !ok {
+		return
+	}
+	delete(s.messages, messageID)
+	for _, fingerprint := range state.Fingerprints {
+		key := messageKey{webhook: state.Webhook, groupKey: state.GroupKey, fingerprint: fingerprint}
+		if s.index[key] == messageID {
+			delete(s.index, key)
+		}
+	}
+}
+
+func (s *MemoryMessageStore) prune(now time.Time) {
+	for id, state := range s.messages {
+		if now.Sub(state.CreatedAt) > s.ttl {
+			s.remove(id)
+		}
+	}
+	for key, thread := range s.threads {
+		if now.Sub(thread.CreatedAt) > s.ttl {
+			delete(s.threads, key)
+		}
+	}
+}
+
+// save persists the state atomically, by writing to a temporary file which is then renamed.
+func (s *MemoryMessageStore) save() error {
+	if s.file == "" {
+		return nil
+	}
+
+	persisted := persistedState{
+		Messages: make([]MessageState, 0, len(s.messages)),
+		Threads:  make([]threadState, 0, len(s.threads)),
+	}
+	for _, state := range s.messages {
+		persisted.Messages = append(persisted.Messages, state)
+	}
+	for _, thread := range s.threads {
+		persisted.Threads = append(persisted.Threads, thread)
+	}
+	b, err := json.Marshal(persisted)
+	if err != nil {
+		return fmt.Errorf("unable to marshal message state to json: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.file), 0o700); err != nil {
+		return fmt.Errorf("unable to create message state directory: %w", err)
+	}
+	tmp := s.file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("unable to write message state file ('%s'): %w", tmp, err)
+	}
+	if err := os.Rename(tmp, s.file); err != nil {
+		return fmt.Errorf("unable to rename message state file ('%s'): %w", tmp, err)
+	}
+	return nil
+}
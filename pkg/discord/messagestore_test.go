@@ -0,0 +1,60 @@
+package discord
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MessageStore_Put_IsPersistedToFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "messages.json")
+
+	store, err := OpenFileMessageStore(file, time.Hour)
+	assert.NoError(t, err, "opening message store")
+	err = store.Put(MessageState{
+		Webhook:      "a_webhook",
+		GroupKey:     "a_group_key",
+		MessageID:    "a_message_id",
+		Fingerprints: []string{"a_fingerprint", "another_fingerprint"},
+		Message:      Out{Content: "a_message"},
+	})
+	assert.NoError(t, err, "putting message state")
+
+	// simulates a restart
+	SUT, err := OpenFileMessageStore(file, time.Hour)
+	assert.NoError(t, err, "reopening message store")
+
+	state, ok := SUT.Get("a_webhook", "a_group_key", "another_fingerprint")
+	assert.True(t, ok, "message state should have been loaded from the file")
+	assert.Equal(t, "a_message_id", state.MessageID, "message id")
+	assert.Equal(t, "a_message", state.Message.Content, "message content")
+
+	_, ok = SUT.Get("another_webhook", "a_group_key", "another_fingerprint")
+	assert.False(t, ok, "message state should be keyed by webhook")
+}
+
+func Test_MessageStore_Put_NewerMessageSupersedesOlder(t *testing.T) {
+	SUT := NewMemoryMessageStore(time.Hour)
+
+	assert.NoError(t, SUT.Put(MessageState{GroupKey: "a_group_key", MessageID: "first", Fingerprints: []string{"a_fingerprint"}}), "putting first message")
+	assert.NoError(t, SUT.Put(MessageState{GroupKey: "a_group_key", MessageID: "second", Fingerprints: []string{"a_fingerprint"}}), "putting second message")
+
+	state, ok := SUT.Get("", "a_group_key", "a_fingerprint")
+	assert.True(t, ok, "message state should exist")
+	assert.Equal(t, "second", state.MessageID, "the most recent message should be returned")
+	assert.Equal(t, 1, SUT.Len(), "the superseded message should be removed")
+
+	assert.NoError(t, SUT.Delete(state), "deleting message")
+	_, ok = SUT.Get("", "a_group_key", "a_fingerprint")
+	assert.False(t, ok, "message state should have been deleted")
+}
+
+func Test_MessageState_IsResolved(t *testing.T) {
+	SUT := MessageState{Fingerprints: []string{"a", "b"}, Resolved: []string{"a"}}
+	assert.False(t, SUT.IsResolved(), "message with a firing alert should not be resolved")
+
+	SUT.Resolved = append(SUT.Resolved, "b")
+	assert.True(t, SUT.IsResolved(), "message in which all alerts have resolved")
+}
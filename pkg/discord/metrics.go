@@ -21,4 +21,19 @@ var (
 		Help:    "Duration of all http requests sent by the Discord client.",
 		Buckets: prometheus.DefBuckets,
 	}, []string{"code"})
+
+	RateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "discord_client_rate_limit_remaining",
+		Help: "The number of requests remaining in the Discord rate limit bucket, as last reported by Discord. -1 if unknown.",
+	}, []string{"bucket"})
+
+	RateLimitResetAfter = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "discord_client_rate_limit_reset_after_seconds",
+		Help: "The duration until the Discord rate limit bucket resets, as last reported by Discord.",
+	}, []string{"bucket"})
+
+	RateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "discord_client_rate_limited_total",
+		Help: "The total number of http requests sent by the Discord client which were rate limited (429 Too Many Requests) by Discord.",
+	}, []string{"bucket", "scope"})
 )
@@ -0,0 +1,150 @@
+package discord
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	backoff "github.com/cenkalti/backoff/v4"
+)
+
+// Discord rate limit headers, https://discord.com/developers/docs/topics/rate-limits#header-format
+const (
+	HeaderRetryAfter          = "Retry-After"
+	HeaderRateLimitBucket     = "X-RateLimit-Bucket"
+	HeaderRateLimitLimit      = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining  = "X-RateLimit-Remaining"
+	HeaderRateLimitResetAfter = "X-RateLimit-Reset-After"
+	HeaderRateLimitGlobal     = "X-RateLimit-Global"
+	HeaderRateLimitScope      = "X-RateLimit-Scope"
+)
+
+// bucketUnknown is used as the bucket label if Discord does not provide a bucket.
+const bucketUnknown = "unknown"
+
+// RateLimit is the rate limit state reported by Discord in the headers of a response.
+type RateLimit struct {
+	Bucket     string
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration
+	// RetryAfter is only set if the request was rate limited.
+	RetryAfter time.Duration
+	Global     bool
+	Scope      string
+}
+
+// ParseRateLimit reads the rate limit headers from the response.
+// If the response is 429 Too Many Requests and no Retry-After header is provided, the 'retry_after' value of the json body is used.
+func ParseRateLimit(res *http.Response) RateLimit {
+	rl := RateLimit{
+		Bucket:    res.Header.Get(HeaderRateLimitBucket),
+		Remaining: -1,
+		Global:    res.Header.Get(HeaderRateLimitGlobal) == "true",
+		Scope:     res.Header.Get(HeaderRateLimitScope),
+	}
+	if rl.Bucket == "" {
+		rl.Bucket = bucketUnknown
+	}
+	if limit, err := strconv.Atoi(res.Header.Get(HeaderRateLimitLimit)); err == nil {
+		rl.Limit = limit
+	}
+	if remaining, err := strconv.Atoi(res.Header.Get(HeaderRateLimitRemaining)); err == nil {
+		rl.Remaining = remaining
+	}
+	rl.ResetAfter = parseSeconds(res.Header.Get(HeaderRateLimitResetAfter))
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		rl.RetryAfter = parseSeconds(res.Header.Get(HeaderRetryAfter))
+		if rl.RetryAfter == 0 && res.Body != nil {
+			body := struct {
+				RetryAfter float64 `json:"retry_after"`
+				Global     bool    `json:"global"`
+			}{}
+			if b, err := io.ReadAll(res.Body); err == nil && json.Unmarshal(b, &body) == nil {
+				rl.RetryAfter = secondsToDuration(body.RetryAfter)
+				rl.Global = rl.Global || body.Global
+			}
+		}
+		if rl.RetryAfter == 0 {
+			rl.RetryAfter = rl.ResetAfter
+		}
+	}
+
+	return rl
+}
+
+func parseSeconds(s string) time.Duration {
+	if s == "" {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return secondsToDuration(seconds)
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	if seconds <= 0 || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// rateLimiter records when the bucket used by the client is next available, so that requests are delayed rather than being rejected by Discord.
+type rateLimiter struct {
+	mu          sync.Mutex
+	availableAt time.Time
+}
+
+func (rl *rateLimiter) update(limit RateLimit) {
+	RateLimitRemaining.WithLabelValues(limit.Bucket).Set(float64(limit.Remaining))
+	RateLimitResetAfter.WithLabelValues(limit.Bucket).Set(limit.ResetAfter.Seconds())
+
+	wait := limit.RetryAfter
+	if wait == 0 && limit.Remaining == 0 {
+		wait = limit.ResetAfter
+	}
+	if wait == 0 {
+		return
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if availableAt := time.Now().Add(wait); availableAt.After(rl.availableAt) {
+		rl.availableAt = availableAt
+	}
+}
+
+// wait returns the duration until the bucket is next available.
+func (rl *rateLimiter) wait() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return time.Until(rl.availableAt)
+}
+
+// retryAfterBackOff uses the duration requested by Discord, if any, in place of the exponential backoff interval.
+// It stops retrying if waiting would exceed the maximum elapsed time of the exponential backoff.
+type retryAfterBackOff struct {
+	*backoff.ExponentialBackOff
+	retryAfter time.Duration
+}
+
+func (b *retryAfterBackOff) NextBackOff() time.Duration {
+	next := b.ExponentialBackOff.NextBackOff()
+	if next == backoff.Stop || b.retryAfter <= 0 {
+		return next
+	}
+
+	retryAfter := b.retryAfter
+	b.retryAfter = 0
+	if b.MaxElapsedTime != 0 && b.GetElapsedTime()+retryAfter > b.MaxElapsedTime {
+		return backoff.Stop
+	}
+	return retryAfter
+}
@@ -0,0 +1,54 @@
+package discord
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the token of a webhook url.
+const Redacted = "REDACTED"
+
+// webhookTokenRegexp matches the token of a Discord webhook url, e.g. within an error message.
+var webhookTokenRegexp = regexp.MustCompile(`(/api/(?:v\d+/)?webhooks/[0-9]+/)[a-zA-Z0-9_-]+`)
+
+// RedactURL replaces the token of the webhook url, which is the last segment of its path, and any password, so that the url may be logged.
+// The token is not assumed to be in Discord's format, as the url may be of a proxy.
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// the url may not be parsed, so nothing of it is retained
+		return Redacted
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), Redacted)
+	}
+	if i := strings.LastIndex(strings.TrimSuffix(u.Path, "/"), "/"); i >= 0 && i < len(u.Path)-1 {
+		u.Path = u.Path[:i+1] + Redacted
+		u.RawPath = ""
+	}
+	return u.String()
+}
+
+// Redact replaces the tokens of any Discord webhook urls within the text.
+func Redact(s string) string {
+	return webhookTokenRegexp.ReplaceAllString(s, "${1}"+Redacted)
+}
+
+// redactedError replaces the webhook url, and the tokens of any Discord webhook urls, within the message of the error it wraps.
+type redactedError struct {
+	err        error
+	webhookURL string
+}
+
+func (e *redactedError) Error() string {
+	message := e.err.Error()
+	if e.webhookURL != "" {
+		message = strings.ReplaceAll(message, e.webhookURL, RedactURL(e.webhookURL))
+	}
+	return Redact(message)
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,64 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+)
+
+// textBar marks the lines of an embed, as Discord marks embeds with a bar of their colour.
+const textBar = "┃ "
+
+// Text approximates in plain text how the message is displayed by Discord: the thread name and content, followed by each embed,
+// marked by a bar and its colour, with each field's name above its value.
+func Text(message Out) string {
+	var b strings.Builder
+	if message.ThreadName != "" {
+		fmt.Fprintf(&b, "# %s\n", message.ThreadName)
+	}
+	if message.Content != "" {
+		fmt.Fprintf(&b, "%s\n", message.Content)
+	}
+
+	for _, embed := range message.Embeds {
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "%s[#%06X]\n", textBar, embed.Color)
+		writeLines(&b, textBar, embed.Title)
+		if embed.URL != "" {
+			writeLines(&b, textBar, "<"+embed.URL+">")
+		}
+		writeLines(&b, textBar, embed.Description)
+		for _, field := range embed.Fields {
+			b.WriteString(strings.TrimSpace(textBar) + "\n")
+			writeLines(&b, textBar, field.Name)
+			writeLines(&b, textBar+"  ", field.Value)
+		}
+
+		if embed.Image != nil && embed.Image.URL != "" {
+			b.WriteString(strings.TrimSpace(textBar) + "\n")
+			writeLines(&b, textBar, "[image: "+embed.Image.URL+"]")
+		}
+
+		var footer []string
+		if embed.Footer != nil && embed.Footer.Text != "" {
+			footer = append(footer, embed.Footer.Text)
+		}
+		if embed.Timestamp != "" {
+			footer = append(footer, embed.Timestamp)
+		}
+		if len(footer) > 0 {
+			b.WriteString(strings.TrimSpace(textBar) + "\n")
+			writeLines(&b, textBar, strings.Join(footer, " • "))
+		}
+	}
+
+	return b.String()
+}
+
+func writeLines(b *strings.Builder, prefix, s string) {
+	if s == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		b.WriteString(strings.TrimRight(prefix+line, " ") + "\n")
+	}
+}
@@ -0,0 +1,36 @@
+package discord
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Text_ApproximatesTheDisplayedMessage(t *testing.T) {
+	message := Out{
+		Content:    "<@&123> a_content",
+		ThreadName: "a_thread",
+		Embeds: []Embed{{
+			Title:       "a_title",
+			Description: "a_description\nover two lines",
+			Color:       ColorRed,
+			Fields:      []EmbedField{{Name: "a_name", Value: "a_value"}},
+			Timestamp:   "2024-01-02T03:04:05Z",
+		}},
+	}
+
+	expected := `# a_thread
+<@&123> a_content
+
+┃ [#992D22]
+┃ a_title
+┃ a_description
+┃ over two lines
+┃
+┃ a_name
+┃   a_value
+┃
+┃ 2024-01-02T03:04:05Z
+`
+	assert.Equal(t, expected, Text(message), "plain text of the message")
+}
@@ -1,25 +1,86 @@
 package discord
 
-// Discord color values
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Discord color values. ColorRed is Discord's dark red.
 const (
-	ColorRed   = 0x992D22
-	ColorGreen = 0x2ECC71
-	ColorGrey  = 0x95A5A6
+	ColorRed    = 0x992D22
+	ColorGreen  = 0x2ECC71
+	ColorGrey   = 0x95A5A6
+	ColorOrange = 0xE67E22
+	ColorBlue   = 0x3498DB
 )
 
+// ParseColor parses a colour as hex, e.g. '#992D22' or '0x992D22', or as a decimal number.
+func ParseColor(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	var color int64
+	var err error
+	switch {
+	case strings.HasPrefix(value, "#"):
+		color, err = strconv.ParseInt(value[1:], 16, 32)
+	case strings.HasPrefix(value, "0x"), strings.HasPrefix(value, "0X"):
+		color, err = strconv.ParseInt(value[2:], 16, 32)
+	default:
+		color, err = strconv.ParseInt(value, 10, 32)
+	}
+	if err != nil || color < 0 || color > 0xFFFFFF {
+		return 0, fmt.Errorf("colour ('%s') must be hex, e.g. '#992D22', or a number no greater than 16777215", value)
+	}
+	return int(color), nil
+}
+
 type Out struct {
 	Content string  `json:"content"`
 	Embeds  []Embed `json:"embeds"`
+	// ThreadName creates a thread (i.e. a post) in a forum channel, of which the message is the first message.
+	ThreadName      string           `json:"thread_name,omitempty"`
+	AllowedMentions *AllowedMentions `json:"allowed_mentions,omitempty"`
+}
+
+// AllowedMentions restricts which of the mentions within the content of a message will notify their roles or users.
+// If Parse is empty, only the roles and users which are listed are notified, so that e.g. '@everyone' within an annotation notifies nobody.
+type AllowedMentions struct {
+	Parse []string `json:"parse"`
+	Roles []string `json:"roles,omitempty"`
+	Users []string `json:"users,omitempty"`
 }
 
 type Embed struct {
-	Title       string       `json:"title"`
+	Title string `json:"title"`
+	// URL is linked by the title of the embed.
+	URL         string       `json:"url,omitempty"`
 	Description string       `json:"description"`
 	Color       int          `json:"color"`
 	Fields      []EmbedField `json:"fields"`
+	// Image is displayed below the fields of the embed.
+	Image *EmbedImage `json:"image,omitempty"`
+	// Timestamp is displayed in the footer of the embed, and must be formatted as ISO8601.
+	Timestamp string       `json:"timestamp,omitempty"`
+	Footer    *EmbedFooter `json:"footer,omitempty"`
+}
+
+type EmbedImage struct {
+	URL string `json:"url"`
+}
+
+type EmbedFooter struct {
+	Text string `json:"text"`
 }
 
 type EmbedField struct {
 	Name  string `json:"name"`
 	Value string `json:"value"`
+	// Inline fields are displayed side by side, rather than each on its own line.
+	Inline bool `json:"inline,omitempty"`
+}
+
+// Message is a message created by a webhook, as returned by Discord when posting with '?wait=true'.
+type Message struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
 }
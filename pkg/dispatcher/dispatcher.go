@@ -0,0 +1,177 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/logging"
+	"github.com/specklesystems/alertmanager-discord/pkg/metrics"
+	"github.com/specklesystems/alertmanager-discord/pkg/queue"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultWorkers    = 4
+	DefaultQueueSize  = 100
+	DefaultRetryAfter = 30 * time.Second
+
+	metricsInterval = time.Second
+)
+
+// ErrFull is returned when the queue is at capacity, and the notification should be retried later.
+var ErrFull = errors.New("the dispatch queue is full")
+
+// job is the messages of a single notification, which are delivered in order.
+type job struct {
+	groupKey   string
+	messages   []queue.Message
+	enqueuedAt time.Time
+}
+
+// Dispatcher delivers messages to Discord asynchronously, using a pool of workers and a bounded in-memory queue.
+// Notifications of the same AlertManager group are delivered in the order they were dispatched, and never concurrently,
+// so that e.g. a resolved notification cannot overtake the firing notification it edits.
+type Dispatcher struct {
+	workers  int
+	capacity int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []*job
+	// busy are the group keys of the jobs currently being delivered
+	busy    map[string]bool
+	stopped bool
+}
+
+func New(workers, capacity int) *Dispatcher {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if capacity <= 0 {
+		capacity = DefaultQueueSize
+	}
+	d := &Dispatcher{
+		workers:  workers,
+		capacity: capacity,
+		busy:     make(map[string]bool),
+	}
+	d.cond = sync.NewCond(&d.mu)
+	return d
+}
+
+// Dispatch queues the messages of a notification for delivery, returning ErrFull if the queue is at capacity.
+func (d *Dispatcher) Dispatch(messages ...queue.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.pending) >= d.capacity {
+		metrics.DispatchQueueRejectedTotal.Inc()
+		return ErrFull
+	}
+	d.pending = append(d.pending, &job{groupKey: messages[0].GroupKey, messages: messages, enqueuedAt: time.Now()})
+	metrics.DispatchQueueLength.Set(float64(len(d.pending)))
+	d.cond.Signal()
+	return nil
+}
+
+// Len returns the number of notifications awaiting delivery.
+func (d *Dispatcher) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.pending)
+}
+
+// Run starts the workers, which deliver messages until the context is cancelled.
+// Notifications which have not been delivered when the context is cancelled are dropped.
+func (d *Dispatcher) Run(ctx context.Context, deliver queue.DeliverFunc) {
+	var wg sync.WaitGroup
+	for i := 0; i < d.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(deliver)
+		}()
+	}
+
+	ticker := time.NewTicker(metricsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			d.mu.Lock()
+			d.stopped = true
+			d.cond.Broadcast()
+			d.mu.Unlock()
+			wg.Wait()
+			return
+		case <-ticker.C:
+			d.updateAgeMetric()
+		}
+	}
+}
+
+func (d *Dispatcher) work(deliver queue.DeliverFunc) {
+	for {
+		j, ok := d.next()
+		if !ok {
+			return
+		}
+
+		for _, message := range j.messages {
+			if err := deliver(message); err != nil {
+				log.Error().
+					Str(logging.FieldKeyCorrelationId, message.CorrelationId).
+					Str(logging.FieldKeyWebhook, message.Webhook).
+					Err(err).
+					Msg("Error when attempting to deliver dispatched message to Discord.")
+				metrics.DispatchDeliveryFailuresTotal.Inc()
+			}
+		}
+
+		d.mu.Lock()
+		delete(d.busy, j.groupKey)
+		// a job of the same group may now be delivered
+		d.cond.Broadcast()
+		d.mu.Unlock()
+	}
+}
+
+// next waits for the oldest job whose group is not already being delivered, returning false once stopped.
+func (d *Dispatcher) next() (*job, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for {
+		if d.stopped {
+			return nil, false
+		}
+		for i, j := range d.pending {
+			if d.busy[j.groupKey] {
+				continue
+			}
+			d.pending = append(d.pending[:i], d.pending[i+1:]...)
+			d.busy[j.groupKey] = true
+			metrics.DispatchQueueLength.Set(float64(len(d.pending)))
+			return j, true
+		}
+		d.cond.Wait()
+	}
+}
+
+func (d *Dispatcher) updateAgeMetric() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	age := 0.0
+	if len(d.pending) > 0 {
+		age = time.Since(d.pending[0].enqueuedAt).Seconds()
+	}
+	metrics.DispatchQueueOldestAgeSeconds.Set(age)
+}
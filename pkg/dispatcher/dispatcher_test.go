@@ -0,0 +1,107 @@
+package dispatcher
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+	"github.com/specklesystems/alertmanager-discord/pkg/queue"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Dispatch_Full_ReturnsErrFull(t *testing.T) {
+	SUT := New(1, 2)
+
+	assert.NoError(t, SUT.Dispatch(queue.Message{GroupKey: "a"}), "dispatching first notification")
+	assert.NoError(t, SUT.Dispatch(queue.Message{GroupKey: "b"}), "dispatching second notification")
+	assert.ErrorIs(t, SUT.Dispatch(queue.Message{GroupKey: "c"}), ErrFull, "dispatching beyond the capacity should return an error")
+	assert.Equal(t, 2, SUT.Len(), "queued notifications")
+}
+
+func Test_Run_DeliversGroupsInOrder(t *testing.T) {
+	SUT := New(4, 100)
+
+	var mu sync.Mutex
+	var delivered []string
+	inFlight := make(map[string]bool)
+	concurrent := false
+	done := make(chan bool)
+
+	for _, content := range []string{"firing", "resolved"} {
+		err := SUT.Dispatch(
+			queue.Message{GroupKey: "a_group", Out: discord.Out{Content: content + " 1"}},
+			queue.Message{GroupKey: "a_group", Out: discord.Out{Content: content + " 2"}},
+		)
+		assert.NoError(t, err, "dispatching notification")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go SUT.Run(ctx, func(m queue.Message) error {
+		mu.Lock()
+		if inFlight[m.GroupKey] {
+			concurrent = true
+		}
+		inFlight[m.GroupKey] = true
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		inFlight[m.GroupKey] = false
+		delivered = append(delivered, m.Out.Content)
+		if len(delivered) == 4 {
+			close(done)
+		}
+		return nil
+	})
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for messages to be delivered")
+	}
+	assert.Equal(t, []string{"firing 1", "firing 2", "resolved 1", "resolved 2"}, delivered, "notifications of a group should be delivered in order")
+	assert.False(t, concurrent, "notifications of a group should not be delivered concurrently")
+	assert.Equal(t, 0, SUT.Len(), "queue should be empty")
+}
+
+func Test_Run_DeliversGroupsConcurrently(t *testing.T) {
+	SUT := New(2, 100)
+	release := make(chan bool)
+	started := make(chan string, 2)
+
+	assert.NoError(t, SUT.Dispatch(queue.Message{GroupKey: "a"}), "dispatching first notification")
+	assert.NoError(t, SUT.Dispatch(queue.Message{GroupKey: "b"}), "dispatching second notification")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan bool)
+	go func() {
+		SUT.Run(ctx, func(m queue.Message) error {
+			started <- m.GroupKey
+			<-release
+			return nil
+		})
+		close(stopped)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for both groups to be delivered concurrently")
+		}
+	}
+	close(release)
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for workers to stop")
+	}
+}
@@ -3,7 +3,13 @@ package flags
 const (
 	ConfigurationPathFlagKey     = "configuration_file_path"
 	DiscordWebhookUrlFlagKey     = "discord_webhook_url"
+	DiscordWebhookUrlFileFlagKey = "discord_webhook_url_file"
 	ListenAddressFlagKey         = "listen_address"
 	MaxBackoffTimeSecondsFlagKey = "max_backoff_time_seconds"
 	LogLevelFlagKey              = "log_level"
+	TLSCertFileFlagKey           = "tls_cert_file"
+	TLSKeyFileFlagKey            = "tls_key_file"
+	TLSClientCAFileFlagKey       = "tls_client_ca_file"
+	TLSMinVersionFlagKey         = "tls_min_version"
+	TLSCipherSuitesFlagKey       = "tls_cipher_suites"
 )
@@ -0,0 +1,14 @@
+// Package keys provides helpers for the keys of maps, e.g. of alert labels.
+package keys
+
+import "sort"
+
+// Sorted returns the keys of the map in ascending order.
+func Sorted[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,12 @@
+package keys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Sorted_ReturnsTheKeysInAscendingOrder(t *testing.T) {
+	assert.Equal(t, []string{"alertname", "instance", "severity"}, Sorted(map[string]string{"severity": "critical", "alertname": "HighCPU", "instance": "a"}), "keys")
+	assert.Equal(t, []string{}, Sorted(map[string]any{}), "an empty map should have no keys")
+}
@@ -9,4 +9,6 @@ const (
 	FieldKeyAlertName     = "alert_name"
 	FieldKeyCorrelationId = "correlation_id"
 	FieldKeyStatusCode    = "status_code"
+	FieldKeyWebhook       = "webhook"
+	FieldKeyFormat        = "format"
 )
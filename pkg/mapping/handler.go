@@ -0,0 +1,88 @@
+package mapping
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+	"github.com/specklesystems/alertmanager-discord/pkg/logging"
+	"github.com/specklesystems/alertmanager-discord/pkg/queue"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Publisher sends messages to Discord, e.g. alertforwarder.Webhooks,
+// so that mapped messages are subject to the same retries, rate limits, and metrics as alerts.
+type Publisher interface {
+	Publish(message queue.Message) (*http.Response, error)
+}
+
+// Handler maps the JSON body of each request into a Discord message, and sends it to the webhook of the mapping.
+type Handler struct {
+	name      string
+	webhook   string
+	mapper    *Mapper
+	publisher Publisher
+}
+
+// NewHandler creates the handler of the mapping. An error is returned if any expression of the mapping is invalid.
+func NewHandler(m config.Mapping, publisher Publisher) (*Handler, error) {
+	mapper, err := New(m)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{name: m.Name, webhook: m.Webhook, mapper: mapper, publisher: publisher}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	correlationId := uuid.New().String()
+	logger := log.With().
+		Str(logging.FieldKeyCorrelationId, correlationId).
+		Str(logging.FieldKeyWebhook, h.webhook).
+		Logger()
+	logger.Info().
+		Str(logging.FieldKeyHttpMethod, r.Method).
+		Str(logging.FieldKeyHttpPath, r.URL.Path).
+		Str(logging.FieldKeyEventType, logging.EventTypeRequestReceived).
+		Msgf("HTTP request received for mapping ('%s').", h.name)
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Error().Err(err).Msg("Unable to read request body.")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	outs, err := h.mapper.Map(b)
+	if err != nil {
+		logger.Info().Err(err).Msg("Unable to map the request body into a Discord message.")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	for _, out := range outs {
+		logger.Info().
+			Str(logging.FieldKeyEventType, logging.EventTypeRequestSending).
+			Msg("Sending HTTP request to Discord.")
+		res, err := h.publisher.Publish(queue.Message{Webhook: h.webhook, Out: out, CorrelationId: correlationId})
+		if err != nil {
+			logger.Error().Err(err).Msg("Error when attempting to publish message to Discord.")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		discord.CloseBody(res)
+
+		logger.Info().
+			Str(logging.FieldKeyEventType, logging.EventTypeResponseReceived).
+			Int(logging.FieldKeyStatusCode, res.StatusCode).
+			Msg("HTTP response received from Discord")
+		if res.StatusCode < 200 || res.StatusCode > 399 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
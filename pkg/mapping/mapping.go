@@ -0,0 +1,196 @@
+package mapping
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+)
+
+// statusColors are the colours of common statuses of CI pipelines, jobs, and alerts, unless configured otherwise.
+var statusColors = map[string]int{
+	"firing":    discord.ColorRed,
+	"failed":    discord.ColorRed,
+	"failure":   discord.ColorRed,
+	"error":     discord.ColorRed,
+	"resolved":  discord.ColorGreen,
+	"success":   discord.ColorGreen,
+	"succeeded": discord.ColorGreen,
+	"ok":        discord.ColorGreen,
+}
+
+// Mapper maps JSON documents into Discord messages, as configured by a mapping.
+type Mapper struct {
+	content      *expression
+	title        *expression
+	description  *expression
+	url          *expression
+	color        *expression
+	status       *expression
+	statusColors map[string]int
+	fields       []fieldExpression
+}
+
+type fieldExpression struct {
+	name   *expression
+	value  *expression
+	inline bool
+}
+
+// expression is either a JSONPath expression, if it begins with '$', or otherwise a literal value.
+type expression struct {
+	path    *Path
+	literal string
+}
+
+// New compiles the expressions of the mapping.
+func New(m config.Mapping) (*Mapper, error) {
+	var err error
+	compile := func(key, value string) *expression {
+		if err != nil {
+			return nil
+		}
+		var e *expression
+		if e, err = compileExpression(value); err != nil {
+			err = fmt.Errorf("invalid %s of mapping ('%s'): %w", key, m.Name, err)
+		}
+		return e
+	}
+
+	mapper := &Mapper{
+		content:      compile("content", m.Content),
+		title:        compile("title", m.Title),
+		description:  compile("description", m.Description),
+		url:          compile("url", m.URL),
+		color:        compile("color", m.Color),
+		status:       compile("status", m.Status),
+		statusColors: make(map[string]int, len(statusColors)+len(m.StatusColors)),
+	}
+	for i, field := range m.Fields {
+		mapper.fields = append(mapper.fields, fieldExpression{
+			name:   compile(fmt.Sprintf("name of field at index ('%d')", i), field.Name),
+			value:  compile(fmt.Sprintf("value of field at index ('%d')", i), field.Value),
+			inline: field.Inline,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for status, color := range statusColors {
+		mapper.statusColors[status] = color
+	}
+	for status, value := range m.StatusColors {
+		color, err := discord.ParseColor(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid colour of status ('%s') of mapping ('%s'): %w", status, m.Name, err)
+		}
+		mapper.statusColors[status] = color
+	}
+	if mapper.color.path == nil && m.Color != "" {
+		if _, err := discord.ParseColor(m.Color); err != nil {
+			return nil, fmt.Errorf("invalid colour of mapping ('%s'): %w", m.Name, err)
+		}
+	}
+
+	return mapper, nil
+}
+
+func compileExpression(value string) (*expression, error) {
+	if !strings.HasPrefix(value, "$") {
+		return &expression{literal: value}, nil
+	}
+	path, err := CompilePath(value)
+	if err != nil {
+		return nil, err
+	}
+	return &expression{path: path}, nil
+}
+
+// evaluate returns the literal value, or the values selected by the path, joined by ', ' if there are several.
+// Strings are not quoted, whereas objects and arrays are formatted as JSON.
+func (e *expression) evaluate(document any) string {
+	if e.path == nil {
+		return e.literal
+	}
+	values := e.path.Select(document)
+	formatted := make([]string, 0, len(values))
+	for _, value := range values {
+		if s := formatValue(value); s != "" {
+			formatted = append(formatted, s)
+		}
+	}
+	return strings.Join(formatted, ", ")
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// Map maps the JSON document into a Discord message, which is split if it would exceed Discord's limits, and which notifies nobody.
+// An error is returned if the body is not JSON, or if the message would be empty.
+func (m *Mapper) Map(body []byte) ([]discord.Out, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("unable to parse the body as JSON: %w", err)
+	}
+
+	embed := discord.Embed{
+		Title:       m.title.evaluate(document),
+		URL:         m.url.evaluate(document),
+		Description: m.description.evaluate(document),
+		Color:       discord.ColorGrey,
+		Fields:      []discord.EmbedField{},
+	}
+	if color, ok := m.statusColors[strings.ToLower(m.status.evaluate(document))]; ok {
+		embed.Color = color
+	}
+	if value := m.color.evaluate(document); value != "" {
+		color, err := discord.ParseColor(value)
+		if err != nil {
+			return nil, err
+		}
+		embed.Color = color
+	}
+	for _, field := range m.fields {
+		name, value := field.name.evaluate(document), field.value.evaluate(document)
+		if name == "" && value == "" {
+			// a field of which nothing was selected is omitted, rather than displayed empty
+			continue
+		}
+		embed.Fields = append(embed.Fields, discord.EmbedField{Name: name, Value: value, Inline: field.inline})
+	}
+
+	message := discord.Out{Content: m.content.evaluate(document)}
+	if embed.Title != "" || embed.Description != "" || len(embed.Fields) > 0 {
+		message.Embeds = []discord.Embed{embed}
+	}
+	if message.Content == "" && len(message.Embeds) == 0 {
+		return nil, fmt.Errorf("the mapping selected nothing from the body, so the message would be empty")
+	}
+
+	messages := discord.Split(message)
+	for i := range messages {
+		// the body is arbitrary, e.g. a commit message, so mentions within it, such as '@everyone', notify nobody
+		messages[i].AllowedMentions = &discord.AllowedMentions{Parse: []string{}}
+	}
+	return messages, nil
+}
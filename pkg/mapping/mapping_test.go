@@ -0,0 +1,149 @@
+package mapping
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+	"github.com/specklesystems/alertmanager-discord/pkg/queue"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const pipelineBody = `{
+	"status": "failed",
+	"pipeline": {"name": "deploy", "url": "https://ci.example.com/pipelines/42", "ref": "main", "duration": 93},
+	"commit": {"message": "Bump dependencies"},
+	"failed_jobs": [{"name": "test"}, {"name": "lint"}]
+}`
+
+var pipelineMapping = config.Mapping{
+	Name:        "ci",
+	Webhook:     "platform",
+	Title:       "$.pipeline.name",
+	Description: "$.commit.message",
+	URL:         "$.pipeline.url",
+	Status:      "$.status",
+	Fields: []config.MappingField{
+		{Name: "Branch", Value: "$.pipeline.ref", Inline: true},
+		{Name: "Duration (s)", Value: "$.pipeline.duration", Inline: true},
+		{Name: "Failed jobs", Value: "$.failed_jobs[*].name"},
+		{Name: "Missing", Value: "$.missing"},
+	},
+}
+
+func Test_Map_MapsTheDocumentIntoAnEmbed(t *testing.T) {
+	SUT, err := New(pipelineMapping)
+	assert.NoError(t, err, "compiling mapping")
+
+	outs, err := SUT.Map([]byte(pipelineBody))
+
+	assert.NoError(t, err, "mapping")
+	assert.Equal(t, 1, len(outs), "number of messages")
+	embed := outs[0].Embeds[0]
+	assert.Equal(t, "deploy", embed.Title, "title")
+	assert.Equal(t, "Bump dependencies", embed.Description, "description")
+	assert.Equal(t, "https://ci.example.com/pipelines/42", embed.URL, "url")
+	assert.Equal(t, discord.ColorRed, embed.Color, "a failed status should be red")
+	assert.Equal(t, []discord.EmbedField{
+		{Name: "Branch", Value: "main", Inline: true},
+		{Name: "Duration (s)", Value: "93", Inline: true},
+		{Name: "Failed jobs", Value: "test, lint"},
+		{Name: "Missing", Value: "-"},
+	}, embed.Fields, "fields")
+}
+
+func Test_Map_StatusAndColor_ChooseTheColour(t *testing.T) {
+	m := config.Mapping{Name: "cron", Title: "Backup", Status: "$.result", StatusColors: map[string]string{"skipped": "#FFA500"}}
+	SUT, err := New(m)
+	assert.NoError(t, err, "compiling mapping")
+
+	outs, _ := SUT.Map([]byte(`{"result": "skipped"}`))
+	assert.Equal(t, 0xFFA500, outs[0].Embeds[0].Color, "configured status colour")
+	outs, _ = SUT.Map([]byte(`{"result": "Success"}`))
+	assert.Equal(t, discord.ColorGreen, outs[0].Embeds[0].Color, "common statuses should be coloured regardless of case")
+	outs, _ = SUT.Map([]byte(`{"result": "unknown"}`))
+	assert.Equal(t, discord.ColorGrey, outs[0].Embeds[0].Color, "unknown status should be grey")
+
+	m.Color = "$.color"
+	SUT, err = New(m)
+	assert.NoError(t, err, "compiling mapping")
+	outs, _ = SUT.Map([]byte(`{"result": "skipped", "color": 255}`))
+	assert.Equal(t, 255, outs[0].Embeds[0].Color, "the colour should take precedence over the status")
+	_, err = SUT.Map([]byte(`{"color": "blue"}`))
+	assert.Error(t, err, "invalid colour")
+}
+
+func Test_Map_MentionsWithinTheBody_NotifyNobody(t *testing.T) {
+	SUT, err := New(config.Mapping{Name: "ci", Content: "$.commit.message", Title: "$.pipeline.name"})
+	assert.NoError(t, err, "compiling mapping")
+
+	outs, err := SUT.Map([]byte(`{"pipeline": {"name": "deploy"}, "commit": {"message": "@everyone @here <@&123456789123456789> please review"}}`))
+
+	assert.NoError(t, err, "mapping")
+	assert.Contains(t, outs[0].Content, "@everyone", "the content should be mapped as it is")
+	assert.Equal(t, &discord.AllowedMentions{Parse: []string{}}, outs[0].AllowedMentions, "mentions within the body should not be parsed")
+
+	b, err := json.Marshal(outs[0])
+	assert.NoError(t, err, "marshalling message")
+	assert.Contains(t, string(b), `"allowed_mentions":{"parse":[]}`, "the message sent to Discord should not parse mentions")
+}
+
+func Test_Map_InvalidBody_ReturnsError(t *testing.T) {
+	SUT, err := New(config.Mapping{Name: "ci", Title: "$.title"})
+	assert.NoError(t, err, "compiling mapping")
+
+	_, err = SUT.Map([]byte("not json"))
+	assert.Error(t, err, "body should be JSON")
+	_, err = SUT.Map([]byte(`{"other": "value"}`))
+	assert.Error(t, err, "an empty message should not be sent")
+}
+
+func Test_New_InvalidMapping_ReturnsError(t *testing.T) {
+	_, err := New(config.Mapping{Name: "ci", Title: "$.stages[first]"})
+	assert.Error(t, err, "invalid path")
+	_, err = New(config.Mapping{Name: "ci", Color: "red"})
+	assert.Error(t, err, "invalid colour")
+	_, err = New(config.Mapping{Name: "ci", StatusColors: map[string]string{"failed": "#GGGGGG"}})
+	assert.Error(t, err, "invalid status colour")
+}
+
+type recordingPublisher struct {
+	statusCode int
+	messages   []queue.Message
+}
+
+func (p *recordingPublisher) Publish(message queue.Message) (*http.Response, error) {
+	p.messages = append(p.messages, message)
+	return &http.Response{StatusCode: p.statusCode}, nil
+}
+
+func Test_Handler_PublishesTheMappedMessageToTheWebhook(t *testing.T) {
+	publisher := &recordingPublisher{statusCode: http.StatusNoContent}
+	SUT, err := NewHandler(pipelineMapping, publisher)
+	assert.NoError(t, err, "creating handler")
+
+	w := httptest.NewRecorder()
+	SUT.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/mappings/ci", strings.NewReader(pipelineBody)))
+
+	assert.Equal(t, http.StatusOK, w.Code, "http response status code")
+	if assert.Equal(t, 1, len(publisher.messages), "should have published one message") {
+		assert.Equal(t, "platform", publisher.messages[0].Webhook, "webhook")
+		b, _ := json.Marshal(publisher.messages[0].Out)
+		assert.True(t, bytes.Contains(b, []byte(`"inline":true`)), "inline fields")
+	}
+
+	w = httptest.NewRecorder()
+	SUT.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/mappings/ci", strings.NewReader("not json")))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "invalid body")
+
+	publisher.statusCode = http.StatusBadRequest
+	w = httptest.NewRecorder()
+	SUT.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/mappings/ci", strings.NewReader(pipelineBody)))
+	assert.Equal(t, http.StatusInternalServerError, w.Code, "Discord rejected the message")
+}
@@ -0,0 +1,133 @@
+package mapping
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/internal/keys"
+)
+
+// Path is a JSONPath expression, e.g. '$.pipeline.stages[0].name', selecting values of a JSON document.
+// The supported subset is the root '$', child members '.name' or ['name'], wildcards '.*' or '[*]', and array indices '[0]', counting from the end if negative.
+type Path struct {
+	expression string
+	steps      []step
+}
+
+// step selects the children of a value: the member of the name, the element at the index, or all members or elements if a wildcard.
+type step struct {
+	name     string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// CompilePath parses the JSONPath expression.
+func CompilePath(expression string) (*Path, error) {
+	if !strings.HasPrefix(expression, "$") {
+		return nil, fmt.Errorf("path ('%s') must begin with '$'", expression)
+	}
+
+	p := &Path{expression: expression}
+	rest := expression[1:]
+	for rest != "" {
+		var s step
+		var err error
+		switch rest[0] {
+		case '.':
+			s, rest, err = parseMember(rest[1:])
+		case '[':
+			s, rest, err = parseBracket(rest[1:])
+		default:
+			err = fmt.Errorf("unexpected ('%c')", rest[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid path ('%s'): %w", expression, err)
+		}
+		p.steps = append(p.steps, s)
+	}
+	return p, nil
+}
+
+func parseMember(s string) (step, string, error) {
+	if strings.HasPrefix(s, "*") {
+		return step{wildcard: true}, s[1:], nil
+	}
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+	if end == 0 {
+		return step{}, "", fmt.Errorf("expected the name of a member after '.'")
+	}
+	return step{name: s[:end]}, s[end:], nil
+}
+
+func parseBracket(s string) (step, string, error) {
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return step{}, "", fmt.Errorf("expected ']'")
+	}
+	inner, rest := strings.TrimSpace(s[:end]), s[end+1:]
+
+	if inner == "*" {
+		return step{wildcard: true}, rest, nil
+	}
+	if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+		return step{name: inner[1 : len(inner)-1]}, rest, nil
+	}
+	index, err := strconv.Atoi(inner)
+	if err != nil {
+		return step{}, "", fmt.Errorf("expected an index, a quoted name, or '*' within ('[%s]')", inner)
+	}
+	return step{index: index, isIndex: true}, rest, nil
+}
+
+// Select returns the values of the document, as decoded by encoding/json, which the path selects.
+func (p *Path) Select(document any) []any {
+	values := []any{document}
+	for _, s := range p.steps {
+		var next []any
+		for _, value := range values {
+			next = append(next, s.children(value)...)
+		}
+		values = next
+	}
+	return values
+}
+
+func (p *Path) String() string {
+	return p.expression
+}
+
+func (s step) children(value any) []any {
+	switch v := value.(type) {
+	case map[string]any:
+		if s.wildcard {
+			// members are selected in the order of their names, as the order of the document is not retained
+			children := make([]any, 0, len(v))
+			for _, name := range keys.Sorted(v) {
+				children = append(children, v[name])
+			}
+			return children
+		}
+		if child, ok := v[s.name]; ok && !s.isIndex {
+			return []any{child}
+		}
+	case []any:
+		if s.wildcard {
+			return v
+		}
+		if s.isIndex {
+			index := s.index
+			if index < 0 {
+				index += len(v)
+			}
+			if index >= 0 && index < len(v) {
+				return []any{v[index]}
+			}
+		}
+	}
+	return nil
+}
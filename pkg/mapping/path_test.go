@@ -0,0 +1,43 @@
+package mapping
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Path_Select_ReturnsSelectedValues(t *testing.T) {
+	var document any
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"pipeline": {"name": "deploy", "stages": [{"name": "build"}, {"name": "test"}, {"name": "release"}]},
+		"labels": {"team name": "platform"}
+	}`), &document), "unmarshalling document")
+
+	tests := []struct {
+		path     string
+		expected []any
+	}{
+		{path: "$.pipeline.name", expected: []any{"deploy"}},
+		{path: "$.pipeline.stages[0].name", expected: []any{"build"}},
+		{path: "$.pipeline.stages[-1].name", expected: []any{"release"}},
+		{path: "$.pipeline.stages[*].name", expected: []any{"build", "test", "release"}},
+		{path: "$['labels']['team name']", expected: []any{"platform"}},
+		{path: "$.labels.*", expected: []any{"platform"}},
+		{path: "$.pipeline.missing", expected: nil},
+		{path: "$.pipeline.stages[3]", expected: nil},
+	}
+	for _, tt := range tests {
+		SUT, err := CompilePath(tt.path)
+		if assert.NoError(t, err, "compiling path ('%s')", tt.path) {
+			assert.Equal(t, tt.expected, SUT.Select(document), "values selected by path ('%s')", tt.path)
+		}
+	}
+}
+
+func Test_CompilePath_InvalidPath_ReturnsError(t *testing.T) {
+	for _, path := range []string{"pipeline.name", "$.", "$.stages[0", "$.stages[first]", "$name"} {
+		_, err := CompilePath(path)
+		assert.Error(t, err, "path ('%s') should be invalid", path)
+	}
+}
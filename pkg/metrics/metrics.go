@@ -21,4 +21,59 @@ var (
 		Help:    "Duration of all http requests processed by alert forwarder.",
 		Buckets: prometheus.DefBuckets,
 	}, []string{"code"})
+
+	ConfigLastReloadSuccessful = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "alertmanager_discord_config_last_reload_successful",
+		Help: "Whether the last attempt to load the configuration was successful.",
+	})
+
+	ConfigLastReloadSuccessTimestampSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "alertmanager_discord_config_last_reload_success_timestamp_seconds",
+		Help: "The timestamp of the last successful load of the configuration.",
+	})
+
+	ConfigHash = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "alertmanager_discord_config_hash",
+		Help: "A hash of the contents of the currently loaded configuration file.",
+	})
+
+	AuthenticationFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alertmanager_discord_authentication_failures_total",
+		Help: "The total number of requests to alert forwarder which were rejected, as unauthenticated (401) or forbidden (403).",
+	}, []string{"code", "reason"})
+
+	DispatchQueueLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "alertmanager_discord_dispatch_queue_length",
+		Help: "The current number of notifications in the in-memory dispatch queue, awaiting delivery to Discord.",
+	})
+
+	DispatchQueueOldestAgeSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "alertmanager_discord_dispatch_queue_oldest_age_seconds",
+		Help: "The duration for which the oldest notification in the in-memory dispatch queue has been awaiting delivery to Discord.",
+	})
+
+	DispatchQueueRejectedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "alertmanager_discord_dispatch_queue_rejected_total",
+		Help: "The total number of notifications rejected with 503 Service Unavailable, as the in-memory dispatch queue was full.",
+	})
+
+	DispatchDeliveryFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "alertmanager_discord_dispatch_delivery_failures_total",
+		Help: "The total number of messages from the in-memory dispatch queue which could not be delivered to Discord.",
+	})
+
+	DurableQueueLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "alertmanager_discord_durable_queue_length",
+		Help: "The current number of messages persisted in the durable queue, awaiting delivery to Discord.",
+	})
+
+	DurableQueueDeliveryFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "alertmanager_discord_durable_queue_delivery_failures_total",
+		Help: "The total number of failed attempts to deliver messages from the durable queue to Discord, which will be retried.",
+	})
+
+	DurableQueueDroppedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alertmanager_discord_durable_queue_dropped_total",
+		Help: "The total number of messages dropped from the durable queue without being delivered to Discord.",
+	}, []string{"reason"})
 )
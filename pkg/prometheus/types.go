@@ -4,11 +4,10 @@ import (
 	"encoding/json"
 )
 
+// Alert is an alert as sent by Prometheus to AlertManager's API, rather than by AlertManager's webhook receiver.
+// https://prometheus.io/docs/alerting/latest/clients/
 type Alert struct {
-	Annotations struct {
-		Description string `json:"description"`
-		Summary     string `json:"summary"`
-	} `json:"annotations"`
+	Annotations  map[string]string `json:"annotations"`
 	EndsAt       string            `json:"endsAt"`
 	GeneratorURL string            `json:"generatorURL"`
 	Labels       map[string]string `json:"labels"`
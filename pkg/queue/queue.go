@@ -0,0 +1,299 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+	"github.com/specklesystems/alertmanager-discord/pkg/logging"
+	"github.com/specklesystems/alertmanager-discord/pkg/metrics"
+
+	backoff "github.com/cenkalti/backoff/v4"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultMaxAge             = 24 * time.Hour
+	DefaultInitialRetryDelay  = time.Second
+	DefaultMaximumRetryDelay  = 5 * time.Minute
+	defaultPollInterval       = time.Second
+	messageFileExtension      = ".json"
+	temporaryMessageExtension = ".tmp"
+)
+
+// Message is a translated Discord message awaiting delivery to a webhook.
+type Message struct {
+	ID            string      `json:"id"`
+	Webhook       string      `json:"webhook"`
+	CorrelationId string      `json:"correlationId"`
+	Out           discord.Out `json:"out"`
+	// GroupKey and Fingerprints identify the alerts within the message. If there are fingerprints, the published message is recorded so that it may be edited when the alerts resolve.
+	GroupKey     string   `json:"groupKey,omitempty"`
+	Fingerprints []string `json:"fingerprints,omitempty"`
+	// EditMessageID, if provided, is the id of a previously published message which is replaced by this message.
+	EditMessageID string `json:"editMessageId,omitempty"`
+
+	EnqueuedAt    time.Time `json:"enqueuedAt"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+}
+
+// DeliverFunc publishes a message to Discord.
+// Errors wrapped with backoff.Permanent cause the message to be dropped rather than retried.
+type DeliverFunc func(Message) error
+
+// Queue is a durable queue of messages, persisted as one file per message within a directory.
+// Each message is written to disk before Enqueue returns, so messages which have not been delivered are replayed after a restart.
+type Queue struct {
+	directory         string
+	maxAge            time.Duration
+	initialRetryDelay time.Duration
+	maximumRetryDelay time.Duration
+	pollInterval      time.Duration
+
+	mu       sync.Mutex
+	messages map[string]Message
+	notify   chan struct{}
+}
+
+// Open creates the directory if it does not exist, and loads any messages persisted within it.
+func Open(directory string, maxAge time.Duration) (*Queue, error) {
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+
+	if err := os.MkdirAll(directory, 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create queue directory ('%s'): %w", directory, err)
+	}
+
+	q := &Queue{
+		directory:         directory,
+		maxAge:            maxAge,
+		initialRetryDelay: DefaultInitialRetryDelay,
+		maximumRetryDelay: DefaultMaximumRetryDelay,
+		pollInterval:      defaultPollInterval,
+		messages:          make(map[string]Message),
+		notify:            make(chan struct{}, 1),
+	}
+
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read queue directory ('%s'): %w", directory, err)
+	}
+	for _, entry := range entries {
+		path := filepath.Join(directory, entry.Name())
+		if strings.HasSuffix(entry.Name(), temporaryMessageExtension) {
+			// an incomplete write, which was never acknowledged
+			_ = os.Remove(path)
+			continue
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), messageFileExtension) {
+			continue
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read queued message ('%s'): %w", path, err)
+		}
+		message := Message{}
+		if err := json.Unmarshal(b, &message); err != nil {
+			log.Error().Err(err).Msgf("Unable to parse queued message ('%s'). It will be removed.", path)
+			_ = os.Remove(path)
+			continue
+		}
+		q.messages[message.ID] = message
+	}
+
+	if len(q.messages) > 0 {
+		log.Info().Msgf("Replaying %d queued messages from directory ('%s').", len(q.messages), directory)
+	}
+	metrics.DurableQueueLength.Set(float64(len(q.messages)))
+
+	return q, nil
+}
+
+// Enqueue persists the messages, which will be delivered by Run.
+// If any message cannot be persisted, none of the messages are enqueued.
+func (q *Queue) Enqueue(messages ...Message) error {
+	now := time.Now()
+	persisted := make([]Message, 0, len(messages))
+	for i, message := range messages {
+		// the id begins with the time and position, so that messages are delivered in the order they were enqueued
+		message.ID = fmt.Sprintf("%020d-%04d-%s", now.UnixNano(), i, uuid.New().String())
+		message.EnqueuedAt = now
+		message.NextAttemptAt = now
+
+		if err := q.write(message); err != nil {
+			for _, p := range persisted {
+				_ = os.Remove(q.path(p.ID))
+			}
+			return err
+		}
+		persisted = append(persisted, message)
+	}
+
+	q.mu.Lock()
+	for _, message := range persisted {
+		q.messages[message.ID] = message
+	}
+	metrics.DurableQueueLength.Set(float64(len(q.messages)))
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Len returns the number of messages awaiting delivery.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
+
+// Run delivers messages until the context is cancelled.
+// Messages which fail to be delivered are retried with exponential backoff, until they exceed the maximum age.
+func (q *Queue) Run(ctx context.Context, deliver DeliverFunc) {
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		q.deliverDue(ctx, deliver)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.notify:
+		}
+	}
+}
+
+func (q *Queue) deliverDue(ctx context.Context, deliver DeliverFunc) {
+	for _, message := range q.due(time.Now()) {
+		if ctx.Err() != nil {
+			return
+		}
+
+		logger := log.With().
+			Str(logging.FieldKeyCorrelationId, message.CorrelationId).
+			Str(logging.FieldKeyWebhook, message.Webhook).
+			Logger()
+
+		if time.Since(message.EnqueuedAt) > q.maxAge {
+			logger.Error().Msgf("Queued message has not been delivered within the maximum age (%s). It will be dropped.", q.maxAge)
+			metrics.DurableQueueDroppedTotal.WithLabelValues("expired").Inc()
+			q.remove(message)
+			continue
+		}
+
+		err := deliver(message)
+		if err == nil {
+			logger.Debug().Msg("Queued message was delivered to Discord.")
+			q.remove(message)
+			continue
+		}
+
+		var permanent *backoff.PermanentError
+		if errors.As(err, &permanent) {
+			logger.Error().Err(err).Msg("Queued message was rejected by Discord. It will be dropped.")
+			metrics.DurableQueueDroppedTotal.WithLabelValues("rejected").Inc()
+			q.remove(message)
+			continue
+		}
+
+		message.Attempts++
+		message.NextAttemptAt = time.Now().Add(q.retryDelay(message.Attempts))
+		logger.Warn().Err(err).Msgf("Unable to deliver queued message to Discord. Attempt %d will be made at %s.", message.Attempts+1, message.NextAttemptAt.Format(time.RFC3339))
+		metrics.DurableQueueDeliveryFailuresTotal.Inc()
+		if err := q.write(message); err != nil {
+			logger.Error().Err(err).Msg("Unable to persist the state of the queued message.")
+		}
+		q.mu.Lock()
+		q.messages[message.ID] = message
+		q.mu.Unlock()
+	}
+}
+
+// due returns the messages which are ready to be delivered, in the order in which they were enqueued.
+func (q *Queue) due(now time.Time) []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	due := make([]Message, 0, len(q.messages))
+	for _, message := range q.messages {
+		if !message.NextAttemptAt.After(now) {
+			due = append(due, message)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	return due
+}
+
+func (q *Queue) retryDelay(attempts int) time.Duration {
+	delay := q.initialRetryDelay
+	for i := 1; i < attempts && delay < q.maximumRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > q.maximumRetryDelay {
+		delay = q.maximumRetryDelay
+	}
+	return delay
+}
+
+func (q *Queue) remove(message Message) {
+	if err := os.Remove(q.path(message.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Error().Err(err).Str(logging.FieldKeyCorrelationId, message.CorrelationId).Msg("Unable to remove queued message from disk.")
+	}
+
+	q.mu.Lock()
+	delete(q.messages, message.ID)
+	metrics.DurableQueueLength.Set(float64(len(q.messages)))
+	q.mu.Unlock()
+}
+
+// write persists the message atomically, by writing to a temporary file which is then renamed.
+func (q *Queue) write(message Message) error {
+	b, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("unable to marshal queued message to json: %w", err)
+	}
+
+	path := q.path(message.ID)
+	tmp := path + temporaryMessageExtension
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to create queued message file ('%s'): %w", tmp, err)
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write queued message file ('%s'): %w", tmp, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to sync queued message file ('%s'): %w", tmp, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to close queued message file ('%s'): %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("unable to rename queued message file ('%s'): %w", tmp, err)
+	}
+	return nil
+}
+
+func (q *Queue) path(id string) string {
+	return filepath.Join(q.directory, id+messageFileExtension)
+}
@@ -0,0 +1,109 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+
+	backoff "github.com/cenkalti/backoff/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Queue_Enqueue_IsReplayedAfterReopening(t *testing.T) {
+	directory := t.TempDir()
+
+	q, err := Open(directory, time.Hour)
+	assert.NoError(t, err, "opening queue")
+	err = q.Enqueue(
+		Message{Webhook: "a_webhook", Out: discord.Out{Content: "first"}},
+		Message{Webhook: "a_webhook", Out: discord.Out{Content: "second"}},
+	)
+	assert.NoError(t, err, "enqueueing messages")
+
+	// simulates a restart, without the messages having been delivered
+	SUT, err := Open(directory, time.Hour)
+	assert.NoError(t, err, "reopening queue")
+	assert.Equal(t, 2, SUT.Len(), "queued messages should have been loaded from disk")
+
+	delivered := runUntilEmpty(t, SUT, func(m Message) error { return nil })
+	assert.Equal(t, []string{"first", "second"}, delivered, "messages should be delivered in the order they were enqueued")
+
+	entries, err := os.ReadDir(directory)
+	assert.NoError(t, err, "reading queue directory")
+	assert.Empty(t, entries, "delivered messages should be removed from disk")
+}
+
+func Test_Queue_FailedDelivery_IsRetried(t *testing.T) {
+	SUT, err := Open(t.TempDir(), time.Hour)
+	assert.NoError(t, err, "opening queue")
+	SUT.initialRetryDelay = 10 * time.Millisecond
+	SUT.pollInterval = 10 * time.Millisecond
+
+	err = SUT.Enqueue(Message{Webhook: "a_webhook", Out: discord.Out{Content: "a_message"}})
+	assert.NoError(t, err, "enqueueing message")
+
+	attempts := 0
+	delivered := runUntilEmpty(t, SUT, func(m Message) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("Discord is unavailable")
+		}
+		return nil
+	})
+	assert.Equal(t, []string{"a_message"}, delivered, "message should eventually be delivered")
+	assert.Equal(t, 3, attempts, "delivery attempts")
+}
+
+func Test_Queue_PermanentError_IsDropped(t *testing.T) {
+	SUT, err := Open(t.TempDir(), time.Hour)
+	assert.NoError(t, err, "opening queue")
+
+	err = SUT.Enqueue(Message{Webhook: "a_webhook", Out: discord.Out{Content: "a_message"}})
+	assert.NoError(t, err, "enqueueing message")
+
+	attempts := 0
+	runUntilEmpty(t, SUT, func(m Message) error {
+		attempts++
+		return backoff.Permanent(errors.New("Discord responded with status code 400"))
+	})
+	assert.Equal(t, 1, attempts, "rejected messages should not be retried")
+}
+
+func Test_Queue_RetryDelay_IsBounded(t *testing.T) {
+	SUT := &Queue{initialRetryDelay: time.Second, maximumRetryDelay: 10 * time.Second}
+
+	assert.Equal(t, time.Second, SUT.retryDelay(1), "first retry delay")
+	assert.Equal(t, 4*time.Second, SUT.retryDelay(3), "third retry delay")
+	assert.Equal(t, 10*time.Second, SUT.retryDelay(100), "retry delay should not exceed the maximum")
+}
+
+// runUntilEmpty runs the queue until all messages have been removed, returning the content of the delivered messages.
+func runUntilEmpty(t *testing.T, q *Queue, deliver DeliverFunc) []string {
+	t.Helper()
+
+	var mu sync.Mutex
+	var delivered []string
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx, func(m Message) error {
+		err := deliver(m)
+		if err == nil {
+			mu.Lock()
+			delivered = append(delivered, m.Out.Content)
+			mu.Unlock()
+		}
+		return err
+	})
+
+	assert.Eventually(t, func() bool { return q.Len() == 0 }, 5*time.Second, 5*time.Millisecond, "queue should be emptied")
+
+	mu.Lock()
+	defer mu.Unlock()
+	return delivered
+}
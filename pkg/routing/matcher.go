@@ -0,0 +1,146 @@
+package routing
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/internal/keys"
+)
+
+type MatchType string
+
+const (
+	MatchEqual     MatchType = "="
+	MatchNotEqual  MatchType = "!="
+	MatchRegexp    MatchType = "=~"
+	MatchNotRegexp MatchType = "!~"
+)
+
+// Matcher compares the value of a single alert label, in the same manner as AlertManager's matchers.
+type Matcher struct {
+	Name  string
+	Type  MatchType
+	Value string
+
+	re *regexp.Regexp
+}
+
+func NewMatcher(name string, matchType MatchType, value string) (*Matcher, error) {
+	m := &Matcher{
+		Name:  name,
+		Type:  matchType,
+		Value: value,
+	}
+
+	switch matchType {
+	case MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegexp:
+		// regular expressions are anchored at both ends, as with AlertManager
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("unable to compile regular expression ('%s') for label ('%s'): %w", value, name, err)
+		}
+		m.re = re
+	default:
+		return nil, fmt.Errorf("unknown match type ('%s') for label ('%s')", matchType, name)
+	}
+
+	return m, nil
+}
+
+// ParseMatcher parses a matcher expressed in AlertManager's syntax, e.g. `severity=~"critical|warning"`.
+// The value may optionally be double quoted.
+func ParseMatcher(s string) (*Matcher, error) {
+	s = strings.TrimSpace(s)
+
+	idx := strings.IndexAny(s, "=!")
+	if idx < 1 {
+		return nil, fmt.Errorf("matcher ('%s') must be of the form <label><operator><value>, where operator is one of '=', '!=', '=~', or '!~'", s)
+	}
+	name := strings.TrimSpace(s[:idx])
+	rest := s[idx:]
+
+	var matchType MatchType
+	for _, t := range []MatchType{MatchRegexp, MatchNotRegexp, MatchNotEqual, MatchEqual} {
+		if strings.HasPrefix(rest, string(t)) {
+			matchType = t
+			break
+		}
+	}
+	if matchType == "" {
+		return nil, fmt.Errorf("matcher ('%s') does not contain a valid operator; expected one of '=', '!=', '=~', or '!~'", s)
+	}
+
+	value := strings.TrimSpace(rest[len(matchType):])
+	if strings.HasPrefix(value, `"`) {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("matcher ('%s') has an invalid quoted value: %w", s, err)
+		}
+		value = unquoted
+	}
+
+	return NewMatcher(name, matchType, value)
+}
+
+// Matches returns true if the labels satisfy the matcher. A missing label is treated as an empty string.
+func (m *Matcher) Matches(labels map[string]string) bool {
+	value := labels[m.Name]
+	switch m.Type {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	}
+	return false
+}
+
+func (m *Matcher) String() string {
+	return fmt.Sprintf("%s%s%q", m.Name, m.Type, m.Value)
+}
+
+// CompileMatchers combines the equality matchers, regular expression matchers, and matcher strings (e.g. 'severity="critical"'), as used by AlertManager's configuration.
+func CompileMatchers(match, matchRE map[string]string, matchers []string) ([]*Matcher, error) {
+	compiled := make([]*Matcher, 0, len(match)+len(matchRE)+len(matchers))
+
+	// sort into alphabetical order, so that compilation errors are reported deterministically
+	for _, name := range keys.Sorted(match) {
+		m, err := NewMatcher(name, MatchEqual, match[name])
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, m)
+	}
+	for _, name := range keys.Sorted(matchRE) {
+		m, err := NewMatcher(name, MatchRegexp, matchRE[name])
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, m)
+	}
+	for _, s := range matchers {
+		m, err := ParseMatcher(s)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, m)
+	}
+
+	return compiled, nil
+}
+
+// MatchesAll returns true if all matchers match the labels. If there are no matchers, it returns true.
+func MatchesAll(matchers []*Matcher, labels map[string]string) bool {
+	for _, m := range matchers {
+		if !m.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package routing
+
+import (
+	"fmt"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/internal/keys"
+)
+
+// Route is a node in the routing tree. It is modelled on AlertManager's route configuration;
+// alerts enter at the root node and descend through the tree, selecting the webhook of the
+// deepest matching node.
+type Route struct {
+	// Webhook is the name of the Discord webhook to which matching alerts are sent.
+	// If empty, the webhook is inherited from the parent route.
+	Webhook string `yaml:"webhook"`
+
+	// Match, MatchRE and Matchers are combined; all must match for the route to match.
+	Match    map[string]string `yaml:"match"`
+	MatchRE  map[string]string `yaml:"match_re"`
+	Matchers []string          `yaml:"matchers"`
+
+	// Continue indicates whether sibling routes should continue to be evaluated after this route has matched.
+	Continue bool `yaml:"continue"`
+
+	Routes []*Route `yaml:"routes"`
+
+	matchers []*Matcher
+}
+
+// Compile parses the matchers of this route and all of its children, and propagates inherited webhooks.
+// It must be called before Select.
+func (r *Route) Compile() error {
+	return r.compile("")
+}
+
+func (r *Route) compile(parentWebhook string) error {
+	if r.Webhook == "" {
+		r.Webhook = parentWebhook
+	}
+
+	matchers, err := CompileMatchers(r.Match, r.MatchRE, r.Matchers)
+	if err != nil {
+		return err
+	}
+	r.matchers = matchers
+
+	for i, child := range r.Routes {
+		if child == nil {
+			return fmt.Errorf("route ('%d') below webhook ('%s') is empty", i, r.Webhook)
+		}
+		if err := child.compile(r.Webhook); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Webhooks returns the names of all webhooks referenced by this route and its children.
+func (r *Route) Webhooks() []string {
+	seen := make(map[string]bool)
+	r.walk(func(route *Route) {
+		if route.Webhook != "" {
+			seen[route.Webhook] = true
+		}
+	})
+	return keys.Sorted(seen)
+}
+
+func (r *Route) walk(fn func(*Route)) {
+	fn(r)
+	for _, child := range r.Routes {
+		child.walk(fn)
+	}
+}
+
+// Select returns the names of the webhooks to which an alert with the given labels should be sent.
+// The root route always matches, regardless of its matchers.
+func (r *Route) Select(labels map[string]string) []string {
+	webhooks := r.match(labels)
+
+	// an alert may match more than one route pointing to the same webhook; it should only be sent once
+	seen := make(map[string]bool, len(webhooks))
+	deduplicated := make([]string, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		if webhook == "" || seen[webhook] {
+			continue
+		}
+		seen[webhook] = true
+		deduplicated = append(deduplicated, webhook)
+	}
+	return deduplicated
+}
+
+func (r *Route) match(labels map[string]string) []string {
+	var webhooks []string
+	for _, child := range r.Routes {
+		if !child.matches(labels) {
+			continue
+		}
+
+		webhooks = append(webhooks, child.match(labels)...)
+		if !child.Continue {
+			break
+		}
+	}
+
+	// no child matched, so this node is the deepest match
+	if len(webhooks) == 0 {
+		webhooks = append(webhooks, r.Webhook)
+	}
+
+	return webhooks
+}
+
+func (r *Route) matches(labels map[string]string) bool {
+	return MatchesAll(r.matchers, labels)
+}
@@ -0,0 +1,87 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Route_NoChildren_SelectsRootWebhook(t *testing.T) {
+	SUT := &Route{Webhook: "default"}
+	assert.NoError(t, SUT.Compile(), "compiling route")
+
+	assert.Equal(t, []string{"default"}, SUT.Select(map[string]string{"alertname": "a"}), "selected webhooks")
+}
+
+func Test_Route_FirstMatchingChild_Wins(t *testing.T) {
+	SUT := &Route{
+		Webhook: "default",
+		Routes: []*Route{
+			{Webhook: "database", Match: map[string]string{"team": "db"}},
+			{Webhook: "critical", MatchRE: map[string]string{"severity": "critical|page"}},
+		},
+	}
+	assert.NoError(t, SUT.Compile(), "compiling route")
+
+	assert.Equal(t, []string{"database"}, SUT.Select(map[string]string{"team": "db", "severity": "critical"}), "first matching route should be selected")
+	assert.Equal(t, []string{"critical"}, SUT.Select(map[string]string{"team": "web", "severity": "page"}), "regular expression route should be selected")
+	assert.Equal(t, []string{"default"}, SUT.Select(map[string]string{"severity": "pagerduty"}), "regular expressions should be anchored")
+}
+
+func Test_Route_Continue_SelectsMultipleWebhooks(t *testing.T) {
+	SUT := &Route{
+		Webhook: "default",
+		Routes: []*Route{
+			{Webhook: "audit", Continue: true},
+			{Webhook: "database", Matchers: []string{`team="db"`}},
+			{Webhook: "never", Matchers: []string{`team="db"`}},
+		},
+	}
+	assert.NoError(t, SUT.Compile(), "compiling route")
+
+	assert.Equal(t, []string{"audit", "database"}, SUT.Select(map[string]string{"team": "db"}), "selected webhooks")
+	assert.Equal(t, []string{"audit"}, SUT.Select(map[string]string{"team": "web"}), "selected webhooks")
+}
+
+func Test_Route_NestedRoutes_InheritWebhook(t *testing.T) {
+	SUT := &Route{
+		Webhook: "default",
+		Routes: []*Route{
+			{
+				Webhook: "database",
+				Match:   map[string]string{"team": "db"},
+				Routes: []*Route{
+					{Matchers: []string{`severity!~"info|debug"`}, Continue: true},
+					{Webhook: "database-info", Matchers: []string{`severity="info"`}},
+				},
+			},
+		},
+	}
+	assert.NoError(t, SUT.Compile(), "compiling route")
+
+	assert.Equal(t, []string{"database"}, SUT.Select(map[string]string{"team": "db", "severity": "critical"}), "child route should inherit webhook")
+	assert.Equal(t, []string{"database-info"}, SUT.Select(map[string]string{"team": "db", "severity": "info"}), "selected webhooks")
+	assert.Equal(t, []string{"database"}, SUT.Select(map[string]string{"team": "db", "severity": "debug"}), "parent should be selected when no child matches")
+	assert.ElementsMatch(t, []string{"default", "database", "database-info"}, SUT.Webhooks(), "all webhooks")
+}
+
+func Test_Route_InvalidMatchers_ReturnError(t *testing.T) {
+	assert.Error(t, (&Route{MatchRE: map[string]string{"a": "("}}).Compile(), "invalid regular expression")
+	assert.Error(t, (&Route{Matchers: []string{"severity"}}).Compile(), "missing operator")
+	assert.Error(t, (&Route{Matchers: []string{`=critical`}}).Compile(), "missing label name")
+	assert.Error(t, (&Route{Matchers: []string{`severity="critical`}}).Compile(), "unterminated quote")
+}
+
+func Test_ParseMatcher_HappyPath(t *testing.T) {
+	m, err := ParseMatcher(`severity =~ "critical|warning"`)
+	assert.NoError(t, err, "parsing matcher")
+	assert.Equal(t, "severity", m.Name, "matcher name")
+	assert.Equal(t, MatchRegexp, m.Type, "matcher type")
+	assert.Equal(t, "critical|warning", m.Value, "matcher value")
+
+	m, err = ParseMatcher(`namespace!=kube-system`)
+	assert.NoError(t, err, "parsing matcher")
+	assert.Equal(t, MatchNotEqual, m.Type, "matcher type")
+	assert.True(t, m.Matches(map[string]string{"namespace": "default"}), "matcher should match")
+	assert.False(t, m.Matches(map[string]string{"namespace": "kube-system"}), "matcher should not match")
+}
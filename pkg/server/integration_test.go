@@ -5,14 +5,18 @@ package server
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -67,15 +71,11 @@ func Test_Serve_HappyPath(t *testing.T) {
 				Status: alertmanager.StatusFiring,
 			},
 		},
-		CommonAnnotations: struct {
-			Summary string `json:"summary"`
-		}{
-			Summary: "a_common_annotation_summary",
+		CommonAnnotations: map[string]string{
+			"summary": "a_common_annotation_summary",
 		},
-		GroupLabels: struct {
-			Alertname string `json:"alertname"`
-		}{
-			Alertname: "testAlertName",
+		GroupLabels: map[string]string{
+			"alertname": "testAlertName",
 		},
 	}
 
@@ -102,6 +102,229 @@ func Test_Serve_HappyPath(t *testing.T) {
 	// TODO assert prometheus metrics were generated
 }
 
+func Test_Serve_Receivers_HappyPath(t *testing.T) {
+	const receiversListenAddress = "127.0.0.1:9097"
+
+	receivedRequestPaths := make(chan string, 2)
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedRequestPaths <- r.URL.Path
+	}))
+	defer mockDiscordServer.Close()
+
+	amds := AlertManagerDiscordServer{
+		Config: &config.Config{
+			Webhooks: []config.Webhook{
+				{Name: "platform", URL: mockDiscordServer.URL + "/platform"},
+				{Name: "frontend", URL: mockDiscordServer.URL + "/frontend"},
+			},
+			Receivers: []config.Receiver{
+				{Name: "platform", Webhook: "platform"},
+				{Name: "frontend", Path: "/frontend-alerts", Webhook: "frontend"},
+			},
+		},
+	}
+	defer func() {
+		err := amds.Shutdown()
+		assert.NoError(t, err, "server shutdown should not error")
+	}()
+
+	_, err := amds.ListenAndServe("", receiversListenAddress)
+	assert.NoError(t, err, "server ListenAndServe should not error")
+
+	client := http.Client{
+		Timeout:   500 * time.Millisecond,
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+
+	aoJson, err := json.Marshal(alertmanager.Out{
+		Alerts: []alertmanager.Alert{
+			{
+				Status: alertmanager.StatusFiring,
+			},
+		},
+	})
+	assert.NoError(t, err, "marshalling alertmanager out")
+
+	for path, expectedDiscordPath := range map[string]string{"/hooks/platform": "/platform", "/frontend-alerts": "/frontend"} {
+		res, err := client.Post(fmt.Sprintf("http://%s%s", receiversListenAddress, path), "application/json", bytes.NewReader(aoJson))
+		assert.NoError(t, err, "sending request to alertmanager-discord server.")
+		assert.NotNil(t, res, "response to POST should not be nil")
+		assert.Equal(t, http.StatusOK, res.StatusCode, "sending valid alertmanager data to a receiver should expect http response status code")
+		res.Body.Close()
+		assert.Equal(t, expectedDiscordPath, <-receivedRequestPaths, "Mock Discord server should have received the request at the receiver's webhook")
+	}
+
+	for _, path := range []string{"/", "/hooks/unknown"} {
+		res, err := client.Post(fmt.Sprintf("http://%s%s", receiversListenAddress, path), "application/json", bytes.NewReader(aoJson))
+		assert.NoError(t, err, "sending request to alertmanager-discord server.")
+		assert.NotNil(t, res, "response to POST should not be nil")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode, "sending data to an unknown path should expect http response status code")
+		res.Body.Close()
+	}
+}
+
+func Test_Serve_TLS_ClientCertificate(t *testing.T) {
+	const tlsListenAddress = "127.0.0.1:9098"
+
+	dir := t.TempDir()
+	serverCertFile, serverKeyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	clientCertFile, clientKeyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	writeCertificate(t, serverCertFile, serverKeyFile, "alertmanager-discord")
+	writeCertificate(t, clientCertFile, clientKeyFile, "alertmanager")
+
+	receivedRequest := make(chan bool, 1)
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedRequest <- true
+	}))
+	defer mockDiscordServer.Close()
+
+	amds := AlertManagerDiscordServer{
+		Config: &config.Config{
+			Auth: &config.Auth{ClientCertificate: &config.ClientCertificate{AllowedNames: []string{"alertmanager"}}},
+		},
+		TLS: &TLSConfig{CertFile: serverCertFile, KeyFile: serverKeyFile, ClientCAFile: clientCertFile},
+	}
+	defer func() {
+		err := amds.Shutdown()
+		assert.NoError(t, err, "server shutdown should not error")
+	}()
+
+	_, err := amds.ListenAndServe(mockDiscordServer.URL, tlsListenAddress)
+	assert.NoError(t, err, "server ListenAndServe should not error")
+
+	serverCA, err := loadCertPool(serverCertFile)
+	assert.NoError(t, err, "loading server CA")
+	clientCertificate, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+	assert.NoError(t, err, "loading client certificate")
+
+	aoJson, err := json.Marshal(alertmanager.Out{Alerts: []alertmanager.Alert{{Status: alertmanager.StatusFiring}}})
+	assert.NoError(t, err, "marshalling alertmanager out")
+
+	client := http.Client{
+		Timeout:   500 * time.Millisecond,
+		Transport: &http.Transport{DisableKeepAlives: true, TLSClientConfig: &tls.Config{RootCAs: serverCA, ServerName: "alertmanager-discord"}},
+	}
+	res, err := client.Get(fmt.Sprintf("https://%s/liveness", tlsListenAddress))
+	assert.NoError(t, err, "sending request without client certificate")
+	assert.Equal(t, http.StatusOK, res.StatusCode, "probes should not require a client certificate")
+	res.Body.Close()
+	res, err = client.Post(fmt.Sprintf("https://%s/", tlsListenAddress), "application/json", bytes.NewReader(aoJson))
+	assert.NoError(t, err, "sending request without client certificate")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode, "alerts without a client certificate should be unauthorized")
+	res.Body.Close()
+
+	client.Transport = &http.Transport{DisableKeepAlives: true, TLSClientConfig: &tls.Config{RootCAs: serverCA, ServerName: "alertmanager-discord", Certificates: []tls.Certificate{clientCertificate}}}
+	res, err = client.Post(fmt.Sprintf("https://%s/", tlsListenAddress), "application/json", bytes.NewReader(aoJson))
+	assert.NoError(t, err, "sending request with client certificate")
+	assert.Equal(t, http.StatusOK, res.StatusCode, "alerts with an allowed client certificate should be forwarded")
+	res.Body.Close()
+	assert.True(t, <-receivedRequest, "Mock Discord server should have received the request")
+}
+
+func Test_Serve_Reload_ReplacesWebhooks(t *testing.T) {
+	const reloadListenAddress = "127.0.0.1:9099"
+
+	receivedRequestPaths := make(chan string, 2)
+	mockDiscordServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedRequestPaths <- r.URL.Path
+	}))
+	defer mockDiscordServer.Close()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(path string) {
+		err := os.WriteFile(configFile, []byte(fmt.Sprintf("webhooks:\n  - name: default\n    url: %s%s\n", mockDiscordServer.URL, path)), 0o600)
+		assert.NoError(t, err, "writing configuration file")
+	}
+	writeConfig("/first")
+	cfg, err := config.LoadFile(configFile)
+	assert.NoError(t, err, "loading configuration file")
+
+	reloaded := make(chan bool, 2)
+	amds := AlertManagerDiscordServer{
+		Config:     cfg,
+		ConfigFile: configFile,
+		LoadConfig: func() (*config.Config, string, error) {
+			cfg, err := config.LoadFile(configFile)
+			return cfg, "", err
+		},
+		OnReload:            func() { reloaded <- true },
+		ConfigWatchInterval: 10 * time.Millisecond,
+	}
+	defer func() {
+		err := amds.Shutdown()
+		assert.NoError(t, err, "server shutdown should not error")
+	}()
+
+	_, err = amds.ListenAndServe("", reloadListenAddress)
+	assert.NoError(t, err, "server ListenAndServe should not error")
+
+	// without keep-alives, the client does not leave unused connections open, which would delay the shutdown of the server
+	client := http.Client{
+		Timeout:   500 * time.Millisecond,
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+	aoJson, err := json.Marshal(alertmanager.Out{Alerts: []alertmanager.Alert{{Status: alertmanager.StatusFiring}}})
+	assert.NoError(t, err, "marshalling alertmanager out")
+	sendAlert := func() string {
+		res, err := client.Post(fmt.Sprintf("http://%s/", reloadListenAddress), "application/json", bytes.NewReader(aoJson))
+		assert.NoError(t, err, "sending request to alertmanager-discord server.")
+		assert.Equal(t, http.StatusOK, res.StatusCode, "sending valid alertmanager data should expect http response status code")
+		res.Body.Close()
+		return <-receivedRequestPaths
+	}
+
+	assert.Equal(t, "/first", sendAlert(), "alerts should be sent to the webhook of the initial configuration")
+
+	// the watcher reloads the configuration once the file changes
+	writeConfig("/second")
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the configuration file to be reloaded")
+	}
+	assert.Equal(t, "/second", sendAlert(), "alerts should be sent to the webhook of the reloaded configuration")
+
+	// an invalid configuration is rejected, and the current configuration continues to be used
+	assert.NoError(t, os.WriteFile(configFile, []byte("webhooks: []\n"), 0o600), "writing invalid configuration file")
+	res, err := client.Post(fmt.Sprintf("http://%s%s", reloadListenAddress, ReloadPath), "", nil)
+	assert.NoError(t, err, "sending reload request")
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode, "reloading an invalid configuration should fail")
+	res.Body.Close()
+	assert.Equal(t, "/second", sendAlert(), "alerts should be sent to the webhook of the current configuration")
+
+	res, err = client.Get(fmt.Sprintf("http://%s%s", reloadListenAddress, ReloadPath))
+	assert.NoError(t, err, "sending reload request")
+	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode, "reload endpoint should only allow POST requests")
+	res.Body.Close()
+
+	// the url file of a webhook is watched along with the configuration file
+	urlFile := filepath.Join(t.TempDir(), "url")
+	waitForReload := func() {
+		select {
+		case <-reloaded:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the configuration to be reloaded")
+		}
+	}
+	assert.NoError(t, os.WriteFile(urlFile, []byte(mockDiscordServer.URL+"/third\n"), 0o600), "writing url file")
+	assert.NoError(t, os.WriteFile(configFile, []byte(fmt.Sprintf("webhooks:\n  - name: default\n    url_file: %s\n", urlFile)), 0o600), "writing configuration file")
+	waitForReload()
+	assert.Equal(t, "/third", sendAlert(), "alerts should be sent to the webhook url read from the file")
+
+	assert.NoError(t, os.WriteFile(urlFile, []byte(mockDiscordServer.URL+"/fourth\n"), 0o600), "writing url file")
+	waitForReload()
+	assert.Equal(t, "/fourth", sendAlert(), "alerts should be sent to the webhook url once the file changes")
+
+	// the template files of the webhooks are also watched
+	templateFile := filepath.Join(t.TempDir(), "title.tmpl")
+	assert.NoError(t, os.WriteFile(templateFile, []byte(`{{ define "discord.title" }}first{{ end }}`), 0o600), "writing template file")
+	assert.NoError(t, os.WriteFile(configFile, []byte(fmt.Sprintf("webhooks:\n  - name: default\n    url_file: %s\n    template_files: [%s]\n", urlFile, templateFile)), 0o600), "writing configuration file")
+	waitForReload()
+	assert.NoError(t, os.WriteFile(templateFile, []byte(`{{ define "discord.title" }}second{{ end }}`), 0o600), "writing template file")
+	waitForReload()
+	assert.Equal(t, "/fourth", sendAlert(), "alerts should be sent once the template file has been reloaded")
+}
+
 // Test with invalid URL, throws an error
 func Test_Server_InvalidDiscordUrl(t *testing.T) {
 	amds := AlertManagerDiscordServer{}
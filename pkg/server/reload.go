@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/metrics"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ReloadPath is the path at which a POST request reloads the configuration.
+	ReloadPath = "/-/reload"
+
+	DefaultConfigWatchInterval = 10 * time.Second
+)
+
+// ConfigLoader reads the configuration, returning it along with the url of the default webhook, if any.
+type ConfigLoader func() (*config.Config, string, error)
+
+// Reload reads the configuration again and atomically replaces the webhooks, routes, receivers, templates, authentication and silences.
+// The listener, TLS, durable queue, dispatcher, and message and deduplication state are retained, and are only changed by a restart.
+// If the configuration is invalid, the current configuration continues to be used.
+func (amds *AlertManagerDiscordServer) Reload() error {
+	_, err := amds.reloadConfig()
+	return err
+}
+
+// reloadConfig reloads the configuration, returning the hash of the configuration file and watched files of the new configuration if successful,
+// otherwise the hash of the files which failed to load.
+func (amds *AlertManagerDiscordServer) reloadConfig() (string, error) {
+	amds.reloadMu.Lock()
+	defer amds.reloadMu.Unlock()
+
+	if amds.LoadConfig == nil {
+		return "", fmt.Errorf("the configuration cannot be reloaded, as it was not read from a file")
+	}
+	current := amds.handler.Load()
+	if current == nil {
+		return "", fmt.Errorf("the server has not been started")
+	}
+
+	state, err := amds.reload(current)
+	if err != nil {
+		hash := amds.configFileHash(current.watchedFiles)
+		reloadedConfig(hash, false)
+		log.Error().Err(err).Msg("Unable to reload configuration. Continuing to use the previous configuration.")
+		return hash, err
+	}
+	// the watched files may differ from those of the previous configuration
+	hash := amds.configFileHash(state.watchedFiles)
+	// the sections which are only applied on restart continue to be those of the configuration with which the server started
+	warnRestartRequired(current.restartOnly, state.restartOnly)
+	state.restartOnly = current.restartOnly
+	amds.handler.Store(state)
+	reloadedConfig(hash, true)
+	if amds.OnReload != nil {
+		amds.OnReload()
+	}
+	log.Info().Msg("Reloaded configuration.")
+	return hash, nil
+}
+
+func (amds *AlertManagerDiscordServer) reload(current *handlerState) (*handlerState, error) {
+	base, webhookUrl, err := amds.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load configuration: %w", err)
+	}
+	cfg, err := prepareConfig(base, webhookUrl)
+	if err != nil {
+		return nil, err
+	}
+	return amds.build(&cfg, current.webhooks)
+}
+
+// warnRestartRequired logs a warning for each section of the configuration which has changed, but which is only applied on restart.
+func warnRestartRequired(current, reloaded restartOnlyConfig) {
+	sections := []struct {
+		key     string
+		changed bool
+	}{
+		{key: "queue", changed: !reflect.DeepEqual(current.Queue, reloaded.Queue)},
+		{key: "dispatcher", changed: !reflect.DeepEqual(current.Dispatcher, reloaded.Dispatcher)},
+		{key: "deduplication", changed: !reflect.DeepEqual(current.Deduplication, reloaded.Deduplication)},
+		{key: "message_state", changed: !reflect.DeepEqual(current.MessageState, reloaded.MessageState)},
+	}
+	for _, section := range sections {
+		if section.changed {
+			log.Warn().Msgf("The '%s' section of the configuration has changed, but is only applied on restart. Continuing to use the previous '%s' configuration.", section.key, section.key)
+		}
+	}
+}
+
+// serveReload reloads the configuration in response to a POST request.
+func (amds *AlertManagerDiscordServer) serveReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Only POST requests are allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := amds.Reload(); err != nil {
+		http.Error(w, fmt.Sprintf("Unable to reload configuration: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// watchConfig reloads the configuration on SIGHUP, and once the contents of the configuration file or its secret or template files change, until the context is cancelled.
+func (amds *AlertManagerDiscordServer) watchConfig(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	interval := amds.ConfigWatchInterval
+	if interval <= 0 {
+		interval = DefaultConfigWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+
+	// a configuration which failed to reload is not retried until it changes again
+	lastHash := amds.configHash
+
+	go func() {
+		defer signal.Stop(hup)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				log.Info().Msg("Received SIGHUP. Reloading configuration...")
+				if hash, err := amds.reloadConfig(); err == nil {
+					lastHash = hash
+				}
+			case <-ticker.C:
+				current := amds.handler.Load()
+				if amds.LoadConfig == nil || current == nil {
+					continue
+				}
+				hash := amds.configFileHash(current.watchedFiles)
+				if hash == "" || hash == lastHash {
+					continue
+				}
+				log.Info().Msgf("Configuration file ('%s') or its secret or template files have changed. Reloading configuration...", amds.ConfigFile)
+				lastHash, _ = amds.reloadConfig()
+			}
+		}
+	}()
+}
+
+// configFileHash returns the SHA-256 hash of the contents of the configuration file and of the watched files, e.g. the url files and template files of webhooks,
+// or an empty string if there are no files or the configuration file cannot be read.
+// Watched files which cannot be read are hashed as empty, e.g. while a Kubernetes secret is being updated.
+func (amds *AlertManagerDiscordServer) configFileHash(watchedFiles []string) string {
+	if amds.ConfigFile == "" && len(watchedFiles) == 0 {
+		return ""
+	}
+
+	h := sha256.New()
+	if amds.ConfigFile != "" {
+		b, err := os.ReadFile(amds.ConfigFile)
+		if err != nil {
+			return ""
+		}
+		sum := sha256.Sum256(b)
+		h.Write(sum[:])
+	}
+	for _, file := range watchedFiles {
+		b, _ := os.ReadFile(file)
+		sum := sha256.Sum256(b)
+		h.Write(sum[:])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// reloadedConfig reports the result of loading the configuration, and the hash of the configuration file, as metrics.
+func reloadedConfig(hash string, successful bool) {
+	if !successful {
+		metrics.ConfigLastReloadSuccessful.Set(0)
+		return
+	}
+	metrics.ConfigLastReloadSuccessful.Set(1)
+	metrics.ConfigLastReloadSuccessTimestampSeconds.SetToCurrentTime()
+
+	// the first 6 bytes of the hash are exactly representable as a float
+	value := 0.0
+	if b, err := hex.DecodeString(hash); err == nil && len(b) >= 6 {
+		value = float64(binary.BigEndian.Uint64(append([]byte{0, 0}, b[:6]...)))
+	}
+	metrics.ConfigHash.Set(value)
+}
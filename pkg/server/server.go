@@ -2,14 +2,24 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/specklesystems/alertmanager-discord/pkg/alertforwarder"
+	"github.com/specklesystems/alertmanager-discord/pkg/auth"
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/dispatcher"
+	"github.com/specklesystems/alertmanager-discord/pkg/mapping"
 	"github.com/specklesystems/alertmanager-discord/pkg/metrics"
+	"github.com/specklesystems/alertmanager-discord/pkg/queue"
+	"github.com/specklesystems/alertmanager-discord/pkg/silence"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog/log"
@@ -27,39 +37,123 @@ const (
 type AlertManagerDiscordServer struct {
 	httpServer                *http.Server
 	MaximumBackoffTimeSeconds time.Duration
+	// Config optionally provides additional named webhooks, a routing tree, and receivers served at their own paths.
+	// The webhook url provided to ListenAndServe, if any, is added as the webhook named 'default'.
+	Config *config.Config
+	// TLS optionally serves HTTPS rather than plaintext HTTP.
+	TLS *TLSConfig
+
+	// LoadConfig, if provided, is called to read the configuration again when it is reloaded.
+	LoadConfig ConfigLoader
+	// OnReload, if provided, is called once the configuration has been reloaded, e.g. to apply settings which are not part of Config.
+	OnReload func()
+	// ConfigFile, if provided, is watched for changes, upon which the configuration is reloaded.
+	// The secret and template files of the configuration, e.g. the url files of webhooks, are also watched if LoadConfig is provided.
+	ConfigFile string
+	// ConfigWatchInterval is the interval at which the configuration file is checked for changes. Defaults to 10 seconds.
+	ConfigWatchInterval time.Duration
+
+	// cancelBackground stops any background workers, e.g. the durable queue
+	cancelBackground context.CancelFunc
+	// forwarderOptions are those of the background workers, which are retained when the configuration is reloaded
+	forwarderOptions []alertforwarder.Option
+	// handler is replaced atomically when the configuration is reloaded
+	handler atomic.Pointer[handlerState]
+	// reloadMu prevents concurrent reloads
+	reloadMu sync.Mutex
+	// configHash is the hash of the configuration file and watched files when the server started, from which changes are watched
+	configHash string
+}
+
+// handlerState is the part of the server which is built from the configuration, and replaced when it is reloaded.
+type handlerState struct {
+	handler      http.Handler
+	webhooks     *alertforwarder.Webhooks
+	authenticate func(http.Handler) http.Handler
+	// watchedFiles, i.e. the secret and template files, are watched for changes along with the configuration file
+	watchedFiles []string
+	// restartOnly are the sections of the configuration which are only applied when the server starts
+	restartOnly restartOnlyConfig
+}
+
+// restartOnlyConfig are the sections of the configuration which are retained when the configuration is reloaded.
+type restartOnlyConfig struct {
+	Queue         *config.Queue
+	Dispatcher    *config.Dispatcher
+	Deduplication *config.Deduplication
+	MessageState  *config.MessageState
 }
 
 func (amds *AlertManagerDiscordServer) ListenAndServe(webhookUrl, listenAddress string) (chan os.Signal, error) {
 	stop := make(chan os.Signal, 1)
 	mux := http.NewServeMux()
 
-	ok, _, err := alertforwarder.CheckWebhookURL(webhookUrl)
-	if !ok {
-		return stop, fmt.Errorf("url is invalid: %w", err)
+	cfg, err := prepareConfig(amds.Config, webhookUrl)
+	if err != nil {
+		return stop, err
 	}
 
 	if listenAddress == "" {
 		log.Info().Msgf("Listen address not provided. Using default: '%s'", DefaultListenAddress)
 		listenAddress = DefaultListenAddress
 	}
+	var tlsConfig *tls.Config
+	if amds.TLS.Enabled() {
+		if tlsConfig, err = newTLSConfig(amds.TLS); err != nil {
+			return stop, err
+		}
+	}
 	log.Info().Msgf("Listening on: %s", listenAddress)
 
-	discordClient := &http.Client{
-		Timeout: 5 * time.Second,
+	backgroundCtx, cancelBackground := context.WithCancel(context.Background())
+	amds.cancelBackground = cancelBackground
+
+	var workers []func(context.Context, queue.DeliverFunc)
+	if cfg.Queue != nil {
+		q, err := queue.Open(cfg.Queue.Directory, time.Duration(cfg.Queue.MaxAgeSeconds)*time.Second)
+		if err != nil {
+			return stop, err
+		}
+		log.Info().Msgf("Messages will be persisted to the durable queue at directory ('%s') before delivery.", cfg.Queue.Directory)
+		workers = append(workers, q.Run)
+		amds.forwarderOptions = append(amds.forwarderOptions, alertforwarder.WithQueue(q))
 	}
 
-	transformAndForwardWithInstrumentation := promhttp.InstrumentHandlerDuration(metrics.RequestsToAlertForwarderDuration,
-		promhttp.InstrumentHandlerCounter(metrics.RequestsToAlertForwarderTotal,
-			promhttp.InstrumentHandlerInFlight(metrics.RequestsToAlertForwarderInFlight,
-				alertforwarder.NewAlertForwarderHandler(discordClient,
-					webhookUrl,
-					amds.MaximumBackoffTimeSeconds,
-				),
-			),
-		),
-	)
+	if cfg.Dispatcher != nil {
+		d := dispatcher.New(cfg.Dispatcher.Workers, cfg.Dispatcher.QueueSize)
+		retryAfter := dispatcher.DefaultRetryAfter
+		if cfg.Dispatcher.RetryAfterSeconds > 0 {
+			retryAfter = time.Duration(cfg.Dispatcher.RetryAfterSeconds) * time.Second
+		}
+		log.Info().Msg("Messages will be delivered asynchronously by the dispatcher.")
+		workers = append(workers, d.Run)
+		amds.forwarderOptions = append(amds.forwarderOptions, alertforwarder.WithDispatcher(d, retryAfter))
+	}
+
+	state, err := amds.build(&cfg, nil)
+	if err != nil {
+		return stop, err
+	}
+	amds.handler.Store(state)
+	amds.configHash = amds.configFileHash(state.watchedFiles)
+	reloadedConfig(amds.configHash, true)
+
+	// background workers deliver messages using the webhooks of the current configuration
+	deliver := func(message queue.Message) error {
+		return amds.handler.Load().webhooks.Deliver(message)
+	}
+	for _, run := range workers {
+		go run(backgroundCtx, deliver)
+	}
+
+	// the alert forwarder, receivers, and silence endpoint are replaced when the configuration is reloaded
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		amds.handler.Load().handler.ServeHTTP(w, r)
+	}))
 
-	mux.HandleFunc("/", transformAndForwardWithInstrumentation)
+	mux.Handle(ReloadPath, instrumentAlertForwarderHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		amds.handler.Load().authenticate(http.HandlerFunc(amds.serveReload)).ServeHTTP(w, r)
+	})))
 
 	mux.HandleFunc("/readiness", func(w http.ResponseWriter, r *http.Request) {
 		log.Debug().Msg("Readiness probe encountered.")
@@ -81,27 +175,238 @@ func (amds *AlertManagerDiscordServer) ListenAndServe(webhookUrl, listenAddress
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 1 << 20,
+		TLSConfig:      tlsConfig,
+	}
+
+	// bind to the address before returning, so that the server is able to accept connections as soon as this function returns
+	listener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		return stop, fmt.Errorf("unable to listen on address ('%s'): %w", listenAddress, err)
 	}
 
 	// Setting up signal capturing
 	signal.Notify(stop, os.Interrupt)
+	amds.watchConfig(backgroundCtx)
 
+	httpServer := amds.httpServer
 	go func() {
-		// check for nil prevents race condition if we have already shutdown the server before this goroutine attempts to start
-		if amds.httpServer != nil {
-			if err := amds.httpServer.ListenAndServe(); err != nil {
-				close(stop)
-			}
+		var err error
+		if tlsConfig != nil {
+			// the certificate is provided by the TLS config, so that it is reloaded when its files change
+			err = httpServer.ServeTLS(listener, "", "")
+		} else {
+			err = httpServer.Serve(listener)
+		}
+		if err != nil {
+			close(stop)
 		}
 	}()
 
 	return stop, nil
 }
 
+// prepareConfig copies the configuration, adds the default webhook, validates it, and reads the webhook urls from their files.
+func prepareConfig(base *config.Config, webhookUrl string) (config.Config, error) {
+	cfg := config.Config{}
+	if base != nil {
+		cfg = *base
+		cfg.Webhooks = append([]config.Webhook{}, base.Webhooks...)
+	}
+
+	if len(cfg.Webhooks) == 0 {
+		// without any named webhooks, the webhook url is required
+		ok, _, err := alertforwarder.CheckWebhookURL(webhookUrl)
+		if !ok {
+			return cfg, fmt.Errorf("url is invalid: %w", err)
+		}
+	}
+
+	cfg.AddDefaultWebhook(webhookUrl, 0)
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("configuration is invalid: %w", err)
+	}
+	if err := cfg.ReadWebhookURLs(); err != nil {
+		return cfg, err
+	}
+	for _, webhook := range cfg.Webhooks {
+		ok, _, err := alertforwarder.CheckWebhookURL(webhook.URL)
+		if !ok {
+			return cfg, fmt.Errorf("url of webhook ('%s') is invalid: %w", webhook.Name, err)
+		}
+	}
+	return cfg, nil
+}
+
+// build creates the handlers of the alert forwarder, receivers, and silence endpoint from the configuration.
+// The message, deduplication, and forwarded alert state of the previous webhooks, if any, is retained, as are the Discord clients of unchanged webhooks.
+func (amds *AlertManagerDiscordServer) build(cfg *config.Config, previous *alertforwarder.Webhooks) (*handlerState, error) {
+	mux := http.NewServeMux()
+
+	if cfg.Auth != nil && cfg.Auth.ClientCertificate != nil && (!amds.TLS.Enabled() || amds.TLS.ClientCAFile == "") {
+		return nil, fmt.Errorf("client certificates can only be authenticated if TLS is configured with a client CA file")
+	}
+
+	discordClient := &http.Client{
+		Timeout: 5 * time.Second,
+	}
+
+	webhooks, err := alertforwarder.ReloadWebhooks(discordClient, cfg, amds.MaximumBackoffTimeSeconds, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	decoders := cfg.Decoders()
+	forwarderOptions := append([]alertforwarder.Option{alertforwarder.WithDecoders(decoders)}, amds.forwarderOptions...)
+	if cfg.Silences != nil {
+		signer, handler, err := newSilences(cfg.Silences)
+		if err != nil {
+			return nil, err
+		}
+		log.Info().Msgf("Serving silence endpoint at path: '%s'", silence.Path)
+		mux.Handle(silence.Path, handler)
+		forwarderOptions = append(forwarderOptions, alertforwarder.WithSilenceLinks(signer))
+	}
+
+	// alert forwarder handlers are authenticated, if configured; probes, metrics and the signed silence links are not
+	authenticate := func(handler http.Handler) http.Handler { return handler }
+	if cfg.Auth != nil {
+		authenticator, err := newAuthenticator(cfg.Auth)
+		if err != nil {
+			return nil, err
+		}
+		log.Info().Msg("Requests to alert forwarder will be authenticated.")
+		authenticate = authenticator.Handler
+	}
+
+	var rootHandler http.Handler = http.NotFoundHandler()
+	if cfg.Route != nil {
+		rootHandler = alertforwarder.NewRoutingAlertForwarderHandler(webhooks, cfg.Route, forwarderOptions...)
+	}
+	if len(cfg.Receivers) > 0 {
+		// once receivers are configured, the root route is only served at the root path; all other unknown paths are not found
+		rootHandler = exactPathHandler("/", rootHandler)
+	}
+
+	// the catch-all handler is instrumented, so that requests to unknown paths are also counted
+	mux.HandleFunc("/", instrumentAlertForwarderHandler(authenticate(rootHandler)))
+
+	for _, receiver := range cfg.Receivers {
+		receiverOptions := forwarderOptions
+		if d, ok := decoders.Lookup(receiver.Format); ok {
+			receiverOptions = append(append([]alertforwarder.Option{}, forwarderOptions...), alertforwarder.WithDecoder(d))
+		}
+		afh := alertforwarder.NewRoutingAlertForwarderHandler(webhooks, receiver.ReceiverRoute(), receiverOptions...)
+
+		log.Info().Msgf("Serving receiver ('%s') at path: '%s'", receiver.Name, receiver.ReceiverPath())
+		mux.HandleFunc(receiver.ReceiverPath(), instrumentAlertForwarderHandler(authenticate(afh)))
+	}
+
+	for _, m := range cfg.Mappings {
+		handler, err := mapping.NewHandler(m, webhooks)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Info().Msgf("Serving mapping ('%s') at path: '%s'", m.Name, m.MappingPath())
+		mux.HandleFunc(m.MappingPath(), instrumentAlertForwarderHandler(authenticate(handler)))
+	}
+
+	return &handlerState{
+		handler:      mux,
+		webhooks:     webhooks,
+		authenticate: authenticate,
+		watchedFiles: cfg.WatchedFiles(),
+		restartOnly: restartOnlyConfig{
+			Queue:         cfg.Queue,
+			Dispatcher:    cfg.Dispatcher,
+			Deduplication: cfg.Deduplication,
+			MessageState:  cfg.MessageState,
+		},
+	}, nil
+}
+
+// newSilences creates the signer of links to the silence endpoint, and the handler of the endpoint.
+func newSilences(cfg *config.Silences) (*silence.Signer, http.Handler, error) {
+	key, err := config.ReadSecret(cfg.SigningKey, cfg.SigningKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if key == "" {
+		return nil, nil, fmt.Errorf("the signing key of silence links is empty")
+	}
+	password, err := config.ReadSecret(cfg.Password, cfg.PasswordFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	bearerToken, err := config.ReadSecret(cfg.BearerToken, cfg.BearerTokenFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	durations, err := cfg.ParsedDurations()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signer := silence.NewSigner([]byte(key), cfg.ExternalURL, time.Duration(cfg.LinkTTLSeconds)*time.Second, durations)
+	client := silence.NewClient(
+		&http.Client{Timeout: 5 * time.Second},
+		cfg.AlertManagerURL,
+		silence.Credentials{Username: cfg.Username, Password: password, BearerToken: bearerToken},
+		cfg.CreatedBy,
+	)
+	return signer, silence.NewHandler(signer, client), nil
+}
+
+// newAuthenticator creates the authenticator of requests to alert forwarder.
+func newAuthenticator(cfg *config.Auth) (*auth.Authenticator, error) {
+	bearerToken, err := config.ReadSecret(cfg.BearerToken, cfg.BearerTokenFile)
+	if err != nil {
+		return nil, err
+	}
+	if bearerToken == "" && cfg.BearerTokenFile != "" {
+		return nil, fmt.Errorf("the bearer token file ('%s') is empty", cfg.BearerTokenFile)
+	}
+
+	options := auth.Options{
+		BearerToken:    bearerToken,
+		BasicAuthUsers: cfg.BasicAuthUsers,
+	}
+	if cfg.ClientCertificate != nil {
+		options.RequireClientCertificate = true
+		options.AllowedNames = cfg.ClientCertificate.AllowedNames
+	}
+	return auth.NewAuthenticator(options), nil
+}
+
+func instrumentAlertForwarderHandler(handler http.Handler) http.HandlerFunc {
+	return promhttp.InstrumentHandlerDuration(metrics.RequestsToAlertForwarderDuration,
+		promhttp.InstrumentHandlerCounter(metrics.RequestsToAlertForwarderTotal,
+			promhttp.InstrumentHandlerInFlight(metrics.RequestsToAlertForwarderInFlight,
+				handler,
+			),
+		),
+	)
+}
+
+// exactPathHandler responds with 404 Not Found to any request which does not exactly match the path.
+func exactPathHandler(path string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			log.Debug().Msgf("Request received for unknown path: '%s'", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
 func (amds *AlertManagerDiscordServer) Shutdown() error {
 	log.Info().Msg("Received signal to shut down server. Shutting down server...")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	if amds.cancelBackground != nil {
+		amds.cancelBackground()
+	}
 	if amds.httpServer == nil {
 		// http server is not referenced, or was never created, so we're unable to shut it down
 		return nil
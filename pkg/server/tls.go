@@ -0,0 +1,211 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultTLSMinVersion = "1.2"
+
+	// tlsReloadInterval is the minimum interval between checks of whether the certificate files have changed
+	tlsReloadInterval = 5 * time.Second
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig configures the listener to serve HTTPS. Certificates are reloaded when their files change, e.g. once rotated by cert-manager.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile, if provided, is used to verify client certificates. Requests without a client certificate are still accepted,
+	// so that probes do not require one; client certificates are required by configuring authentication.
+	ClientCAFile string
+	// MinVersion is one of '1.0', '1.1', '1.2', or '1.3'. Defaults to '1.2'.
+	MinVersion string
+	// CipherSuites are the names of the cipher suites of TLS 1.2 and below, e.g. 'TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256'. Defaults to those of Go.
+	CipherSuites []string
+}
+
+// Enabled returns true if a certificate has been configured.
+func (c *TLSConfig) Enabled() bool {
+	return c != nil && (c.CertFile != "" || c.KeyFile != "")
+}
+
+// newTLSConfig creates the configuration of the TLS listener, loading the certificate and client CA.
+func newTLSConfig(c *TLSConfig) (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("both a TLS certificate file and key file are required")
+	}
+
+	minVersion := DefaultTLSMinVersion
+	if c.MinVersion != "" {
+		minVersion = c.MinVersion
+	}
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("minimum TLS version ('%s') is invalid. Acceptable values are '1.0', '1.1', '1.2', or '1.3'", minVersion)
+	}
+
+	cipherSuites, err := parseCipherSuites(c.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate := newFileReloader([]string{c.CertFile, c.KeyFile}, func() (*tls.Certificate, error) {
+		certificate, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load TLS certificate ('%s') and key ('%s'): %w", c.CertFile, c.KeyFile, err)
+		}
+		return &certificate, nil
+	})
+	if _, err := certificate.get(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:   version,
+		CipherSuites: cipherSuites,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return certificate.get()
+		},
+	}
+	if c.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	clientCAs := newFileReloader([]string{c.ClientCAFile}, func() (*x509.CertPool, error) {
+		return loadCertPool(c.ClientCAFile)
+	})
+	if _, err := clientCAs.get(); err != nil {
+		return nil, err
+	}
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		pool, err := clientCAs.get()
+		if err != nil {
+			return nil, err
+		}
+		clientConfig := tlsConfig.Clone()
+		clientConfig.GetConfigForClient = nil
+		clientConfig.ClientCAs = pool
+		return clientConfig, nil
+	}
+	return tlsConfig, nil
+}
+
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("cipher suite ('%s') is unknown or insecure", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read TLS client CA file ('%s'): %w", file, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("TLS client CA file ('%s') does not contain any PEM encoded certificates", file)
+	}
+	return pool, nil
+}
+
+// fileReloader loads a value from files, and reloads it once any of the files has been modified.
+// If reloading fails, the previously loaded value continues to be used.
+type fileReloader[T any] struct {
+	files    []string
+	load     func() (T, error)
+	interval time.Duration
+
+	mu        sync.Mutex
+	value     T
+	loaded    bool
+	modTimes  []time.Time
+	checkedAt time.Time
+}
+
+func newFileReloader[T any](files []string, load func() (T, error)) *fileReloader[T] {
+	return &fileReloader[T]{files: files, load: load, interval: tlsReloadInterval}
+}
+
+func (r *fileReloader[T]) get() (T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if r.loaded && now.Sub(r.checkedAt) < r.interval {
+		return r.value, nil
+	}
+	r.checkedAt = now
+
+	modTimes := make([]time.Time, 0, len(r.files))
+	for _, file := range r.files {
+		info, err := os.Stat(file)
+		if err != nil {
+			if r.loaded {
+				log.Error().Err(err).Msgf("Unable to check whether file ('%s') has changed. Continuing to use the previously loaded file.", file)
+				return r.value, nil
+			}
+			return r.value, fmt.Errorf("unable to read file ('%s'): %w", file, err)
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	if r.loaded && equalTimes(modTimes, r.modTimes) {
+		return r.value, nil
+	}
+
+	value, err := r.load()
+	if err != nil {
+		if r.loaded {
+			log.Error().Err(err).Msg("Unable to reload TLS files. Continuing to use the previously loaded files.")
+			return r.value, nil
+		}
+		return value, err
+	}
+	if r.loaded {
+		log.Info().Msgf("Reloaded TLS files: %v", r.files)
+	}
+	r.value = value
+	r.loaded = true
+	r.modTimes = modTimes
+	return value, nil
+}
+
+func equalTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
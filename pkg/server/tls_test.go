@@ -0,0 +1,145 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/routing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FileReloader_ReloadsWhenFilesChange(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCertificate(t, certFile, keyFile, "first")
+
+	SUT := newFileReloader([]string{certFile, keyFile}, func() (*tls.Certificate, error) {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		return &certificate, err
+	})
+	// check the files on every call, so that changes are detected immediately
+	SUT.interval = 0
+
+	certificate, err := SUT.get()
+	assert.NoError(t, err, "loading certificate")
+	assert.Equal(t, "first", commonName(t, certificate), "initial certificate")
+
+	writeCertificate(t, certFile, keyFile, "second")
+	future := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(certFile, future, future), "updating modification time")
+	certificate, err = SUT.get()
+	assert.NoError(t, err, "reloading certificate")
+	assert.Equal(t, "second", commonName(t, certificate), "certificate should be reloaded once its file changes")
+
+	assert.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0o600), "corrupting key")
+	later := future.Add(time.Minute)
+	assert.NoError(t, os.Chtimes(keyFile, later, later), "updating modification time")
+	certificate, err = SUT.get()
+	assert.NoError(t, err, "an invalid file should not return an error once a certificate has been loaded")
+	assert.Equal(t, "second", commonName(t, certificate), "previous certificate should continue to be used")
+}
+
+func Test_Build_ClientCertificateAuthWithoutClientCA_ReturnsError(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCertificate(t, certFile, keyFile, "server")
+	cfg := &config.Config{
+		Webhooks: []config.Webhook{{Name: "default", URL: "https://discord.com/api/webhooks/123456789123456789/abc"}},
+		Route:    &routing.Route{Webhook: "default"},
+		Auth:     &config.Auth{ClientCertificate: &config.ClientCertificate{}},
+	}
+
+	for name, tlsConfig := range map[string]*TLSConfig{
+		"without TLS":       nil,
+		"without client CA": {CertFile: certFile, KeyFile: keyFile},
+	} {
+		SUT := &AlertManagerDiscordServer{TLS: tlsConfig}
+		_, err := SUT.build(cfg, nil)
+		assert.Error(t, err, "client certificate authentication %s would reject every request, so should not be configured", name)
+	}
+}
+
+func Test_NewTLSConfig_Options(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCertificate(t, certFile, keyFile, "server")
+
+	tlsConfig, err := newTLSConfig(&TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	assert.NoError(t, err, "creating TLS config")
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion, "minimum version should default to TLS 1.2")
+	certificate, err := tlsConfig.GetCertificate(nil)
+	assert.NoError(t, err, "getting certificate")
+	assert.Equal(t, "server", commonName(t, certificate), "certificate")
+
+	tlsConfig, err = newTLSConfig(&TLSConfig{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: certFile,
+		MinVersion:   "1.3",
+		CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+	})
+	assert.NoError(t, err, "creating TLS config")
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion, "minimum version")
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, tlsConfig.CipherSuites, "cipher suites")
+	assert.Equal(t, tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth, "client certificates should be verified if given")
+
+	clientConfig, err := tlsConfig.GetConfigForClient(nil)
+	assert.NoError(t, err, "getting config for client")
+	assert.NotNil(t, clientConfig.ClientCAs, "client CAs should be loaded")
+
+	_, err = newTLSConfig(&TLSConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.4"})
+	assert.Error(t, err, "unknown minimum version should return an error")
+	_, err = newTLSConfig(&TLSConfig{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}})
+	assert.Error(t, err, "insecure cipher suite should return an error")
+	_, err = newTLSConfig(&TLSConfig{CertFile: certFile})
+	assert.Error(t, err, "missing key file should return an error")
+	_, err = newTLSConfig(&TLSConfig{CertFile: certFile, KeyFile: filepath.Join(dir, "does_not_exist.key")})
+	assert.Error(t, err, "unreadable key file should return an error")
+	_, err = newTLSConfig(&TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile})
+	assert.Error(t, err, "client CA file without certificates should return an error")
+}
+
+// writeCertificate writes a self-signed certificate, which may also be used as its own CA.
+func writeCertificate(t *testing.T, certFile, keyFile, name string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err, "generating key")
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err, "creating certificate")
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err, "marshalling key")
+
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600), "writing certificate")
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600), "writing key")
+}
+
+func commonName(t *testing.T, certificate *tls.Certificate) string {
+	t.Helper()
+	parsed, err := x509.ParseCertificate(certificate.Certificate[0])
+	assert.NoError(t, err, "parsing certificate")
+	return parsed.Subject.CommonName
+}
@@ -0,0 +1,73 @@
+package severity
+
+import (
+	"strings"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+)
+
+// DefaultLabel is the label whose value is the severity of an alert, unless configured otherwise.
+const DefaultLabel = "severity"
+
+// Level is a severity, and the colour and emoji with which notifications of that severity are displayed.
+type Level struct {
+	Name  string
+	Color int
+	// Emoji prefixes the title of notifications of the severity, if not empty.
+	Emoji string
+}
+
+// DefaultLevels are the severities, from the highest to the lowest, unless configured otherwise.
+var DefaultLevels = []Level{
+	{Name: "critical", Color: discord.ColorRed, Emoji: "🔥"},
+	{Name: "warning", Color: discord.ColorOrange, Emoji: "⚠️"},
+	{Name: "info", Color: discord.ColorBlue, Emoji: "ℹ️"},
+}
+
+// Severities determines the severity of alerts from the value of their severity label.
+type Severities struct {
+	label string
+	// levels are ordered from the highest severity to the lowest
+	levels []Level
+}
+
+// New creates the severities of the label, ordered from the highest to the lowest.
+// The default label and levels are used if either is empty.
+func New(label string, levels []Level) *Severities {
+	if label == "" {
+		label = DefaultLabel
+	}
+	if len(levels) == 0 {
+		levels = DefaultLevels
+	}
+	return &Severities{label: label, levels: levels}
+}
+
+// Default returns the default levels of the default label.
+func Default() *Severities {
+	return New("", nil)
+}
+
+// Highest returns the highest level of the alerts, or false if none of the alerts has a known severity.
+func (s *Severities) Highest(alerts []alertmanager.Alert) (Level, bool) {
+	highest, found := -1, Level{}
+	for _, alert := range alerts {
+		if i, level, ok := s.find(alert.Labels[s.label]); ok && (highest < 0 || i < highest) {
+			highest, found = i, level
+		}
+	}
+	return found, highest >= 0
+}
+
+func (s *Severities) find(value string) (int, Level, bool) {
+	if value == "" {
+		return 0, Level{}, false
+	}
+	for i, level := range s.levels {
+		if strings.EqualFold(level.Name, value) {
+			return i, level, true
+		}
+	}
+	return 0, Level{}, false
+}
@@ -0,0 +1,60 @@
+package severity
+
+import (
+	"testing"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func alertOf(labels map[string]string) alertmanager.Alert {
+	return alertmanager.Alert{Status: alertmanager.StatusFiring, Labels: labels}
+}
+
+func Test_Highest_ReturnsTheHighestSeverityOfTheGroup(t *testing.T) {
+	SUT := Default()
+
+	level, ok := SUT.Highest([]alertmanager.Alert{
+		alertOf(map[string]string{"severity": "info"}),
+		alertOf(map[string]string{"severity": "Critical"}),
+		alertOf(map[string]string{"severity": "warning"}),
+	})
+	assert.True(t, ok, "a severity should be found")
+	assert.Equal(t, "critical", level.Name, "the highest severity should be chosen, ignoring case")
+	assert.Equal(t, discord.ColorRed, level.Color, "critical should be dark red")
+	assert.Equal(t, "🔥", level.Emoji, "critical should be prefixed with fire")
+
+	level, ok = SUT.Highest([]alertmanager.Alert{
+		alertOf(map[string]string{"severity": "info"}),
+		alertOf(map[string]string{"severity": "warning"}),
+	})
+	assert.True(t, ok, "a severity should be found")
+	assert.Equal(t, discord.ColorOrange, level.Color, "warning should be orange")
+}
+
+func Test_Highest_UnknownSeverity_ReturnsFalse(t *testing.T) {
+	SUT := Default()
+
+	_, ok := SUT.Highest([]alertmanager.Alert{
+		alertOf(map[string]string{"alertname": "HighCPU"}),
+		alertOf(map[string]string{"severity": "page"}),
+	})
+	assert.False(t, ok, "alerts without a known severity should not have one")
+}
+
+func Test_New_CustomLabelAndLevels(t *testing.T) {
+	SUT := New("priority", []Level{
+		{Name: "P1", Color: 0xFF0000, Emoji: "🚨"},
+		{Name: "P2", Color: 0x00FF00},
+	})
+
+	level, ok := SUT.Highest([]alertmanager.Alert{
+		alertOf(map[string]string{"severity": "critical", "priority": "P2"}),
+		alertOf(map[string]string{"priority": "P1"}),
+	})
+	assert.True(t, ok, "a severity should be found")
+	assert.Equal(t, "P1", level.Name, "the configured label and levels should be used")
+	assert.Equal(t, "🚨", level.Emoji, "the configured emoji should be used")
+}
@@ -0,0 +1,116 @@
+package silence
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/internal/keys"
+)
+
+const (
+	DefaultCreatedBy = "alertmanager-discord"
+
+	silencesPath = "/api/v2/silences"
+)
+
+// Credentials authenticate requests to the AlertManager API. At most one of basic authentication or a bearer token should be provided.
+type Credentials struct {
+	Username    string
+	Password    string
+	BearerToken string
+}
+
+// Client creates silences via the AlertManager v2 API.
+type Client struct {
+	httpClient  *http.Client
+	url         string
+	credentials Credentials
+	createdBy   string
+}
+
+func NewClient(client *http.Client, alertManagerURL string, credentials Credentials, createdBy string) *Client {
+	if createdBy == "" {
+		createdBy = DefaultCreatedBy
+	}
+	return &Client{
+		httpClient:  client,
+		url:         strings.TrimSuffix(alertManagerURL, "/"),
+		credentials: credentials,
+		createdBy:   createdBy,
+	}
+}
+
+type matcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+type postableSilence struct {
+	Matchers  []matcher `json:"matchers"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+	CreatedBy string    `json:"createdBy"`
+	Comment   string    `json:"comment"`
+}
+
+type silenceCreated struct {
+	SilenceID string `json:"silenceID"`
+}
+
+// CreateSilence silences alerts with exactly the labels for the duration, returning the id of the silence.
+func (c *Client) CreateSilence(labels map[string]string, duration time.Duration, comment string) (string, error) {
+	now := time.Now().UTC()
+	silence := postableSilence{
+		StartsAt:  now,
+		EndsAt:    now.Add(duration),
+		CreatedBy: c.createdBy,
+		Comment:   comment,
+	}
+	for _, name := range keys.Sorted(labels) {
+		silence.Matchers = append(silence.Matchers, matcher{Name: name, Value: labels[name], IsEqual: true})
+	}
+
+	b, err := json.Marshal(silence)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal silence to json: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url+silencesPath, bytes.NewReader(b))
+	if err != nil {
+		return "", fmt.Errorf("unable to create request to AlertManager: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	switch {
+	case c.credentials.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.credentials.BearerToken)
+	case c.credentials.Username != "":
+		req.SetBasicAuth(c.credentials.Username, c.credentials.Password)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to send request to AlertManager: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("unable to read response from AlertManager: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("AlertManager responded with status code %d: %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	created := silenceCreated{}
+	if err := json.Unmarshal(body, &created); err != nil {
+		return "", fmt.Errorf("unable to parse response from AlertManager: %w", err)
+	}
+	return created.SilenceID, nil
+}
@@ -0,0 +1,124 @@
+package silence
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/logging"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	comment = "Silenced from Discord."
+)
+
+var page = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Silence alert</title>
+</head>
+<body>
+<h1>{{ .Heading }}</h1>
+{{ with .Filter }}<p><code>{{ . }}</code></p>{{ end }}
+{{ with .Message }}<p>{{ . }}</p>{{ end }}
+{{ if .Durations }}
+<form method="post">
+{{ range $key, $value := .Query }}<input type="hidden" name="{{ $key }}" value="{{ index $value 0 }}">
+{{ end }}
+{{ range .Durations }}<button type="submit" name="d" value="{{ . }}">Silence for {{ . }}</button>
+{{ end }}
+</form>
+{{ end }}
+</body>
+</html>
+`))
+
+type pageData struct {
+	Heading   string
+	Filter    string
+	Message   string
+	Query     map[string][]string
+	Durations []string
+}
+
+// Handler serves the links created by the Signer.
+// A GET request responds with a page on which the duration is chosen, so that previews of the link (e.g. by a chat client) do not create silences.
+// A POST request from that page creates the silence via the AlertManager API.
+type Handler struct {
+	signer *Signer
+	client *Client
+}
+
+func NewHandler(signer *Signer, client *Client) *Handler {
+	return &Handler{signer: signer, client: client}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	correlationId := uuid.New().String()
+	logger := log.With().Str(logging.FieldKeyCorrelationId, correlationId).Logger()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		render(w, http.StatusBadRequest, pageData{Heading: "Unable to silence alert", Message: "The request is invalid."})
+		return
+	}
+
+	labels, err := h.signer.Verify(r.Form)
+	if err != nil {
+		logger.Info().Err(err).Msg("Rejected request to silence alert.")
+		message := "The link is invalid."
+		if errors.Is(err, ErrExpired) {
+			message = "The link has expired. Please silence the alert in AlertManager."
+		}
+		render(w, http.StatusForbidden, pageData{Heading: "Unable to silence alert", Message: message})
+		return
+	}
+	filter := Filter(labels)
+
+	if r.Method == http.MethodGet {
+		query := map[string][]string{
+			parameterLabels:    {r.Form.Get(parameterLabels)},
+			parameterExpires:   {r.Form.Get(parameterExpires)},
+			parameterSignature: {r.Form.Get(parameterSignature)},
+		}
+		durations := make([]string, 0, len(h.signer.Durations()))
+		for _, d := range h.signer.Durations() {
+			durations = append(durations, FormatDuration(d))
+		}
+		render(w, http.StatusOK, pageData{Heading: "Silence alert", Filter: filter, Query: query, Durations: durations})
+		return
+	}
+
+	duration, err := h.signer.ParseDuration(r.PostForm.Get(parameterDuration))
+	if err != nil {
+		render(w, http.StatusBadRequest, pageData{Heading: "Unable to silence alert", Filter: filter, Message: err.Error()})
+		return
+	}
+
+	id, err := h.client.CreateSilence(labels, duration, comment)
+	if err != nil {
+		logger.Error().Err(err).Msg("Unable to create silence in AlertManager.")
+		render(w, http.StatusBadGateway, pageData{Heading: "Unable to silence alert", Filter: filter, Message: "AlertManager was unable to create the silence."})
+		return
+	}
+
+	logger.Info().Msgf("Created silence ('%s') for %s, matching: %s", id, FormatDuration(duration), filter)
+	render(w, http.StatusOK, pageData{Heading: "Silenced for " + FormatDuration(duration), Filter: filter, Message: "Silence id: " + id})
+}
+
+func render(w http.ResponseWriter, statusCode int, data pageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	if err := page.Execute(w, data); err != nil {
+		log.Error().Err(err).Msg("Unable to render silence page.")
+	}
+}
@@ -0,0 +1,80 @@
+package silence
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Handler_Get_RendersConfirmationWithoutSilencing(t *testing.T) {
+	requests := 0
+	mockAlertManager := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer mockAlertManager.Close()
+
+	signer := NewSigner([]byte("a_key"), "https://alertmanager-discord.example.com", time.Hour, nil)
+	SUT := NewHandler(signer, NewClient(&http.Client{}, mockAlertManager.URL, Credentials{}, ""))
+
+	w := httptest.NewRecorder()
+	SUT.ServeHTTP(w, httptest.NewRequest(http.MethodGet, signer.Link(map[string]string{"alertname": "HighLatency"}), nil))
+
+	assert.Equal(t, http.StatusOK, w.Code, "http response status code")
+	assert.Contains(t, w.Body.String(), `value="4h"`, "page should offer the configured durations")
+	assert.Equal(t, 0, requests, "a GET request should not create a silence")
+}
+
+func Test_Handler_Post_CreatesSilenceInAlertManager(t *testing.T) {
+	var silence postableSilence
+	var authorization string
+	mockAlertManager := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/silences", r.URL.Path, "AlertManager API path")
+		authorization = r.Header.Get("Authorization")
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&silence), "decoding silence")
+		_, _ = w.Write([]byte(`{"silenceID": "a_silence_id"}`))
+	}))
+	defer mockAlertManager.Close()
+
+	signer := NewSigner([]byte("a_key"), "https://alertmanager-discord.example.com", time.Hour, nil)
+	SUT := NewHandler(signer, NewClient(&http.Client{}, mockAlertManager.URL, Credentials{BearerToken: "a_token"}, ""))
+
+	link, err := url.Parse(signer.Link(map[string]string{"alertname": "HighLatency", "instance": "web-1"}))
+	assert.NoError(t, err, "parsing link")
+	form := link.Query()
+	form.Set(parameterDuration, "4h")
+	req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	SUT.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, "http response status code")
+	assert.Contains(t, w.Body.String(), "a_silence_id", "page should include the id of the silence")
+	assert.Equal(t, "Bearer a_token", authorization, "AlertManager credentials")
+	assert.Equal(t, []matcher{
+		{Name: "alertname", Value: "HighLatency", IsEqual: true},
+		{Name: "instance", Value: "web-1", IsEqual: true},
+	}, silence.Matchers, "silence matchers")
+	assert.Equal(t, 4*time.Hour, silence.EndsAt.Sub(silence.StartsAt), "silence duration")
+	assert.Equal(t, DefaultCreatedBy, silence.CreatedBy, "silence created by")
+}
+
+func Test_Handler_Post_InvalidSignature_IsForbidden(t *testing.T) {
+	signer := NewSigner([]byte("a_key"), "https://alertmanager-discord.example.com", time.Hour, nil)
+	SUT := NewHandler(signer, NewClient(&http.Client{}, "http://localhost:0", Credentials{}, ""))
+
+	form := url.Values{parameterLabels: {"e30"}, parameterExpires: {"9999999999"}, parameterSignature: {"forged"}, parameterDuration: {"1h"}}
+	req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	SUT.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code, "http response status code")
+}
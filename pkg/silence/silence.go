@@ -0,0 +1,171 @@
+package silence
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/internal/keys"
+)
+
+const (
+	// Path is the path at which the silence endpoint is served.
+	Path = "/silence"
+
+	DefaultLinkTTL = 7 * 24 * time.Hour
+
+	parameterLabels    = "l"
+	parameterExpires   = "e"
+	parameterSignature = "s"
+	parameterDuration  = "d"
+)
+
+var (
+	DefaultDurations = []time.Duration{time.Hour, 4 * time.Hour, 24 * time.Hour}
+
+	ErrInvalidSignature = errors.New("the link is invalid")
+	ErrExpired          = errors.New("the link has expired")
+)
+
+// AlertManagerURL returns the url of the AlertManager user interface, at which a new silence is pre-filled with matchers for all of the labels.
+// An empty string is returned if the external url of AlertManager is not known.
+func AlertManagerURL(externalURL string, labels map[string]string) string {
+	if externalURL == "" || len(labels) == 0 {
+		return ""
+	}
+
+	// the user interface does not decode '+' as a space within the query of its fragment
+	filter := strings.ReplaceAll(url.QueryEscape(Filter(labels)), "+", "%20")
+	return strings.TrimSuffix(externalURL, "/") + "/#/silences/new?filter=" + filter
+}
+
+// Filter formats the labels as AlertManager matchers, e.g. '{alertname="HighLatency", instance="web-1"}'.
+func Filter(labels map[string]string) string {
+	matchers := make([]string, 0, len(labels))
+	for _, name := range keys.Sorted(labels) {
+		matchers = append(matchers, name+"="+strconv.Quote(labels[name]))
+	}
+	return "{" + strings.Join(matchers, ", ") + "}"
+}
+
+// Signer creates links to the silence endpoint of this service, which are signed so that only the alerts which were sent to Discord can be silenced.
+type Signer struct {
+	key       []byte
+	baseURL   string
+	ttl       time.Duration
+	durations []time.Duration
+}
+
+// NewSigner creates a Signer for links to the silence endpoint, served at the external url of this service.
+// Links expire after the ttl, and may only silence alerts for one of the durations.
+func NewSigner(key []byte, externalURL string, ttl time.Duration, durations []time.Duration) *Signer {
+	if ttl <= 0 {
+		ttl = DefaultLinkTTL
+	}
+	if len(durations) == 0 {
+		durations = DefaultDurations
+	}
+	return &Signer{
+		key:       key,
+		baseURL:   strings.TrimSuffix(externalURL, "/") + Path,
+		ttl:       ttl,
+		durations: durations,
+	}
+}
+
+// Durations returns the durations for which alerts may be silenced.
+func (s *Signer) Durations() []time.Duration {
+	return s.durations
+}
+
+// Link returns a signed link to the silence endpoint, for an alert with the labels.
+func (s *Signer) Link(labels map[string]string) string {
+	return s.link(labels, time.Now().Add(s.ttl))
+}
+
+func (s *Signer) link(labels map[string]string, expires time.Time) string {
+	// json.Marshal sorts the keys of maps, so the encoding is deterministic
+	b, _ := json.Marshal(labels)
+	encodedLabels := base64.RawURLEncoding.EncodeToString(b)
+	expiresUnix := strconv.FormatInt(expires.Unix(), 10)
+
+	query := url.Values{}
+	query.Set(parameterLabels, encodedLabels)
+	query.Set(parameterExpires, expiresUnix)
+	query.Set(parameterSignature, s.sign(encodedLabels, expiresUnix))
+	return s.baseURL + "?" + query.Encode()
+}
+
+// Verify checks the signature and expiry of a link, returning the labels of the alert.
+func (s *Signer) Verify(values url.Values) (map[string]string, error) {
+	encodedLabels := values.Get(parameterLabels)
+	expiresUnix := values.Get(parameterExpires)
+
+	signature, err := base64.RawURLEncoding.DecodeString(values.Get(parameterSignature))
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	expected, _ := base64.RawURLEncoding.DecodeString(s.sign(encodedLabels, expiresUnix))
+	if !hmac.Equal(signature, expected) {
+		return nil, ErrInvalidSignature
+	}
+
+	expires, err := strconv.ParseInt(expiresUnix, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if time.Now().After(time.Unix(expires, 0)) {
+		return nil, ErrExpired
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(encodedLabels)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	labels := make(map[string]string)
+	if err := json.Unmarshal(b, &labels); err != nil || len(labels) == 0 {
+		return nil, ErrInvalidSignature
+	}
+	return labels, nil
+}
+
+// ParseDuration returns the duration, if it is one of the durations for which alerts may be silenced.
+func (s *Signer) ParseDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("duration ('%s') is invalid: %w", value, err)
+	}
+	for _, allowed := range s.durations {
+		if d == allowed {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("alerts may not be silenced for ('%s')", value)
+}
+
+func (s *Signer) sign(encodedLabels, expiresUnix string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(encodedLabels))
+	mac.Write([]byte{'.'})
+	mac.Write([]byte(expiresUnix))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// FormatDuration formats the duration without trailing zero units, e.g. '4h' rather than '4h0m0s'.
+func FormatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
@@ -0,0 +1,75 @@
+package silence
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_AlertManagerURL_PrefillsMatchersForAllLabels(t *testing.T) {
+	SUT := AlertManagerURL("https://alertmanager.example.com/", map[string]string{"instance": "web-1", "alertname": "HighLatency"})
+
+	assert.Equal(t, "https://alertmanager.example.com/#/silences/new?filter=%7Balertname%3D%22HighLatency%22%2C%20instance%3D%22web-1%22%7D", SUT, "silence url")
+	assert.Equal(t, "", AlertManagerURL("", map[string]string{"alertname": "HighLatency"}), "without an external url there is no silence url")
+}
+
+func Test_Signer_Link_IsVerified(t *testing.T) {
+	SUT := NewSigner([]byte("a_key"), "https://alertmanager-discord.example.com", time.Hour, nil)
+	labels := map[string]string{"alertname": "HighLatency", "instance": "web-1"}
+
+	link, err := url.Parse(SUT.Link(labels))
+	assert.NoError(t, err, "parsing link")
+	assert.Equal(t, Path, link.Path, "link path")
+
+	verified, err := SUT.Verify(link.Query())
+	assert.NoError(t, err, "verifying link")
+	assert.Equal(t, labels, verified, "labels of the link")
+}
+
+func Test_Signer_TamperedLink_IsRejected(t *testing.T) {
+	SUT := NewSigner([]byte("a_key"), "https://alertmanager-discord.example.com", time.Hour, nil)
+
+	link, err := url.Parse(SUT.Link(map[string]string{"alertname": "HighLatency"}))
+	assert.NoError(t, err, "parsing link")
+	query := link.Query()
+	other, err := url.Parse(SUT.Link(map[string]string{"alertname": "Other"}))
+	assert.NoError(t, err, "parsing other link")
+	query.Set(parameterLabels, other.Query().Get(parameterLabels))
+
+	_, err = SUT.Verify(query)
+	assert.ErrorIs(t, err, ErrInvalidSignature, "labels should not be replaceable")
+
+	_, err = NewSigner([]byte("another_key"), "", time.Hour, nil).Verify(link.Query())
+	assert.ErrorIs(t, err, ErrInvalidSignature, "link signed with another key")
+}
+
+func Test_Signer_ExpiredLink_IsRejected(t *testing.T) {
+	SUT := NewSigner([]byte("a_key"), "https://alertmanager-discord.example.com", time.Hour, nil)
+
+	link, err := url.Parse(SUT.link(map[string]string{"alertname": "HighLatency"}, time.Now().Add(-time.Minute)))
+	assert.NoError(t, err, "parsing link")
+
+	_, err = SUT.Verify(link.Query())
+	assert.ErrorIs(t, err, ErrExpired, "expired link")
+}
+
+func Test_Signer_ParseDuration_OnlyAllowsConfiguredDurations(t *testing.T) {
+	SUT := NewSigner([]byte("a_key"), "", time.Hour, []time.Duration{time.Hour, 4 * time.Hour})
+
+	d, err := SUT.ParseDuration("4h")
+	assert.NoError(t, err, "configured duration")
+	assert.Equal(t, 4*time.Hour, d, "parsed duration")
+
+	_, err = SUT.ParseDuration("8760h")
+	assert.Error(t, err, "durations which are not configured should be rejected")
+	assert.True(t, strings.Contains(err.Error(), "8760h"), "error should include the duration")
+}
+
+func Test_FormatDuration(t *testing.T) {
+	assert.Equal(t, "1h", FormatDuration(time.Hour), "hours")
+	assert.Equal(t, "1h30m", FormatDuration(90*time.Minute), "hours and minutes")
+	assert.Equal(t, "45s", FormatDuration(45*time.Second), "seconds")
+}
@@ -0,0 +1,127 @@
+package templates
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/internal/keys"
+)
+
+var funcMap = template.FuncMap{
+	"toUpper":          strings.ToUpper,
+	"toLower":          strings.ToLower,
+	"title":            title,
+	"trimSpace":        strings.TrimSpace,
+	"contains":         strings.Contains,
+	"hasPrefix":        strings.HasPrefix,
+	"hasSuffix":        strings.HasSuffix,
+	"replace":          strings.ReplaceAll,
+	"join":             join,
+	"split":            strings.Split,
+	"sortedKeys":       keys.Sorted[string],
+	"default":          defaultValue,
+	"humanizeDuration": humanizeDuration,
+}
+
+// title capitalises the first letter of each word.
+func title(s string) string {
+	previous := ' '
+	return strings.Map(func(r rune) rune {
+		defer func() { previous = r }()
+		if unicode.IsSpace(previous) || previous == '-' || previous == '_' {
+			return unicode.ToTitle(r)
+		}
+		return r
+	}, s)
+}
+
+// join accepts the separator first, so that it can be used at the end of a pipeline.
+func join(sep string, elems []string) string {
+	return strings.Join(elems, sep)
+}
+
+// defaultValue returns the given value, or the default if the value is empty.
+// The default is accepted first, so that it can be used at the end of a pipeline.
+func defaultValue(def, value string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// humanizeDuration formats a number of seconds, a time.Duration, or a duration string in a human readable form, e.g. '1d 2h 3m 4s'.
+// This matches the behaviour of the Prometheus template function of the same name.
+func humanizeDuration(i any) (string, error) {
+	var v float64
+	switch d := i.(type) {
+	case time.Duration:
+		v = d.Seconds()
+	case float64:
+		v = d
+	case float32:
+		v = float64(d)
+	case int:
+		v = float64(d)
+	case int64:
+		v = float64(d)
+	case string:
+		parsed, err := time.ParseDuration(d)
+		if err != nil {
+			return "", fmt.Errorf("unable to parse duration ('%s'): %w", d, err)
+		}
+		v = parsed.Seconds()
+	default:
+		return "", fmt.Errorf("unable to humanize duration of type ('%T')", i)
+	}
+
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Sprintf("%.4g", v), nil
+	}
+	if v == 0 {
+		return "0s", nil
+	}
+
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	if v < 1 {
+		if v >= 1e-3 {
+			return fmt.Sprintf("%s%.4gms", sign, v*1e3), nil
+		}
+		if v >= 1e-6 {
+			return fmt.Sprintf("%s%.4gus", sign, v*1e6), nil
+		}
+		return fmt.Sprintf("%s%.4gns", sign, v*1e9), nil
+	}
+
+	seconds := int64(v) % 60
+	minutes := (int64(v) / 60) % 60
+	hours := (int64(v) / 60 / 60) % 24
+	days := int64(v) / 60 / 60 / 24
+
+	var parts []string
+	if days != 0 {
+		parts = append(parts, fmt.Sprintf("%dd", days))
+	}
+	if hours != 0 {
+		parts = append(parts, fmt.Sprintf("%dh", hours))
+	}
+	if minutes != 0 {
+		parts = append(parts, fmt.Sprintf("%dm", minutes))
+	}
+	if days == 0 && hours == 0 && minutes == 0 {
+		return fmt.Sprintf("%s%.4gs", sign, v), nil
+	}
+	if seconds != 0 {
+		parts = append(parts, fmt.Sprintf("%ds", seconds))
+	}
+
+	return sign + strings.Join(parts, " "), nil
+}
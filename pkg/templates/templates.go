@@ -0,0 +1,130 @@
+package templates
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+)
+
+// The names of the templates used to render each part of a Discord message.
+const (
+	NameContent     = "discord.content"
+	NameTitle       = "discord.title"
+	NameDescription = "discord.description"
+	NameFieldName   = "discord.field.name"
+	NameFieldValue  = "discord.field.value"
+	NameThreadName  = "discord.thread.name"
+)
+
+// Names lists all templates which are rendered for a Discord message.
+var Names = []string{NameContent, NameTitle, NameDescription, NameFieldName, NameFieldValue, NameThreadName}
+
+//go:embed default.tmpl
+var defaultTemplate string
+
+var defaultTemplates = template.Must(newTemplate().Parse(defaultTemplate))
+
+type Template struct {
+	tmpl *template.Template
+}
+
+// Default returns the templates which reproduce the standard Discord message format.
+func Default() *Template {
+	return &Template{tmpl: defaultTemplates}
+}
+
+// New parses the template files, in order, on top of the default templates.
+// Templates defined in the files replace any default or previously defined template of the same name.
+func New(files ...string) (*Template, error) {
+	if len(files) == 0 {
+		return Default(), nil
+	}
+
+	tmpl, err := defaultTemplates.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("unable to clone default templates: %w", err)
+	}
+
+	for _, file := range files {
+		if tmpl, err = tmpl.ParseFiles(file); err != nil {
+			return nil, fmt.Errorf("unable to parse template file ('%s'): %w", file, err)
+		}
+	}
+
+	return &Template{tmpl: tmpl}, nil
+}
+
+func newTemplate() *template.Template {
+	return template.New("default").
+		Option("missingkey=zero").
+		Funcs(funcMap)
+}
+
+// Execute renders the named template with the data.
+func (t *Template) Execute(name string, data any) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.ExecuteTemplate(&b, name, data); err != nil {
+		return "", fmt.Errorf("unable to execute template ('%s'): %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// Data is provided to the content, title, and description templates.
+type Data struct {
+	// Status of the alerts within this message, which may differ from the status of the whole notification.
+	Status string
+	Alerts []alertmanager.Alert
+
+	// TruncatedAlerts is the number of alerts which AlertManager omitted from the notification.
+	TruncatedAlerts   int
+	Receiver          string
+	GroupKey          string
+	ExternalURL       string
+	GroupLabels       map[string]string
+	CommonLabels      map[string]string
+	CommonAnnotations map[string]string
+
+	// Title, Message, and State are only sent by Grafana, and are otherwise empty.
+	Title   string
+	Message string
+	State   string
+}
+
+// FieldData is provided to the field name and field value templates, which are rendered once per alert.
+type FieldData struct {
+	Data
+	Alert alertmanager.Alert
+
+	// SilenceURL is the page of the AlertManager, or Grafana, user interface on which a silence for the alert is pre-filled, if it is known.
+	SilenceURL string
+	// QuickSilenceURL is the page of this service from which the alert can be silenced, if silences have been configured.
+	QuickSilenceURL string
+	// Links are the alert's generator url, dashboard and panel urls, and the silence urls, which are present.
+	Links []Link
+}
+
+// Link is a markdown link, e.g. '[Silence](https://...)'.
+type Link struct {
+	Text string
+	URL  string
+}
+
+func NewData(status string, amo *alertmanager.Out, alerts []alertmanager.Alert) Data {
+	return Data{
+		Status:            status,
+		Alerts:            alerts,
+		TruncatedAlerts:   amo.TruncatedAlerts,
+		Receiver:          amo.Receiver,
+		GroupKey:          amo.GroupKey,
+		ExternalURL:       amo.ExternalURL,
+		GroupLabels:       amo.GroupLabels,
+		CommonLabels:      amo.CommonLabels,
+		CommonAnnotations: amo.CommonAnnotations,
+		Title:             amo.Title,
+		Message:           amo.Message,
+		State:             amo.State,
+	}
+}
@@ -0,0 +1,122 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Default_MatchesStandardFormat(t *testing.T) {
+	amo := &alertmanager.Out{}
+	amo.CommonAnnotations = map[string]string{"summary": "a_summary"}
+	amo.CommonLabels = map[string]string{"alertname": "an_alertname"}
+	alert := alertmanager.Alert{
+		Status: alertmanager.StatusFiring,
+		Annotations: map[string]string{
+			"summary":     "an_alert_summary",
+			"runbook_url": "https://example.org/runbook",
+			"description": "a_description",
+		},
+		Labels: map[string]string{
+			"source_environment_type": "k8s",
+			"source_environment_name": "production",
+			"severity":                "critical",
+			"alertname":               "an_alertname",
+		},
+	}
+	data := NewData(alertmanager.StatusFiring, amo, []alertmanager.Alert{alert})
+	SUT := Default()
+
+	assertRendered(t, SUT, NameContent, data, " === a_summary === \n")
+	assertRendered(t, SUT, NameTitle, data, "[FIRING: 1] an_alertname")
+	assertRendered(t, SUT, NameDescription, data, "a_summary")
+	assertRendered(t, SUT, NameFieldName, FieldData{Data: data, Alert: alert}, "[k8s/production] an_alert_summary")
+	assertRendered(t, SUT, NameFieldValue, FieldData{Data: data, Alert: alert},
+		"Annotations:\n\tdescription: a_description\n\trunbook_url: https://example.org/runbook\nLabels:\n\talertname: an_alertname\n\tseverity: critical\n")
+}
+
+func Test_Default_MissingValues_RenderEmpty(t *testing.T) {
+	alert := alertmanager.Alert{Status: alertmanager.StatusResolved}
+	data := NewData(alertmanager.StatusResolved, &alertmanager.Out{}, []alertmanager.Alert{alert})
+	SUT := Default()
+
+	assertRendered(t, SUT, NameContent, data, "")
+	assertRendered(t, SUT, NameTitle, data, "[RESOLVED: 1] ")
+	assertRendered(t, SUT, NameDescription, data, "")
+	assertRendered(t, SUT, NameFieldName, FieldData{Data: data, Alert: alert}, "[/] Alert details")
+	assertRendered(t, SUT, NameFieldValue, FieldData{Data: data, Alert: alert}, "Annotations:\nLabels:\n")
+}
+
+func Test_Default_Links_AreAppendedToFieldValue(t *testing.T) {
+	alert := alertmanager.Alert{Status: alertmanager.StatusFiring}
+	data := NewData(alertmanager.StatusFiring, &alertmanager.Out{}, []alertmanager.Alert{alert})
+	fieldData := FieldData{
+		Data:  data,
+		Alert: alert,
+		Links: []Link{{Text: "Source", URL: "https://prometheus.example.com"}, {Text: "Silence", URL: "https://alertmanager.example.com"}},
+	}
+
+	assertRendered(t, Default(), NameFieldValue, fieldData,
+		"Annotations:\nLabels:\n[Source](https://prometheus.example.com) | [Silence](https://alertmanager.example.com)")
+}
+
+func Test_New_TemplateFile_OverridesDefaults(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "custom.tmpl")
+	err := os.WriteFile(file, []byte(`{{ define "discord.title" }}{{ .Status | toUpper }} {{ .CommonLabels.alertname }} {{ .CommonLabels | sortedKeys | join "," }}{{ end }}`), 0o600)
+	assert.NoError(t, err, "writing template file")
+
+	SUT, err := New(file)
+	assert.NoError(t, err, "parsing template file")
+
+	amo := &alertmanager.Out{}
+	amo.CommonLabels = map[string]string{"alertname": "an_alertname"}
+	amo.CommonAnnotations = map[string]string{"summary": "a_summary"}
+	data := NewData(alertmanager.StatusFiring, amo, nil)
+
+	assertRendered(t, SUT, NameTitle, data, "FIRING an_alertname alertname")
+	assertRendered(t, SUT, NameDescription, data, "a_summary")
+	assertRendered(t, Default(), NameTitle, data, "[FIRING: 0] an_alertname")
+}
+
+func Test_New_InvalidTemplateFile_ReturnsError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "invalid.tmpl")
+	err := os.WriteFile(file, []byte(`{{ define "discord.title" }}{{ .Status `), 0o600)
+	assert.NoError(t, err, "writing template file")
+
+	_, err = New(file)
+	assert.Error(t, err, "parsing an invalid template should return an error")
+
+	_, err = New(filepath.Join(t.TempDir(), "does_not_exist.tmpl"))
+	assert.Error(t, err, "parsing a missing template file should return an error")
+}
+
+func Test_HumanizeDuration(t *testing.T) {
+	for input, expected := range map[any]string{
+		0:                "0s",
+		1.5:              "1.5s",
+		0.25:             "250ms",
+		90 * time.Second: "1m 30s",
+		"26h3m":          "1d 2h 3m",
+		int64(-3600):     "-1h",
+		49 * time.Hour:   "2d 1h",
+	} {
+		actual, err := humanizeDuration(input)
+		assert.NoError(t, err, "humanizing duration")
+		assert.Equal(t, expected, actual, "humanized duration of %v", input)
+	}
+
+	_, err := humanizeDuration(struct{}{})
+	assert.Error(t, err, "unsupported types should return an error")
+}
+
+func assertRendered(t *testing.T, tmpl *Template, name string, data any, expected string) {
+	t.Helper()
+	actual, err := tmpl.Execute(name, data)
+	assert.NoError(t, err, "executing template ('%s')", name)
+	assert.Equal(t, expected, actual, "rendered template ('%s')", name)
+}
@@ -0,0 +1,369 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/specklesystems/alertmanager-discord/pkg/alertforwarder"
+	"github.com/specklesystems/alertmanager-discord/pkg/alertmanager"
+	"github.com/specklesystems/alertmanager-discord/pkg/config"
+	"github.com/specklesystems/alertmanager-discord/pkg/decoder"
+	"github.com/specklesystems/alertmanager-discord/pkg/discord"
+	"github.com/specklesystems/alertmanager-discord/pkg/flags"
+	"github.com/specklesystems/alertmanager-discord/pkg/mapping"
+	"github.com/specklesystems/alertmanager-discord/pkg/routing"
+	"github.com/specklesystems/alertmanager-discord/pkg/templates"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is an error within the configuration file, located at a line of the file if possible.
+type Problem struct {
+	// Line is the line of the configuration file at which the problem was found, or 0 if it applies to the whole file.
+	Line    int
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("%d: %s", p.Line, p.Message)
+}
+
+// Validate checks the configuration file as thoroughly as possible without sending anything to Discord.
+// The url of every webhook is checked, the template files of every webhook are parsed and rendered against sample notifications,
+// and the matchers of every route and mention, and the expressions of every mapping, are compiled. The consistency of the configuration as a whole is checked once there are no other problems.
+// The default webhook url, if provided, or otherwise the 'discord_webhook_url' or 'discord_webhook_url_file' key of the file, is added to the configuration as it would be by the server.
+func Validate(b []byte, defaultWebhookURL string) []Problem {
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return []Problem{{Line: errorLine(err), Message: fmt.Sprintf("unable to parse configuration: %s", err)}}
+	}
+	cfg, err := config.Parse(b)
+	if err != nil {
+		return []Problem{{Line: errorLine(err), Message: err.Error()}}
+	}
+
+	v := &validator{root: &root, cfg: cfg}
+	v.webhooks()
+	if cfg.Route != nil {
+		v.route(cfg.Route, "route")
+	}
+	for i, receiver := range cfg.Receivers {
+		if receiver.Route != nil {
+			v.route(receiver.Route, "receivers", i, "route")
+		} else if receiver.Webhook != "" && !v.webhookExists(receiver.Webhook) {
+			v.add(fmt.Sprintf("receiver ('%s') refers to webhook ('%s') which has not been configured", receiver.Name, receiver.Webhook), "receivers", i, "webhook")
+		}
+		if _, ok := decoder.Default().Lookup(receiver.Format); receiver.Format != "" && !ok {
+			v.add(fmt.Sprintf("format ('%s') of receiver ('%s') is not supported, expected one of: %s", receiver.Format, receiver.Name, strings.Join(decoder.Default().Formats(), ", ")), "receivers", i, "format")
+		}
+	}
+	for i, m := range cfg.Mappings {
+		if _, err := mapping.New(m); err != nil {
+			v.add(err.Error(), "mappings", i)
+		}
+		if m.Webhook != "" && !v.webhookExists(m.Webhook) {
+			v.add(fmt.Sprintf("mapping ('%s') refers to webhook ('%s') which has not been configured", m.Name, m.Webhook), "mappings", i, "webhook")
+		}
+	}
+	if cfg.Prometheus != nil && cfg.Prometheus.GroupWaitSeconds < 0 {
+		v.add("the group wait of Prometheus alerts must not be negative", "prometheus", "group_wait_seconds")
+	}
+	if cfg.Severity != nil {
+		for i, level := range cfg.Severity.Levels {
+			if _, err := discord.ParseColor(level.Color); err != nil {
+				v.add(fmt.Sprintf("invalid colour of severity ('%s'): %s", level.Name, err), "severity", "levels", i, "color")
+			}
+		}
+	}
+	if len(v.problems) > 0 {
+		return v.problems
+	}
+
+	// the checks of the configuration as a whole stop at the first problem, which may otherwise repeat a problem found above
+	var defaultWebhookPath []any
+	if defaultWebhookURL == "" {
+		defaultWebhookURL = scalar(&root, flags.DiscordWebhookUrlFlagKey)
+		defaultWebhookPath = []any{flags.DiscordWebhookUrlFlagKey}
+	}
+	if file := scalar(&root, flags.DiscordWebhookUrlFileFlagKey); defaultWebhookURL == "" && file != "" {
+		defaultWebhookPath = []any{flags.DiscordWebhookUrlFileFlagKey}
+		if defaultWebhookURL, err = config.ReadSecret("", file); err != nil {
+			v.add(fmt.Sprintf("default webhook: %s", err), defaultWebhookPath...)
+			return v.problems
+		}
+	}
+	_, configured := cfg.Webhook(config.DefaultWebhookName)
+	if !configured && defaultWebhookURL != "" {
+		if _, _, err := alertforwarder.CheckWebhookURL(defaultWebhookURL); err != nil {
+			v.add(fmt.Sprintf("default webhook: %s", err), defaultWebhookPath...)
+		}
+	}
+	cfg.AddDefaultWebhook(defaultWebhookURL, 0)
+	// the urls have been checked above, so are not read again
+	for i := range cfg.Webhooks {
+		cfg.Webhooks[i].URLFile = ""
+	}
+	if err := cfg.Validate(); err != nil {
+		v.add(err.Error())
+	}
+	return v.problems
+}
+
+type validator struct {
+	root     *yaml.Node
+	cfg      *config.Config
+	problems []Problem
+}
+
+// add records a problem, located at the node of the configuration file at the path, or at its deepest ancestor which exists.
+// If there is no path, the problem applies to the whole file.
+func (v *validator) add(message string, path ...any) {
+	problem := Problem{Message: message}
+	if len(path) > 0 {
+		problem.Line = line(v.root, path...)
+	}
+	v.problems = append(v.problems, problem)
+}
+
+func (v *validator) webhookExists(name string) bool {
+	if _, ok := v.cfg.Webhook(name); ok {
+		return true
+	}
+	// the default webhook may instead be provided by a flag or environment variable
+	return name == config.DefaultWebhookName
+}
+
+func (v *validator) webhooks() {
+	for i, webhook := range v.cfg.Webhooks {
+		if webhook.URLFile == "" {
+			if _, _, err := alertforwarder.CheckWebhookURL(webhook.URL); err != nil {
+				v.add(fmt.Sprintf("webhook ('%s'): %s", webhook.Name, err), "webhooks", i, "url")
+			}
+		} else if webhook.URL != "" {
+			v.add(fmt.Sprintf("webhook ('%s') must have either a url or a url file, not both", webhook.Name), "webhooks", i, "url_file")
+		} else if url, err := config.ReadSecret("", webhook.URLFile); err != nil {
+			v.add(fmt.Sprintf("webhook ('%s'): %s", webhook.Name, err), "webhooks", i, "url_file")
+		} else if _, _, err := alertforwarder.CheckWebhookURL(url); err != nil {
+			v.add(fmt.Sprintf("webhook ('%s'): url file: %s", webhook.Name, err), "webhooks", i, "url_file")
+		}
+
+		for j := range webhook.Mentions {
+			if err := webhook.Mentions[j].Compile(); err != nil {
+				v.add(fmt.Sprintf("mention of webhook ('%s'): %s", webhook.Name, err), "webhooks", i, "mentions", j)
+			}
+		}
+
+		tmpl, err := templates.New(v.cfg.WebhookTemplateFiles(webhook)...)
+		if err != nil {
+			v.add(fmt.Sprintf("templates of webhook ('%s'): %s", webhook.Name, err), "webhooks", i, "template_files")
+			continue
+		}
+		if err := render(tmpl); err != nil {
+			v.add(fmt.Sprintf("templates of webhook ('%s'): %s", webhook.Name, err), "webhooks", i, "template_files")
+		}
+	}
+}
+
+// route checks the matchers and webhooks of the route and its children, each located at its own node.
+func (v *validator) route(route *routing.Route, path ...any) {
+	if _, err := routing.CompileMatchers(route.Match, route.MatchRE, route.Matchers); err != nil {
+		v.add(fmt.Sprintf("invalid route: %s", err), path...)
+	}
+	if route.Webhook != "" && !v.webhookExists(route.Webhook) {
+		v.add(fmt.Sprintf("route refers to webhook ('%s') which has not been configured", route.Webhook), append(path, "webhook")...)
+	}
+	for i, child := range route.Routes {
+		childPath := append(append([]any{}, path...), "routes", i)
+		if child == nil {
+			v.add("route is empty", childPath...)
+			continue
+		}
+		v.route(child, childPath...)
+	}
+}
+
+// render executes every template against each sample notification, split by status as the alert forwarder would.
+func render(tmpl *templates.Template) error {
+	for _, sample := range Samples() {
+		for _, status := range []string{alertmanager.StatusFiring, alertmanager.StatusResolved} {
+			var alerts []alertmanager.Alert
+			for _, alert := range sample.Out.Alerts {
+				if alert.Status == status {
+					alerts = append(alerts, alert)
+				}
+			}
+			if len(alerts) == 0 {
+				continue
+			}
+
+			if _, err := alertforwarder.TranslateAlertManagerToDiscord(status, &sample.Out, alerts, tmpl, nil, nil); err != nil {
+				return fmt.Errorf("unable to render the %s sample notification: %w", sample.Name, err)
+			}
+			if _, err := alertforwarder.TranslateThreadName(status, &sample.Out, alerts, tmpl); err != nil {
+				return fmt.Errorf("unable to render the %s sample notification: %w", sample.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// line returns the line of the node at the path, which consists of mapping keys and sequence indices.
+// If the path does not exist, the line of its deepest ancestor which does is returned.
+func line(root *yaml.Node, path ...any) int {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	current := node.Line
+
+	for _, element := range path {
+		var next *yaml.Node
+		switch key := element.(type) {
+		case string:
+			if node.Kind != yaml.MappingNode {
+				return current
+			}
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					// the line of the key, as the value of a mapping or sequence begins on the following line
+					current = node.Content[i].Line
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case int:
+			if node.Kind != yaml.SequenceNode || key >= len(node.Content) {
+				return current
+			}
+			next = node.Content[key]
+			current = next.Line
+		}
+		if next == nil {
+			return current
+		}
+		node = next
+	}
+	return current
+}
+
+// scalar returns the value of the top level key of the configuration file, or an empty string if it is not a scalar.
+func scalar(root *yaml.Node, key string) string {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return ""
+	}
+	mapping := root.Content[0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key && mapping.Content[i+1].Kind == yaml.ScalarNode {
+			return mapping.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+var errorLineRegexp = regexp.MustCompile(`line (\d+):`)
+
+// errorLine returns the first line mentioned by a yaml error, e.g. of invalid syntax or of a string given for a list.
+func errorLine(err error) int {
+	match := errorLineRegexp.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(match[1])
+	return n
+}
+
+// Sample is a notification against which templates are rendered.
+type Sample struct {
+	Name string
+	Out  alertmanager.Out
+}
+
+// Samples returns notifications resembling those sent by AlertManager: a single firing alert, a single resolved alert,
+// a group of alerts with mixed statuses, a large group which must be split across messages, and a group sent by Grafana.
+// The first sample has no annotations, so that templates which assume that an annotation is present are caught.
+func Samples() []Sample {
+	startsAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	endsAt := startsAt.Add(17 * time.Minute)
+
+	fingerprint := 0
+	alert := func(status, instance string, annotations map[string]string) alertmanager.Alert {
+		fingerprint++
+		a := alertmanager.Alert{
+			Status: status,
+			Labels: map[string]string{
+				"alertname": "HighLatency",
+				"severity":  "warning",
+				"cluster":   "eu-1",
+				"instance":  instance,
+			},
+			Annotations:  annotations,
+			StartsAt:     startsAt,
+			GeneratorURL: "https://prometheus.example.com/graph?g0.expr=latency",
+			Fingerprint:  fmt.Sprintf("%016x", fingerprint),
+		}
+		if status == alertmanager.StatusResolved {
+			a.EndsAt = endsAt
+		}
+		return a
+	}
+	annotations := map[string]string{
+		"summary":     "Latency is high",
+		"description": "The p99 latency has been above 1s for 5 minutes.",
+		"runbook_url": "https://example.com/runbooks/high-latency",
+	}
+	out := func(status string, alerts ...alertmanager.Alert) alertmanager.Out {
+		return alertmanager.Out{
+			Version:           alertmanager.Version,
+			GroupKey:          `{}:{alertname="HighLatency"}`,
+			Status:            status,
+			Receiver:          "discord",
+			GroupLabels:       map[string]string{"alertname": "HighLatency"},
+			CommonLabels:      map[string]string{"alertname": "HighLatency", "severity": "warning", "cluster": "eu-1"},
+			CommonAnnotations: annotations,
+			ExternalURL:       "https://alertmanager.example.com",
+			Alerts:            alerts,
+		}
+	}
+
+	firing := out(alertmanager.StatusFiring, alert(alertmanager.StatusFiring, "web-1", nil))
+	firing.CommonAnnotations = nil
+
+	var large []alertmanager.Alert
+	for i := 0; i < 60; i++ {
+		large = append(large, alert(alertmanager.StatusFiring, fmt.Sprintf("web-%d", i), annotations))
+	}
+	largeOut := out(alertmanager.StatusFiring, large...)
+	largeOut.TruncatedAlerts = 3
+
+	grafanaAlert := alert(alertmanager.StatusFiring, "web-1", annotations)
+	grafanaAlert.GeneratorURL = "https://grafana.example.com/alerting/grafana/latency/view"
+	grafanaAlert.DashboardURL = "https://grafana.example.com/d/latency"
+	grafanaAlert.PanelURL = "https://grafana.example.com/d/latency?viewPanel=1"
+	grafanaAlert.SilenceURL = "https://grafana.example.com/alerting/silence/new?matcher=alertname%3DHighLatency"
+	grafanaAlert.ImageURL = "https://grafana.example.com/public/img/attachments/latency.png"
+	grafanaAlert.Values = map[string]float64{"B": 1.25, "C": 1}
+	grafana := out(alertmanager.StatusFiring, grafanaAlert)
+	grafana.Version = alertmanager.GrafanaVersion
+	grafana.ExternalURL = "https://grafana.example.com/"
+	grafana.Title = "[FIRING:1] HighLatency (eu-1 warning)"
+	grafana.Message = "**Firing**\n\nValue: B=1.25, C=1"
+	grafana.State = "alerting"
+	grafana.OrgID = 1
+
+	return []Sample{
+		{Name: "firing", Out: firing},
+		{Name: "resolved", Out: out(alertmanager.StatusResolved, alert(alertmanager.StatusResolved, "web-1", annotations))},
+		{Name: "mixed", Out: out(alertmanager.StatusFiring,
+			alert(alertmanager.StatusFiring, "web-1", annotations),
+			alert(alertmanager.StatusResolved, "web-2", annotations),
+		)},
+		{Name: "large", Out: largeOut},
+		{Name: "grafana", Out: grafana},
+	}
+}
@@ -0,0 +1,90 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const validWebhookURL = "https://discord.com/api/webhooks/123456789123456789/a_token"
+
+func Test_Validate_ValidConfiguration_ReturnsNoProblems(t *testing.T) {
+	templateFile := writeFile(t, "title.tmpl", `{{ define "discord.title" }}[{{ .Status | toUpper }}] {{ .CommonLabels.alertname }}{{ end }}`)
+	configuration := `
+webhooks:
+  - name: platform
+    url: ` + validWebhookURL + `
+    template_files: [` + templateFile + `]
+route:
+  webhook: platform
+  routes:
+    - matchers: ['severity="critical"']
+`
+
+	assert.Empty(t, Validate([]byte(configuration), ""), "valid configuration should not have problems")
+}
+
+func Test_Validate_InvalidConfiguration_ReturnsLineNumberedProblems(t *testing.T) {
+	brokenTemplate := writeFile(t, "broken.tmpl", `{{ define "discord.title" }}{{ .Status | notAFunction }}{{ end }}`)
+	failingTemplate := writeFile(t, "failing.tmpl", `{{ define "discord.field.value" }}{{ index .Alert.Annotations.summary 5 }}{{ end }}`)
+	configuration := `webhooks:
+  - name: platform
+    url: https://example.com/not-discord
+  - name: broken
+    url: ` + validWebhookURL + `
+    template_files:
+      - ` + brokenTemplate + `
+  - name: failing
+    url: ` + validWebhookURL + `
+    template_files:
+      - ` + failingTemplate + `
+route:
+  webhook: platform
+  routes:
+    - webhook: broken
+    - matchers: ['severity=~"("']
+      webhook: unknown
+`
+
+	problems := Validate([]byte(configuration), "")
+
+	lines := make(map[int]string)
+	for _, problem := range problems {
+		lines[problem.Line] = problem.Message
+	}
+	assert.Len(t, problems, 5, "problems: %v", problems)
+	assert.Contains(t, lines[3], "doesn't seem to be a valid Discord Webhook API url", "the invalid url should be reported at its line")
+	assert.Contains(t, lines[6], "notAFunction", "the template which cannot be parsed should be reported at the template files of its webhook")
+	assert.Contains(t, lines[10], "unable to render the firing sample notification", "the template which cannot be rendered should be reported at the template files of its webhook")
+	assert.Contains(t, lines[16], "invalid route", "the invalid matcher should be reported at its route")
+	assert.Contains(t, lines[17], "webhook ('unknown') which has not been configured", "the unknown webhook should be reported at its line")
+}
+
+func Test_Validate_InvalidYAML_ReturnsLineNumberedProblem(t *testing.T) {
+	problems := Validate([]byte("webhooks:\n  - name: a\n    url: [\n"), "")
+	assert.Len(t, problems, 1, "problems: %v", problems)
+	assert.Equal(t, 3, problems[0].Line, "syntax error should be reported at its line")
+
+	problems = Validate([]byte("webhooks:\n  - name: a\n    template_files: a.tmpl\n"), "")
+	assert.Len(t, problems, 1, "problems: %v", problems)
+	assert.Equal(t, 3, problems[0].Line, "type error should be reported at its line")
+}
+
+func Test_Validate_DefaultWebhook(t *testing.T) {
+	problems := Validate([]byte("discord_webhook_url: https://example.com\n"), "")
+	assert.Len(t, problems, 1, "problems: %v", problems)
+	assert.Equal(t, 1, problems[0].Line, "invalid default webhook url from the file should be reported at its line")
+
+	assert.Empty(t, Validate([]byte("discord_webhook_url: https://example.com\n"), validWebhookURL), "default webhook url provided by the environment takes precedence over the file")
+	assert.NotEmpty(t, Validate([]byte(""), ""), "configuration without any webhooks should be invalid")
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(strings.TrimSpace(content)), 0o600), "writing file")
+	return path
+}